@@ -1,6 +1,8 @@
 go install github.com/gonutz/blob/cmd/blob@latest
 mkdir temp_blob
 copy *.png temp_blob
+copy font.txt temp_blob
+copy level.json temp_blob
 blob -path=temp_blob -out=data.blob
 del /Q temp_blob\*
 rmdir temp_blob
\ No newline at end of file
@@ -0,0 +1,80 @@
+// ld40-screenshot renders the game scene on the CPU and saves it as a PNG. It
+// needs neither a GPU nor Windows.
+//
+// Usage:
+//
+//	ld40-screenshot -level=level.json -out=shot.png -x=0 -z=0 -yaw=30 -pitch=-10
+//
+// The player stands on the terrain at x,z and looks in the direction given by
+// yaw (degrees around the y-axis, 0 is looking down the z-axis) and pitch
+// (degrees up or down).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+	"github.com/gonutz/ld40/level"
+	"github.com/gonutz/ld40/render"
+	"github.com/gonutz/ld40/render/soft"
+)
+
+func main() {
+	var (
+		lvl    = flag.String("level", "level.json", "level file")
+		out    = flag.String("out", "screenshot.png", "output PNG file")
+		width  = flag.Int("width", 640, "image width in pixels")
+		height = flag.Int("height", 480, "image height in pixels")
+		x      = flag.Float64("x", 0, "player x position")
+		z      = flag.Float64("z", 0, "player z position")
+		yaw    = flag.Float64("yaw", 0, "view direction around the y-axis in degrees")
+		pitch  = flag.Float64("pitch", 0, "view direction up/down in degrees")
+	)
+	flag.Parse()
+
+	if err := run(*lvl, *out, *width, *height, *x, *z, *yaw, *pitch); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(levelPath, out string, width, height int, x, z, yaw, pitch float64) error {
+	dir := filepath.Dir(levelPath)
+	open := level.DirOpener(dir)
+	l, err := level.Load(filepath.Base(levelPath), open)
+	if err != nil {
+		return err
+	}
+	ground, err := l.LoadGround(open)
+	if err != nil {
+		return err
+	}
+
+	world := l.NewWorld(ground)
+	world.Pos = d3dmath.Vec3{float32(x), 0, float32(z)}
+	world.Pos[1] = game.HeightAt(world.Pos[0], world.Pos[2], ground)
+	world.Yaw, world.Pitch = float32(yaw), float32(pitch)
+	world.PrevPos = world.Pos
+	world.PrevYaw, world.PrevPitch = world.Yaw, world.Pitch
+
+	terrain := render.NewTerrain(ground)
+	r := soft.New(width, height)
+	if err := r.LoadLevel(dir, l, terrain); err != nil {
+		return err
+	}
+	r.Clear(color.RGBA{255, 0, 0, 255})
+	render.Draw(r, render.NewScene(world, l, terrain, 1, float32(width)/float32(height)))
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, r.Image)
+}
@@ -0,0 +1,118 @@
+// ld40-sim runs the player simulation without a window or graphics device. It
+// loads a level, feeds the inputs from a script file (see parseScript) to
+// the simulation tick by tick and prints the final state of the player as JSON.
+//
+// Usage:
+//
+//	ld40-sim -level=level.json -script=jump.txt -ticks=120
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+	"github.com/gonutz/ld40/level"
+)
+
+func main() {
+	var (
+		levelPath  = flag.String("level", "level.json", "level file")
+		scriptPath = flag.String("script", "", "input script, leave empty or use - for stdin")
+		ticks      = flag.Int("ticks", 0, "number of ticks to simulate, 0 means the length of the script")
+		tickRate   = flag.Int("tickrate", 60, "simulation ticks per second")
+	)
+	flag.Parse()
+
+	if err := run(*levelPath, *scriptPath, *ticks, *tickRate, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(levelPath, scriptPath string, ticks, tickRate int, out io.Writer) error {
+	if tickRate <= 0 {
+		return fmt.Errorf("tick rate must be positive but is %d", tickRate)
+	}
+	if ticks < 0 {
+		return fmt.Errorf("tick count must not be negative but is %d", ticks)
+	}
+
+	open := level.DirOpener(filepath.Dir(levelPath))
+	l, err := level.Load(filepath.Base(levelPath), open)
+	if err != nil {
+		return err
+	}
+	ground, err := l.LoadGround(open)
+	if err != nil {
+		return err
+	}
+
+	var script io.Reader = os.Stdin
+	if scriptPath != "" && scriptPath != "-" {
+		f, err := os.Open(scriptPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		script = f
+	}
+	inputs, err := parseScript(script)
+	if err != nil {
+		return err
+	}
+	if ticks == 0 {
+		ticks = len(inputs)
+	}
+
+	world := l.NewWorld(ground)
+	dt := 1 / float32(tickRate)
+	for i := 0; i < ticks; i++ {
+		var in game.Input
+		if i < len(inputs) {
+			in = inputs[i]
+		}
+		world.Step(in, dt)
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(finalState(world, ticks))
+}
+
+type state struct {
+	Ticks      int          `json:"ticks"`
+	Position   d3dmath.Vec3 `json:"position"`
+	ViewDir    d3dmath.Vec3 `json:"viewDir"`
+	InAir      bool         `json:"inAir"`
+	VelY       float32      `json:"velY"`
+	Ground     float32      `json:"groundHeight"`
+	LaserBeams []beam       `json:"laserBeams"`
+}
+
+type beam struct {
+	Start d3dmath.Vec3 `json:"start"`
+	End   d3dmath.Vec3 `json:"end"`
+	Life  float32      `json:"life"`
+}
+
+func finalState(w *game.World, ticks int) state {
+	s := state{
+		Ticks:      ticks,
+		Position:   w.Pos,
+		ViewDir:    w.ViewDir(),
+		InAir:      w.InAir(),
+		VelY:       w.VelY,
+		Ground:     game.HeightAt(w.Pos[0], w.Pos[2], w.Ground),
+		LaserBeams: []beam{},
+	}
+	for _, b := range w.LaserBeams {
+		s.LaserBeams = append(s.LaserBeams, beam{Start: b.Start, End: b.End, Life: b.Life})
+	}
+	return s
+}
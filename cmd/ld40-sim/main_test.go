@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/ld40/game"
+)
+
+func TestParseScript(t *testing.T) {
+	inputs, err := parseScript(strings.NewReader(`
+# comment
+2 w+run
+1 - 5 -3
+2 jump+shoot
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []game.Input{
+		{Forward: true, Run: true},
+		{Forward: true, Run: true},
+		{MouseDx: 5, MouseDy: -3},
+		{Jump: true, Shoot: true},
+		{},
+	}
+	if len(inputs) != len(want) {
+		t.Fatalf("want %d inputs but have %d", len(want), len(inputs))
+	}
+	for i := range want {
+		if inputs[i] != want[i] {
+			t.Errorf("input %d: want %+v but have %+v", i, want[i], inputs[i])
+		}
+	}
+}
+
+func TestParseScriptReportsLineOfError(t *testing.T) {
+	_, err := parseScript(strings.NewReader("1 w\n1 jmp\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("want error for line 2 but have %v", err)
+	}
+}
+
+func TestRunIsDeterministic(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "script.txt")
+	err := os.WriteFile(script, []byte("60 w+run 3 0\n1 w+jump\n20 w+shoot 0 -2\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	simulate := func() []byte {
+		var buf bytes.Buffer
+		if err := run("../../level.json", script, 0, 60, &buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	a, b := simulate(), simulate()
+	if !bytes.Equal(a, b) {
+		t.Fatalf("two runs differ:\n%s\n%s", a, b)
+	}
+	var s state
+	if err := json.Unmarshal(a, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Ticks != 81 {
+		t.Errorf("want 81 ticks but have %d", s.Ticks)
+	}
+	if !s.InAir {
+		t.Error("player should still be in the air after the jump")
+	}
+}
+
+func TestRunRejectsInvalidCounts(t *testing.T) {
+	for _, test := range []struct {
+		ticks, tickRate int
+	}{
+		{-1, 60},
+		{10, 0},
+	} {
+		err := run("../../heights.png", "", test.ticks, test.tickRate, io.Discard)
+		if err == nil {
+			t.Errorf("ticks %d at rate %d should be an error", test.ticks, test.tickRate)
+		}
+	}
+}
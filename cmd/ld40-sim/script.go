@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gonutz/ld40/game"
+)
+
+/*
+parseScript reads an input script with one entry per line:
+
+	<ticks> <keys> [<mouseDx> <mouseDy>]
+
+<ticks> is how many simulation ticks the entry lasts. <keys> is a list of the
+keys held down during these ticks, separated by '+', or '-' for no keys. Valid
+keys are w, a, s, d, jump, run, sneak and shoot. Like in the game, jump and
+shoot are a single key press, they only happen in the entry's first tick. The
+mouse deltas are applied in every one of the ticks. Empty lines and lines
+starting with # are ignored.
+
+Example:
+
+	# walk forward for one second, then jump while running
+	60 w
+	1  w+run+jump
+	30 w+run
+	10 - 8 0
+*/
+func parseScript(r io.Reader) ([]game.Input, error) {
+	var inputs []game.Input
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fail := func(format string, a ...interface{}) error {
+			return fmt.Errorf("script line %d: "+format, append([]interface{}{lineNumber}, a...)...)
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 && len(fields) != 4 {
+			return nil, fail("want 2 or 4 fields but have %d", len(fields))
+		}
+		ticks, err := strconv.Atoi(fields[0])
+		if err != nil || ticks < 0 {
+			return nil, fail("invalid tick count '%s'", fields[0])
+		}
+		var in game.Input
+		if fields[1] != "-" {
+			for _, key := range strings.Split(fields[1], "+") {
+				switch strings.ToLower(key) {
+				case "w":
+					in.Forward = true
+				case "s":
+					in.Backward = true
+				case "a":
+					in.Left = true
+				case "d":
+					in.Right = true
+				case "jump":
+					in.Jump = true
+				case "run":
+					in.Run = true
+				case "sneak":
+					in.Sneak = true
+				case "shoot":
+					in.Shoot = true
+				default:
+					return nil, fail("unknown key '%s'", key)
+				}
+			}
+		}
+		if len(fields) == 4 {
+			in.MouseDx, err = strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fail("invalid mouse dx '%s'", fields[2])
+			}
+			in.MouseDy, err = strconv.Atoi(fields[3])
+			if err != nil {
+				return nil, fail("invalid mouse dy '%s'", fields[3])
+			}
+		}
+		for i := 0; i < ticks; i++ {
+			inputs = append(inputs, in)
+			in.Jump, in.Shoot = false, false
+		}
+	}
+	return inputs, scanner.Err()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/gonutz/d3d9"
+)
+
+// deviceState is what restoreDevice did.
+type deviceState int
+
+const (
+	// deviceLost means that the device cannot be reset yet, e.g. because
+	// the fullscreen window is minimized.
+	deviceLost deviceState = iota
+	// deviceReset means that the device was lost and was just reset, the
+	// render states have to be set again.
+	deviceReset
+)
+
+// restoreDevice is called every frame while the device is lost. Once
+// TestCooperativeLevel reports that it can be reset, the resources in the
+// default pool are released, the device is reset and they are created again.
+func restoreDevice(device *d3d9.Device, params d3d9.PRESENT_PARAMETERS, res *resources) deviceState {
+	err := device.TestCooperativeLevel()
+	if err == nil {
+		// the device came back by itself
+		return deviceReset
+	}
+	switch err.Code() {
+	case d3d9.ERR_DEVICELOST:
+		return deviceLost
+	case d3d9.ERR_DEVICENOTRESET:
+		res.releaseLost()
+		if _, err := device.Reset(params); err != nil {
+			if err.Code() == d3d9.ERR_DEVICELOST {
+				// lost again while resetting, try again next frame
+				return deviceLost
+			}
+			check(err)
+		}
+		check(res.restoreLost())
+		return deviceReset
+	}
+	check(err)
+	return deviceLost
+}
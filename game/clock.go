@@ -0,0 +1,44 @@
+package game
+
+import "time"
+
+// TimeStep is the fixed duration of one simulation step.
+const TimeStep = time.Second / 60
+
+// maxFrameTime limits how much time is simulated in one frame. After a long
+// hitch (window dragged, debugger break) we rather slow down the game than try
+// to catch up with hundreds of steps.
+const maxFrameTime = time.Second / 4
+
+// Clock decouples the simulation from the frame rate. Each frame the elapsed
+// real time is put into an accumulator which is then used up in steps of
+// TimeStep. What is left over tells how far the rendering is between the last
+// and the next step.
+type Clock struct {
+	accumulator time.Duration
+}
+
+// Advance adds the time elapsed since the last frame and returns how many
+// simulation steps to run now.
+func (c *Clock) Advance(elapsed time.Duration) int {
+	if elapsed > maxFrameTime {
+		elapsed = maxFrameTime
+	}
+	if elapsed > 0 {
+		c.accumulator += elapsed
+	}
+	steps := int(c.accumulator / TimeStep)
+	c.accumulator -= time.Duration(steps) * TimeStep
+	return steps
+}
+
+// Dt is the duration of one step in seconds, to be passed to World.Step.
+func (c *Clock) Dt() float32 {
+	return float32(TimeStep.Seconds())
+}
+
+// Alpha is the fraction of a step that has elapsed since the last step, in the
+// range [0..1). Use it to interpolate between the previous and current state.
+func (c *Clock) Alpha() float32 {
+	return float32(c.accumulator) / float32(TimeStep)
+}
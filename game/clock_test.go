@@ -0,0 +1,58 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockStepsAtFixedRate(t *testing.T) {
+	var c Clock
+	if n := c.Advance(TimeStep / 2); n != 0 {
+		t.Errorf("half a step: want 0 steps but have %d", n)
+	}
+	if a := c.Alpha(); abs(a-0.5) > 0.001 {
+		t.Errorf("want alpha 0.5 but have %v", a)
+	}
+	if n := c.Advance(2 * TimeStep); n != 2 {
+		t.Errorf("want 2 steps but have %d", n)
+	}
+	if n := c.Advance(time.Hour); n != int(maxFrameTime/TimeStep) {
+		t.Errorf("long frames must be capped, want %d steps but have %d",
+			int(maxFrameTime/TimeStep), n)
+	}
+}
+
+func TestMovementIsIndependentOfFrameRate(t *testing.T) {
+	// simulate one second of running forward and jumping at different frame
+	// rates, the rendered position must always be the same
+	simulate := func(fps int) (pos [3]float32) {
+		w := NewWorld(flatGround(20, 0))
+		var c Controls
+		c.Handle(Event{Kind: KeyDown, Key: KeyForward})
+		c.Handle(Event{Kind: KeyDown, Key: KeyRun})
+		c.Handle(Event{Kind: KeyDown, Key: KeyJump})
+		var clock Clock
+		for frame := 0; frame < fps; frame++ {
+			for n := clock.Advance(time.Second / time.Duration(fps)); n > 0; n-- {
+				w.Step(c.NextInput(), clock.Dt())
+			}
+		}
+		p, _ := w.Interpolated(clock.Alpha())
+		return p
+	}
+
+	want := simulate(60)
+	if want[2] < 3 {
+		t.Fatalf("player should have run forward but is at %v", want)
+	}
+	for _, fps := range []int{30, 144} {
+		have := simulate(fps)
+		for i := range have {
+			if abs(have[i]-want[i]) > 0.001 {
+				t.Errorf("%d fps: want position %v like at 60 fps but have %v",
+					fps, want, have)
+				break
+			}
+		}
+	}
+}
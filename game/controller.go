@@ -0,0 +1,181 @@
+package game
+
+import (
+	"math"
+
+	"github.com/gonutz/d3dmath"
+)
+
+// EdgeMode tells what happens when the player reaches the border of the height
+// field.
+type EdgeMode int
+
+const (
+	// EdgeWall keeps the player on the map with invisible walls.
+	EdgeWall EdgeMode = iota
+	// EdgeFall lets the player walk off the map and fall down.
+	EdgeFall
+)
+
+// Controller moves the player's capsule over the height field. The capsule
+// stands upright with its feet at the player position. Only its bottom half
+// sphere can touch the terrain, height fields have no overhangs that the top
+// could bump into.
+type Controller struct {
+	// Radius is the capsule's radius, it must be greater than 0.
+	Radius float32
+	// MaxSlope is the steepest slope, in degrees, that the player can walk up
+	// and stand on.
+	MaxSlope float32
+	// StepHeight is how high a steeper ledge may be to still step onto it.
+	StepHeight float32
+	// SlideSpeed is how fast the player slides down slopes that are too steep
+	// to stand on, in units per second.
+	SlideSpeed float32
+	Edges      EdgeMode
+}
+
+// capsuleSamples are the points on the terrain that are tested against the
+// capsule's bottom sphere, relative to its center and in units of its radius.
+// The third component is how high the sphere's surface is above its lowest
+// point at that distance from the center, also in units of the radius.
+var capsuleSamples = func() [][3]float32 {
+	samples := [][3]float32{{0, 0, 0}}
+	for _, r := range []float32{0.5, 1} {
+		lift := 1 - float32(math.Sqrt(float64(1-r*r)))
+		for i := 0; i < 8; i++ {
+			a := float64(i) * math.Pi / 4
+			samples = append(samples, [3]float32{
+				r * float32(math.Cos(a)),
+				r * float32(math.Sin(a)),
+				lift,
+			})
+		}
+	}
+	return samples
+}()
+
+// Ground returns the height that the capsule's feet rest at when it stands at
+// x,z and the normal of the terrain that carries it. It is ok if the capsule
+// touches the height field at all, only with EdgeFall can it be off the map.
+func (c Controller) Ground(x, z float32, h HeightField) (y float32, normal d3dmath.Vec3, ok bool) {
+	y, normal, _, ok = c.contact(x, z, h)
+	return
+}
+
+// contact is like Ground but also returns the point where the capsule's bottom
+// sphere touches the terrain.
+func (c Controller) contact(x, z float32, h HeightField) (y float32, normal, point d3dmath.Vec3, ok bool) {
+	for _, s := range capsuleSamples {
+		sx, sz := x+s[0]*c.Radius, z+s[1]*c.Radius
+		sy, n, inside := SurfaceAt(sx, sz, h)
+		if !inside {
+			continue
+		}
+		if feet := sy - s[2]*c.Radius; !ok || feet > y {
+			y, normal, point, ok = feet, n, d3dmath.Vec3{sx, sy, sz}, true
+		}
+	}
+	return
+}
+
+// Walkable tells whether the player can stand on terrain with this normal.
+func (c Controller) Walkable(normal d3dmath.Vec3) bool {
+	return normal[1] >= float32(math.Cos(float64(deg2rad(c.MaxSlope))))
+}
+
+// Slide returns the horizontal movement of a player standing at pos for dt
+// seconds due to sliding down terrain that is too steep to stand on.
+func (c Controller) Slide(pos d3dmath.Vec3, dt float32, h HeightField) d3dmath.Vec3 {
+	_, n, ok := c.Ground(pos[0], pos[2], h)
+	if !ok || c.Walkable(n) {
+		return d3dmath.Vec3{}
+	}
+	downhill := d3dmath.Vec3{n[0], 0, n[2]}.Normalized()
+	return downhill.MulScalar(c.SlideSpeed * dt)
+}
+
+// Move returns where the capsule ends up when it moves horizontally by delta
+// from pos. Terrain that is steeper than MaxSlope and rises more than
+// StepHeight blocks it, as do the map's edges with EdgeWall. The capsule then
+// slides along them instead. The returned position keeps the height of pos,
+// see Ground for putting it on the terrain.
+func (c Controller) Move(pos, delta d3dmath.Vec3, h HeightField) d3dmath.Vec3 {
+	delta[1] = 0
+	// first try the whole move, if it is blocked try sliding along the slope
+	for try := 0; try < 2; try++ {
+		target := c.keepOnMap(pos.Add(delta), h)
+		away, blocked := c.blocked(pos, target, h)
+		if !blocked {
+			return target
+		}
+		into := delta.Dot(away)
+		if away.Norm() == 0 || into >= 0 {
+			break
+		}
+		delta = delta.Sub(away.MulScalar(into))
+	}
+	return c.keepOnMap(pos, h)
+}
+
+// blocked tells whether the capsule cannot go from pos to target. That is the
+// case if the way there is too steep, either because the terrain below target
+// is or because the capsule would have to climb too fast, and the steep part
+// rises more than a step. The returned horizontal unit vector points away from
+// the obstacle, it is 0 if there is no sensible direction to slide along.
+func (c Controller) blocked(pos, target d3dmath.Vec3, h HeightField) (away d3dmath.Vec3, blocked bool) {
+	y, _, point, ok := c.contact(target[0], target[2], h)
+	if !ok {
+		return d3dmath.Vec3{}, false
+	}
+	ahead := target.Sub(pos)
+	ahead[1] = 0
+	maxRise := ahead.Norm() * float32(math.Tan(float64(deg2rad(c.MaxSlope))))
+	_, n, _ := SurfaceAt(target[0], target[2], h)
+	if c.Walkable(n) && y-pos[1] <= maxRise+1e-5 {
+		return d3dmath.Vec3{}, false
+	}
+
+	// look one radius further to see how high the steep part goes
+	probe := target
+	if ahead.Norm() > 0 {
+		probe = target.Add(ahead.Normalized().MulScalar(c.Radius))
+	}
+	if top, _, ok := c.Ground(probe[0], probe[2], h); ok && top-pos[1] <= c.StepHeight {
+		return d3dmath.Vec3{}, false
+	}
+
+	if !c.Walkable(n) {
+		away = d3dmath.Vec3{n[0], 0, n[2]}
+	} else {
+		// the capsule bumps into something, e.g. the edge of a ledge
+		away = target.Sub(point)
+		away[1] = 0
+	}
+	if away.Norm() == 0 {
+		return d3dmath.Vec3{}, true
+	}
+	return away.Normalized(), true
+}
+
+// keepOnMap moves p back onto the map, far enough from the edges for the whole
+// capsule to be on it, if the edges are walls.
+func (c Controller) keepOnMap(p d3dmath.Vec3, h HeightField) d3dmath.Vec3 {
+	if c.Edges != EdgeWall {
+		return p
+	}
+	dx, _, dz := h.Offset()
+	p[0] = clamp(p[0], dx*h.Scale[0]+c.Radius, (dx+float32(h.CellsX()))*h.Scale[0]-c.Radius)
+	p[2] = clamp(p[2], dz*h.Scale[2]+c.Radius, (dz+float32(h.CellsZ()))*h.Scale[2]-c.Radius)
+	return p
+}
+
+func clamp(x, min, max float32) float32 {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
@@ -0,0 +1,156 @@
+package game
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+)
+
+// slope is a ramp along x with the given steepness in degrees. It has
+// cells*cells cells of size 0.1 and starts rising at x = 0.
+func slope(cells int, degrees float32) HeightField {
+	h := flatGround(cells, 0)
+	h.Scale = d3dmath.Vec3{0.1, 0.1, 0.1}
+	rise := float32(math.Tan(float64(deg2rad(degrees))))
+	for i := range h.Heights {
+		for j := range h.Heights[i] {
+			if j > cells/2 {
+				h.Heights[i][j] = float32(j-cells/2) * rise
+			}
+		}
+	}
+	return h
+}
+
+func walk(w *World, in Input, steps int) {
+	for i := 0; i < steps; i++ {
+		w.Step(in, 1.0/60)
+	}
+}
+
+func TestGroundUnderCapsule(t *testing.T) {
+	c := Controller{Radius: 0.1}
+	y, n, ok := c.Ground(0, 0, flatGround(4, 0.5))
+	if !ok || y != 0.5 || !vecNear(n, d3dmath.Vec3{0, 1, 0}) {
+		t.Errorf("want flat ground at 0.5 but have %v, %v, %v", y, n, ok)
+	}
+
+	// a thin spike right next to the center holds the capsule up
+	h := flatGround(40, 0)
+	h.Scale = d3dmath.Vec3{0.01, 1, 0.01}
+	h.Heights[20][25] = 1
+	y, _, _ = c.Ground(0, 0, h)
+	if y < 0.9 || y > 1 {
+		t.Errorf("capsule should rest on the spike but is at %v", y)
+	}
+
+	c.Edges = EdgeFall
+	if _, _, ok := c.Ground(2.05, 0, flatGround(4, 0)); !ok {
+		t.Error("capsule overlapping the map's edge should still stand on it")
+	}
+	if _, _, ok := c.Ground(2.2, 0, flatGround(4, 0)); ok {
+		t.Error("capsule next to the map should not be on the ground")
+	}
+}
+
+func TestWalkUpGentleSlope(t *testing.T) {
+	w := NewWorld(slope(40, 30))
+	w.SetViewDir(d3dmath.Vec3{1, 0, 0})
+	walk(w, Input{Forward: true}, 60)
+	if w.Pos[0] < 1.5 {
+		t.Errorf("player should have walked up the slope but is at %v", w.Pos)
+	}
+	// on a slope, the capsule's bottom sphere rests a bit above the point
+	// below its center
+	lift := w.Controller.Radius * (1/float32(math.Cos(math.Pi/6)) - 1)
+	if want := HeightAt(w.Pos[0], w.Pos[2], w.Ground) + lift; abs(w.Pos[1]-want) > 0.002 {
+		t.Errorf("player should be on the slope at %v but is at %v", want, w.Pos[1])
+	}
+}
+
+func TestSteepSlopeBlocks(t *testing.T) {
+	w := NewWorld(slope(40, 70))
+	w.Pos = d3dmath.Vec3{-1, 0, 0}
+	w.SetViewDir(d3dmath.Vec3{1, 0, 0})
+	walk(w, Input{Forward: true}, 120)
+	if w.Pos[0] > 0.05 || w.Pos[1] > w.Controller.StepHeight {
+		t.Errorf("player should be stopped at the foot of the slope but is at %v", w.Pos)
+	}
+}
+
+func TestSlideAlongSteepSlope(t *testing.T) {
+	w := NewWorld(slope(40, 70))
+	w.Pos = d3dmath.Vec3{-0.5, 0, 0}
+	w.SetViewDir(d3dmath.Vec3{1, 0, 1}.Normalized())
+	walk(w, Input{Forward: true}, 30)
+	if w.Pos[0] > 0.05 {
+		t.Errorf("player should not climb the slope but is at %v", w.Pos)
+	}
+	// half a second of walking diagonally, sliding along the slope in z
+	if w.Pos[2] < 0.4 {
+		t.Errorf("player should slide along the slope but is at %v", w.Pos)
+	}
+}
+
+func TestSlideDownSteepSlope(t *testing.T) {
+	w := NewWorld(slope(40, 60))
+	w.Pos = d3dmath.Vec3{1, 2, 0}
+	walk(w, Input{}, 60)
+	if w.Pos[0] > 0.1 {
+		t.Errorf("player should have slid down the slope but is at %v", w.Pos)
+	}
+	if w.InAir() || w.Pos[1] > w.Controller.StepHeight {
+		t.Errorf("player should stand at the foot of the slope but is at %v", w.Pos)
+	}
+}
+
+func TestStepUp(t *testing.T) {
+	ledge := func(height float32) HeightField {
+		h := flatGround(100, 0)
+		h.Scale = d3dmath.Vec3{0.01, 1, 0.01}
+		for i := range h.Heights {
+			for j := 75; j < len(h.Heights[i]); j++ {
+				h.Heights[i][j] = height
+			}
+		}
+		return h
+	}
+
+	w := NewWorld(ledge(0.05))
+	w.SetViewDir(d3dmath.Vec3{1, 0, 0})
+	walk(w, Input{Forward: true}, 20)
+	if w.Pos[0] < 0.3 || w.Pos[1] != 0.05 {
+		t.Errorf("player should have stepped onto the low ledge but is at %v", w.Pos)
+	}
+
+	w = NewWorld(ledge(0.2))
+	w.SetViewDir(d3dmath.Vec3{1, 0, 0})
+	walk(w, Input{Forward: true}, 20)
+	if w.Pos[0] > 0.25 || w.Pos[1] > 0.01 {
+		t.Errorf("player should be stopped by the high ledge but is at %v", w.Pos)
+	}
+}
+
+func TestMapEdges(t *testing.T) {
+	w := NewWorld(flatGround(4, 0))
+	w.SetViewDir(d3dmath.Vec3{1, 0, 1}.Normalized())
+	walk(w, Input{Forward: true}, 300)
+	max := 2 - w.Controller.Radius
+	if abs(w.Pos[0]-max) > 1e-4 || abs(w.Pos[2]-max) > 1e-4 || w.InAir() {
+		t.Errorf("walls should keep the player at %v,%v but it is at %v", max, max, w.Pos)
+	}
+
+	w = NewWorld(flatGround(4, 0))
+	w.Spawn = d3dmath.Vec3{0.5, 0, 0.5}
+	w.Controller.Edges = EdgeFall
+	w.SetViewDir(d3dmath.Vec3{1, 0, 0})
+	walk(w, Input{Forward: true}, 90)
+	if !w.InAir() || w.Pos[0] <= 2 || w.Pos[1] >= 0 {
+		t.Fatalf("player should fall off the map but is at %v", w.Pos)
+	}
+	walk(w, Input{}, 150)
+	if w.InAir() || w.Pos != w.Spawn {
+		t.Errorf("player should be back at the spawn point but is at %v", w.Pos)
+	}
+}
@@ -0,0 +1,115 @@
+package game
+
+// Key is a game control, independent of which physical key or button it is
+// bound to.
+type Key uint8
+
+const (
+	KeyForward Key = iota
+	KeyBackward
+	KeyLeft
+	KeyRight
+	KeyRun
+	KeySneak
+	KeyJump
+	KeyShoot
+	KeyCount
+)
+
+// Axis is an analog game control, e.g. a gamepad stick's direction.
+type Axis uint8
+
+const (
+	// AxisForward is walking forward (positive) or backward (negative), from
+	// -1 to 1.
+	AxisForward Axis = iota
+	// AxisRight is walking right (positive) or left (negative), from -1 to 1.
+	AxisRight
+	// AxisTurnX and AxisTurnY turn the view in every step, like moving the
+	// mouse by that many pixels.
+	AxisTurnX
+	AxisTurnY
+	AxisCount
+)
+
+type EventKind uint8
+
+const (
+	KeyDown EventKind = iota + 1
+	KeyUp
+	MouseMove
+	AxisMove
+	MouseDelta
+)
+
+// Event is a change of the player's controls, as reported by the window.
+type Event struct {
+	Kind EventKind
+	Key  Key // for KeyDown and KeyUp
+	// X and Y are the mouse cursor position relative to the screen center, for
+	// MouseMove events. For MouseDelta events, they are the relative mouse
+	// motion, e.g. from raw input, and add up until the next step.
+	X, Y int
+	// Axis is set to Value for AxisMove events. It keeps that value until the
+	// next AxisMove for the same Axis.
+	Axis  Axis
+	Value float32
+}
+
+// Controls keeps track of which keys are down, where the mouse is and how far
+// the analog controls are moved. Events are handled as they come in and once
+// per step the Input is taken out.
+type Controls struct {
+	down           [KeyCount]bool
+	mouseX, mouseY int
+	axes           [AxisCount]float32
+}
+
+func (c *Controls) Handle(e Event) {
+	switch e.Kind {
+	case KeyDown:
+		if e.Key < KeyCount {
+			c.down[e.Key] = true
+		}
+	case KeyUp:
+		if e.Key < KeyCount {
+			c.down[e.Key] = false
+		}
+	case MouseMove:
+		c.mouseX, c.mouseY = e.X, e.Y
+	case MouseDelta:
+		c.mouseX += e.X
+		c.mouseY += e.Y
+	case AxisMove:
+		if e.Axis < AxisCount {
+			c.axes[e.Axis] = e.Value
+		}
+	}
+}
+
+// NextInput returns the Input for the next simulation step. Jumping and
+// shooting only happen once per key press so these keys are released
+// afterwards. The mouse is assumed to be put back at the screen center.
+func (c *Controls) NextInput() Input {
+	in := Input{
+		Forward:  c.down[KeyForward],
+		Backward: c.down[KeyBackward],
+		Left:     c.down[KeyLeft],
+		Right:    c.down[KeyRight],
+		Run:      c.down[KeyRun],
+		Sneak:    c.down[KeySneak],
+		Jump:     c.down[KeyJump],
+		Shoot:    c.down[KeyShoot],
+		MouseDx:  c.mouseX,
+		MouseDy:  c.mouseY,
+
+		MoveForward: c.axes[AxisForward],
+		MoveRight:   c.axes[AxisRight],
+		TurnX:       c.axes[AxisTurnX],
+		TurnY:       c.axes[AxisTurnY],
+	}
+	c.down[KeyJump] = false
+	c.down[KeyShoot] = false
+	c.mouseX, c.mouseY = 0, 0
+	return in
+}
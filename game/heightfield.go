@@ -0,0 +1,206 @@
+package game
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/geometry"
+)
+
+// LoadHeightField reads a height map, the format is chosen by the file name's
+// extension: PNG images (.png) or headerless 16 bit little-endian RAW files
+// (.raw, .r16) as exported by terrain tools. RAW files are expected to be
+// square, use HeightFieldFromRaw for other sizes.
+func LoadHeightField(r io.Reader, name string) (HeightField, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png":
+		img, err := png.Decode(r)
+		if err != nil {
+			return HeightField{}, fmt.Errorf("height field %s: %v", name, err)
+		}
+		return HeightFieldFromImage(img)
+	case ".raw", ".r16":
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return HeightField{}, fmt.Errorf("height field %s: %v", name, err)
+		}
+		return HeightFieldFromRaw(data, 0)
+	default:
+		return HeightField{}, fmt.Errorf("height field %s: unknown file type", name)
+	}
+}
+
+// HeightFieldFromImage creates a height field from a black and white image.
+// Only the red channel is used, mid-gray (127 in 8 bit images) is ground level.
+// 16 bit images, e.g. image.Gray16, keep their full precision.
+func HeightFieldFromImage(img image.Image) (HeightField, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if err := checkHeightFieldSize(w, h); err != nil {
+		return HeightField{}, err
+	}
+	heights := make([]float32, 0, w*h)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, _, _, _ := img.At(x, y).RGBA()
+			heights = append(heights, height16(uint16(r)))
+		}
+	}
+	return heightFieldFromSamples(heights, w, h), nil
+}
+
+// HeightFieldFromRaw creates a height field from headerless 16 bit
+// little-endian samples, row by row. If width is 0 the data must be square.
+func HeightFieldFromRaw(data []byte, width int) (HeightField, error) {
+	if len(data)%2 != 0 {
+		return HeightField{}, errors.New("height field: RAW data has an odd number of bytes")
+	}
+	n := len(data) / 2
+	if width == 0 {
+		width = int(math.Sqrt(float64(n)) + 0.5)
+		if width*width != n {
+			return HeightField{}, fmt.Errorf("height field: %d RAW samples are not square, the width must be given", n)
+		}
+	}
+	if width < 0 || n%width != 0 {
+		return HeightField{}, fmt.Errorf("height field: %d RAW samples do not fit width %d", n, width)
+	}
+	height := n / width
+	if err := checkHeightFieldSize(width, height); err != nil {
+		return HeightField{}, err
+	}
+	heights := make([]float32, n)
+	for i := range heights {
+		heights[i] = height16(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+	return heightFieldFromSamples(heights, width, height), nil
+}
+
+func checkHeightFieldSize(w, h int) error {
+	if w < 2 || h < 2 {
+		return fmt.Errorf("height field must be at least 2x2 samples but is %dx%d", w, h)
+	}
+	return nil
+}
+
+// height16 maps a 16 bit sample to a height. The scale is chosen so that 8 bit
+// values v (which become v*257 in 16 bit) map to (v-127)/127.
+func height16(v uint16) float32 {
+	return (float32(v)/257 - 127) / 127
+}
+
+// heightFieldFromSamples slices the linear array of w*h samples into rows.
+func heightFieldFromSamples(heights []float32, w, h int) HeightField {
+	var field HeightField
+	field.Heights = make([][]float32, h)
+	for i := range field.Heights {
+		field.Heights[i] = heights[i*w : (i+1)*w]
+	}
+	return field
+}
+
+// HeightField is a grid of height samples. Heights[0] is the row with the
+// highest z-coordinate, each row goes from low to high x.
+type HeightField struct {
+	Heights [][]float32
+	Scale   d3dmath.Vec3 // the height field is first offset, then scaled
+}
+
+// CellsX is the number of grid cells along the x-axis.
+func (h HeightField) CellsX() int {
+	return len(h.Heights[0]) - 1
+}
+
+// CellsZ is the number of grid cells along the z-axis.
+func (h HeightField) CellsZ() int {
+	return len(h.Heights) - 1
+}
+
+// Offset centers the height field around the origin.
+func (h HeightField) Offset() (x, y, z float32) {
+	x = -float32(h.CellsX()) / 2
+	z = -float32(h.CellsZ()) / 2
+	return
+}
+
+func (h HeightField) ModelTransform() d3dmath.Mat4 {
+	return d3dmath.Mul4(
+		d3dmath.Translate(h.Offset()),
+		d3dmath.ScaleV(h.Scale),
+	)
+}
+
+// HeightAt returns the terrain height at world position x,z. Outside of the
+// height field the height is 0.
+func HeightAt(x, z float32, h HeightField) float32 {
+	y, _, _ := SurfaceAt(x, z, h)
+	return y
+}
+
+// SurfaceAt returns the terrain height and the unit normal, pointing up, at
+// world position x,z. Outside of the height field ok is false and the height
+// is 0.
+func SurfaceAt(x, z float32, h HeightField) (y float32, normal d3dmath.Vec3, ok bool) {
+	x /= h.Scale[0]
+	z /= h.Scale[2]
+	dx, _, dz := h.Offset()
+	x -= dx
+	z -= dz
+	if x < 0 || z < 0 || x >= float32(h.CellsX()) || z >= float32(h.CellsZ()) {
+		return 0, d3dmath.Vec3{0, 1, 0}, false
+	}
+	/* at this point x,z are in tile coordinates
+	        z
+	        ^
+	        |
+	        |
+	   03 13|23 33
+	   02 12|22 32
+	--------+----------> x
+	   01 11|21 31
+	   00 10|20 30
+	        |
+	        |
+	*/
+	ix, iz := int(x), int(z)
+	fx, fz := x-float32(ix), z-float32(iz)
+	onLeftTriangle := 1.0-fx > fz
+
+	heightBottomLeft := h.Heights[h.CellsZ()-iz][ix]
+	heightTopLeft := h.Heights[h.CellsZ()-iz-1][ix]
+	heightBottomRight := h.Heights[h.CellsZ()-iz][ix+1]
+	heightTopRight := h.Heights[h.CellsZ()-iz-1][ix+1]
+	triangle := geometry.Triangle{
+		d3dmath.Vec3{1, heightBottomRight, 0},
+		d3dmath.Vec3{0, heightTopLeft, 1},
+	}
+	if onLeftTriangle {
+		triangle[2] = d3dmath.Vec3{0, heightBottomLeft, 0}
+	} else {
+		triangle[2] = d3dmath.Vec3{1, heightTopRight, 1}
+	}
+	// the terrain's triangles are never vertical, so the vertical line always
+	// intersects them
+	plane, _ := triangle.Plane()
+	y, _ = geometry.LinePlane(d3dmath.Vec3{fx, 0, fz}, d3dmath.Vec3{0, 1, 0}, plane)
+	// like in CastRay, the inverse transpose of the scaling brings the normal
+	// from tile to world coordinates
+	normal = d3dmath.Vec3{
+		plane.Normal[0] / h.Scale[0],
+		plane.Normal[1] / h.Scale[1],
+		plane.Normal[2] / h.Scale[2],
+	}.Normalized()
+	if normal[1] < 0 {
+		normal = normal.MulScalar(-1)
+	}
+	return y * h.Scale[1], normal, true
+}
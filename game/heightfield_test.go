@@ -0,0 +1,125 @@
+package game
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+)
+
+func TestEightBitImagesMapRedChannelAroundGray(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{R: 127, A: 255})
+	img.Set(1, 0, color.RGBA{R: 254, A: 255})
+	img.Set(0, 1, color.RGBA{R: 0, G: 255, B: 255, A: 255})
+	img.Set(1, 1, color.RGBA{R: 127, A: 255})
+	h, err := HeightFieldFromImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]float32{{0, 1}, {-1, 0}}
+	for y := range want {
+		for x := range want[y] {
+			if abs(h.Heights[y][x]-want[y][x]) > 1e-6 {
+				t.Errorf("%d,%d: want %v but have %v", x, y, want[y][x], h.Heights[y][x])
+			}
+		}
+	}
+}
+
+func TestSixteenBitImagesKeepPrecision(t *testing.T) {
+	img := image.NewGray16(image.Rect(0, 0, 2, 2))
+	img.SetGray16(0, 0, color.Gray16{Y: 127 * 257})
+	img.SetGray16(1, 0, color.Gray16{Y: 127*257 + 1})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	h, err := LoadHeightField(&buf, "terrain.PNG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Heights[0][0] != 0 {
+		t.Errorf("want ground level but have %v", h.Heights[0][0])
+	}
+	if h.Heights[0][1] <= 0 || h.Heights[0][1] > 0.0001 {
+		t.Errorf("want a tiny height above ground but have %v", h.Heights[0][1])
+	}
+}
+
+func TestRawFiles(t *testing.T) {
+	raw := func(samples ...uint16) []byte {
+		b := make([]byte, 2*len(samples))
+		for i, s := range samples {
+			binary.LittleEndian.PutUint16(b[2*i:], s)
+		}
+		return b
+	}
+
+	h, err := LoadHeightField(bytes.NewReader(raw(0, 127*257, 254*257, 0)), "map.r16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.CellsX() != 1 || h.CellsZ() != 1 {
+		t.Fatalf("want 1x1 cells but have %dx%d", h.CellsX(), h.CellsZ())
+	}
+	if h.Heights[0][1] != 0 || h.Heights[1][0] != 1 {
+		t.Errorf("unexpected heights %v", h.Heights)
+	}
+
+	h, err = HeightFieldFromRaw(raw(1, 2, 3, 4, 5, 6), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.CellsX() != 2 || h.CellsZ() != 1 {
+		t.Errorf("want 2x1 cells but have %dx%d", h.CellsX(), h.CellsZ())
+	}
+
+	if _, err := HeightFieldFromRaw(raw(1, 2, 3, 4, 5, 6), 0); err == nil {
+		t.Error("non-square RAW data without width must be an error")
+	}
+	if _, err := HeightFieldFromRaw([]byte{1, 2, 3}, 0); err == nil {
+		t.Error("odd number of bytes must be an error")
+	}
+}
+
+func TestInvalidHeightFieldsAreErrors(t *testing.T) {
+	if _, err := HeightFieldFromImage(image.NewGray(image.Rect(0, 0, 1, 5))); err == nil {
+		t.Error("1 pixel wide image must be an error")
+	}
+	if _, err := LoadHeightField(strings.NewReader("not a PNG"), "a.png"); err == nil {
+		t.Error("invalid PNG must be an error")
+	}
+	if _, err := LoadHeightField(strings.NewReader(""), "a.bmp"); err == nil {
+		t.Error("unknown file type must be an error")
+	}
+}
+
+func TestHeightAtOnRectangularField(t *testing.T) {
+	// 4 cells along x, 2 cells along z, the height rises with x
+	h := HeightField{
+		Heights: [][]float32{
+			{0, 1, 2, 3, 4},
+			{0, 1, 2, 3, 4},
+			{0, 1, 2, 3, 4},
+		},
+		Scale: d3dmath.Vec3{1, 1, 1},
+	}
+	for _, test := range []struct{ x, z, want float32 }{
+		{-2, -1, 0},
+		{0, 0, 2},
+		{1.5, 0.5, 3.5},
+		{-1.25, 0.9, 0.75},
+		{2, 0, 0}, // outside
+		{0, 1, 0}, // outside
+	} {
+		if have := HeightAt(test.x, test.z, h); abs(have-test.want) > 1e-5 {
+			t.Errorf("%v,%v: want %v but have %v", test.x, test.z, test.want, have)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package game
+
+import "github.com/gonutz/d3dmath"
+
+// JumpState tells whether the player stands on the ground or flies through
+// the air.
+type JumpState int
+
+const (
+	// Grounded means the player stands on the terrain and follows it.
+	Grounded JumpState = iota
+	// Airborne means the player jumped or fell off something and is pulled
+	// down by gravity.
+	Airborne
+	// Landing is the state for the one step in which the player touched down,
+	// see World.ImpactSpeed. Otherwise it behaves like Grounded.
+	Landing
+)
+
+func (s JumpState) String() string {
+	switch s {
+	case Grounded:
+		return "grounded"
+	case Airborne:
+		return "airborne"
+	case Landing:
+		return "landing"
+	}
+	return "unknown jump state"
+}
+
+// InAir tells whether the player is airborne.
+func (w *World) InAir() bool {
+	return w.State == Airborne
+}
+
+// startJump handles the jump key. A jump can start on the ground, or shortly
+// after walking off an edge (see CoyoteTime). Jumps that are pressed too early,
+// while still in the air, are remembered for JumpBuffer seconds so they
+// happen when landing.
+func (w *World) startJump(in Input, dt float32) {
+	if in.Jump {
+		w.jumpBuffered = w.JumpBuffer
+		if w.jumpBuffered < dt {
+			// a press must last at least for this step
+			w.jumpBuffered = dt
+		}
+	}
+	canJump := w.State != Airborne || (!w.jumped && w.airTime <= w.CoyoteTime)
+	if w.jumpBuffered > 0 && canJump {
+		w.State = Airborne
+		w.VelY = w.JumpSpeed
+		w.jumped = true
+		w.jumpBuffered = 0
+	}
+	w.jumpBuffered -= dt
+	if w.jumpBuffered < 0 {
+		w.jumpBuffered = 0
+	}
+}
+
+// airMove returns the horizontal movement for a step in the air, for the
+// movement that the input asks for. The player keeps the velocity from when
+// leaving the ground, the input only changes it by AirAcceleration.
+func (w *World) airMove(move d3dmath.Vec3, dt float32) d3dmath.Vec3 {
+	if move.Norm() == 0 {
+		return w.moveVel.MulScalar(dt)
+	}
+	change := move.MulScalar(1 / dt).Sub(w.moveVel)
+	if max := w.AirAcceleration * dt; change.Norm() > max {
+		change = change.Normalized().MulScalar(max)
+	}
+	return w.moveVel.Add(change).MulScalar(dt)
+}
+
+// stepVertical moves the player up or down, after the horizontal movement.
+// Grounded players follow the terrain unless it drops away faster than gravity
+// pulls them down, by more than the controller's StepHeight, e.g. at a ledge.
+// Airborne players fall until they land.
+func (w *World) stepVertical(dt float32) {
+	if w.State == Landing {
+		w.State = Grounded
+	}
+	y, _, onMap := w.Controller.Ground(w.Pos[0], w.Pos[2], w.Ground)
+
+	if w.State == Grounded {
+		// players only get pulled down, walking up a slope and over its top
+		// does not launch them into the air
+		velY := w.VelY
+		if velY > 0 {
+			velY = 0
+		}
+		lowest := w.Pos[1] + (velY+w.Gravity*dt)*dt - w.Controller.StepHeight
+		if onMap && y >= lowest {
+			w.VelY = (y - w.Pos[1]) / dt
+			w.Pos[1] = y
+			return
+		}
+		w.State = Airborne
+		w.VelY = velY
+		w.jumped = false
+		w.airTime = 0
+	}
+
+	w.airTime += dt
+	w.Pos[1] += w.VelY * dt
+	w.VelY += w.Gravity * dt
+	if onMap && w.Pos[1] <= y {
+		w.Pos[1] = y
+		w.ImpactSpeed = 0
+		if w.VelY < 0 {
+			w.ImpactSpeed = -w.VelY
+		}
+		w.VelY = 0
+		w.State = Landing
+	}
+}
@@ -0,0 +1,164 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+)
+
+// cliff is a plateau of the given height for x < -0.1 that drops down to 0 at
+// x = 0. It has cells*cells cells of size 0.1.
+func cliff(cells int, height float32) HeightField {
+	h := flatGround(cells, 0)
+	h.Scale = d3dmath.Vec3{0.1, 1, 0.1}
+	for i := range h.Heights {
+		for j := 0; j < cells/2; j++ {
+			h.Heights[i][j] = height
+		}
+	}
+	return h
+}
+
+// walkOffCliff walks the player towards +x until it leaves the plateau.
+func walkOffCliff(t *testing.T, w *World) {
+	w.Pos = d3dmath.Vec3{-1, 1, 0}
+	w.SetViewDir(d3dmath.Vec3{1, 0, 0})
+	for i := 0; i < 60; i++ {
+		w.Step(Input{Forward: true}, 1.0/60)
+		if w.State == Airborne {
+			return
+		}
+	}
+	t.Fatalf("player should have walked off the cliff but is at %v", w.Pos)
+}
+
+func TestWalkingOffCliffFallsAndLands(t *testing.T) {
+	w := NewWorld(cliff(40, 1))
+	walkOffCliff(t, w)
+	if w.Pos[1] < 0.8 {
+		t.Errorf("player should start falling from the top but is at %v", w.Pos)
+	}
+	landed := false
+	for i := 0; i < 60 && !landed; i++ {
+		w.Step(Input{}, 1.0/60)
+		landed = w.State == Landing
+	}
+	if !landed || w.Pos[1] != 0 {
+		t.Fatalf("player should have landed below the cliff but is at %v", w.Pos)
+	}
+	// falling about one unit
+	if w.ImpactSpeed < 3.5 || w.ImpactSpeed > 5 {
+		t.Errorf("want impact speed around 4.2 but have %v", w.ImpactSpeed)
+	}
+	w.Step(Input{}, 1.0/60)
+	if w.State != Grounded {
+		t.Errorf("landing should only last one step but state is %v", w.State)
+	}
+}
+
+func TestWalkingDownSlopeStaysOnGround(t *testing.T) {
+	w := NewWorld(slope(40, 30))
+	w.Spawn = d3dmath.Vec3{1.8, 0, 0}
+	w.Respawn()
+	w.SetViewDir(d3dmath.Vec3{-1, 0, 0})
+	for i := 0; i < 60; i++ {
+		w.Step(Input{Forward: true, Run: true}, 1.0/60)
+		if w.State != Grounded {
+			t.Fatalf("step %d: player should stay on the ground but is %v at %v", i, w.State, w.Pos)
+		}
+	}
+	if w.Pos[0] > -1 {
+		t.Errorf("player should have run down the slope but is at %v", w.Pos)
+	}
+}
+
+func TestJumpLandingEvent(t *testing.T) {
+	w := NewWorld(flatGround(10, 0))
+	w.Step(Input{Jump: true}, 1.0/60)
+	if w.State != Airborne {
+		t.Fatalf("player should jump but is %v", w.State)
+	}
+	for i := 0; i < 120 && w.State == Airborne; i++ {
+		w.Step(Input{}, 1.0/60)
+	}
+	if w.State != Landing {
+		t.Fatalf("player should have landed but is %v", w.State)
+	}
+	// landing as fast as the jump started
+	if abs(w.ImpactSpeed-w.JumpSpeed) > 0.2 {
+		t.Errorf("want impact speed %v but have %v", w.JumpSpeed, w.ImpactSpeed)
+	}
+}
+
+func TestCoyoteTime(t *testing.T) {
+	w := NewWorld(cliff(40, 1))
+	walkOffCliff(t, w)
+	w.Step(Input{Jump: true}, 1.0/60)
+	if w.VelY <= 0 {
+		t.Errorf("jump right after leaving the cliff should work, velocity is %v", w.VelY)
+	}
+
+	w = NewWorld(cliff(40, 1))
+	walkOffCliff(t, w)
+	walk(w, Input{}, 10)
+	w.Step(Input{Jump: true}, 1.0/60)
+	if w.VelY > 0 {
+		t.Errorf("jump long after leaving the cliff must not work, velocity is %v", w.VelY)
+	}
+
+	w = NewWorld(flatGround(10, 0))
+	w.Step(Input{Jump: true}, 1.0/60)
+	w.Step(Input{}, 1.0/60)
+	vel := w.VelY
+	w.Step(Input{Jump: true}, 1.0/60)
+	if w.VelY > vel {
+		t.Error("jumping again right after a jump must not work")
+	}
+}
+
+func TestJumpBuffering(t *testing.T) {
+	// count the steps that a jump takes
+	w := NewWorld(flatGround(10, 0))
+	w.Step(Input{Jump: true}, 1.0/60)
+	steps := 1
+	for w.State != Landing {
+		w.Step(Input{}, 1.0/60)
+		steps++
+	}
+
+	jumpAgain := func(early int) bool {
+		w := NewWorld(flatGround(10, 0))
+		w.Step(Input{Jump: true}, 1.0/60)
+		walk(w, Input{}, steps-early-1)
+		w.Step(Input{Jump: true}, 1.0/60)
+		walk(w, Input{}, early)
+		return w.State == Airborne && w.VelY > 0
+	}
+	if !jumpAgain(3) {
+		t.Error("jump pressed shortly before landing should happen after landing")
+	}
+	if jumpAgain(20) {
+		t.Error("jump pressed long before landing must be forgotten")
+	}
+}
+
+func TestAirControl(t *testing.T) {
+	w := NewWorld(flatGround(10, 0))
+	w.Step(Input{Jump: true}, 1.0/60)
+	walk(w, Input{Forward: true}, 6)
+	if w.Pos[2] > 0.05 {
+		t.Errorf("player should only slowly start moving in the air but is at %v", w.Pos)
+	}
+
+	w = NewWorld(flatGround(10, 0))
+	walk(w, Input{Forward: true}, 10)
+	start := w.Pos[2]
+	w.Step(Input{Forward: true, Jump: true}, 1.0/60)
+	walk(w, Input{}, 10)
+	if w.State != Airborne {
+		t.Fatalf("player should still be in the air")
+	}
+	if moved := w.Pos[2] - start; moved < 0.3 {
+		t.Errorf("player should keep moving in the air but only moved %v", moved)
+	}
+}
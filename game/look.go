@@ -0,0 +1,78 @@
+package game
+
+import (
+	"math"
+
+	"github.com/gonutz/d3dmath"
+)
+
+// ViewDir returns the unit length direction that the player looks in, see Yaw
+// and Pitch.
+func (w *World) ViewDir() d3dmath.Vec3 {
+	return direction(w.Yaw, w.Pitch)
+}
+
+// SetViewDir makes the player look in the given direction, which does not have
+// to be unit length. Looking straight up or down keeps the current Yaw and the
+// Pitch is limited to MaxPitch.
+func (w *World) SetViewDir(dir d3dmath.Vec3) {
+	if dir[0] != 0 || dir[2] != 0 {
+		w.Yaw = rad2deg(float32(math.Atan2(float64(dir[0]), float64(dir[2]))))
+	}
+	if n := dir.Norm(); n > 0 {
+		w.Pitch = rad2deg(float32(math.Asin(float64(dir[1] / n))))
+	}
+	w.Pitch = w.clampPitch(w.Pitch)
+}
+
+// look turns the view by the mouse movement dx, dy in pixels. Moving the mouse
+// right turns right, moving it down looks down, unless InvertY is set.
+func (w *World) look(dx, dy float32) {
+	if dx != 0 {
+		w.Yaw = wrapDegrees(w.Yaw + dx*w.LookSensitivity)
+	}
+	if dy != 0 {
+		if w.InvertY {
+			dy = -dy
+		}
+		w.Pitch = w.clampPitch(w.Pitch - dy*w.LookSensitivity)
+	}
+}
+
+func (w *World) clampPitch(pitch float32) float32 {
+	max := w.MaxPitch
+	if max > 89.9 {
+		max = 89.9
+	}
+	if pitch > max {
+		return max
+	}
+	if pitch < -max {
+		return -max
+	}
+	return pitch
+}
+
+// direction converts yaw and pitch in degrees to a unit vector. Yaw 0 looks
+// down the z-axis, yaw 90 down the x-axis.
+func direction(yaw, pitch float32) d3dmath.Vec3 {
+	y, p := float64(deg2rad(yaw)), float64(deg2rad(pitch))
+	return d3dmath.Vec3{
+		float32(math.Sin(y) * math.Cos(p)),
+		float32(math.Sin(p)),
+		float32(math.Cos(y) * math.Cos(p)),
+	}
+}
+
+// wrapDegrees brings an angle into the range [-180, 180).
+func wrapDegrees(a float32) float32 {
+	a = float32(math.Mod(float64(a)+180, 360))
+	if a < 0 {
+		a += 360
+	}
+	return a - 180
+}
+
+func rad2deg(x float32) float32 {
+	return x * 180 / math.Pi
+}
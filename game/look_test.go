@@ -0,0 +1,77 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+)
+
+func TestPitchIsClampedShortOfStraightUpAndDown(t *testing.T) {
+	w := NewWorld(flatGround(10, 0))
+	w.Step(Input{MouseDy: 100000}, 1.0/60)
+	if w.Pitch != -w.MaxPitch {
+		t.Errorf("want pitch %v but have %v", -w.MaxPitch, w.Pitch)
+	}
+	w.Step(Input{MouseDy: -100000}, 1.0/60)
+	if w.Pitch != w.MaxPitch {
+		t.Errorf("want pitch %v but have %v", w.MaxPitch, w.Pitch)
+	}
+	dir := w.ViewDir()
+	if dir[0] == 0 && dir[2] == 0 {
+		t.Errorf("view must not point straight up but is %v", dir)
+	}
+
+	w.MaxPitch = 90
+	w.SetViewDir(d3dmath.Vec3{0, 1, 0})
+	if w.Pitch >= 90 {
+		t.Errorf("pitch must stay short of 90 but is %v", w.Pitch)
+	}
+}
+
+func TestLookSensitivityAndInvertY(t *testing.T) {
+	w := NewWorld(flatGround(10, 0))
+	w.LookSensitivity = 0.5
+	w.Step(Input{MouseDx: 10, MouseDy: 10}, 1.0/60)
+	if !near(w.Yaw, 5) || !near(w.Pitch, -5) {
+		t.Errorf("want yaw 5 and pitch -5 but have %v and %v", w.Yaw, w.Pitch)
+	}
+	w.InvertY = true
+	w.Step(Input{MouseDy: 10}, 1.0/60)
+	if !near(w.Pitch, 0) {
+		t.Errorf("inverted mouse down should look up, pitch is %v", w.Pitch)
+	}
+}
+
+func TestYawWrapsAround(t *testing.T) {
+	w := NewWorld(flatGround(10, 0))
+	w.Yaw = 170
+	w.Step(Input{TurnX: 160}, 1.0/60) // 20 degrees at the default sensitivity
+	if !near(w.Yaw, -170) {
+		t.Errorf("want yaw -170 but have %v", w.Yaw)
+	}
+	// half way between 170 and -170 is 180, not 0
+	_, dir := w.Interpolated(0.5)
+	if !vecNear(dir, d3dmath.Vec3{0, 0, -1}) {
+		t.Errorf("want to look down -z half way but have %v", dir)
+	}
+}
+
+func TestSetViewDir(t *testing.T) {
+	w := NewWorld(flatGround(10, 0))
+	for _, dir := range []d3dmath.Vec3{
+		{0, 0, 1},
+		{1, 0, 0},
+		{0, 0, -1},
+		{-1, 0.5, -1},
+		{0.2, -3, 0.1},
+	} {
+		w.SetViewDir(dir)
+		if have := w.ViewDir(); !vecNear(have, dir.Normalized()) {
+			t.Errorf("set %v but view direction is %v", dir, have)
+		}
+	}
+}
+
+func near(a, b float32) bool {
+	return abs(a-b) < 1e-4
+}
@@ -0,0 +1,192 @@
+package game
+
+import (
+	"math"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/geometry"
+)
+
+// RayHit describes where a ray hits the height field.
+type RayHit struct {
+	Point  d3dmath.Vec3 // in world coordinates
+	Normal d3dmath.Vec3 // unit length, pointing up
+	// Distance is the distance from the ray's origin to Point, in world units.
+	Distance float32
+	// CellX and CellZ is the grid cell that was hit, counted from the cell
+	// with the lowest x and z coordinates.
+	CellX, CellZ int
+}
+
+// CastRay finds the first point where the ray from origin in direction dir
+// hits the height field. The direction does not need to be unit length. The
+// ray only hits the terrain inside the height field's grid, if it leaves the
+// grid without hitting anything, ok is false.
+func CastRay(origin, dir d3dmath.Vec3, h HeightField) (hit RayHit, ok bool) {
+	// the ray is traced in tile coordinates where each cell is 1x1, scaling
+	// does not change the ray parameter t so hit points can be computed in
+	// world coordinates with the same t
+	dx, _, dz := h.Offset()
+	o := d3dmath.Vec3{
+		origin[0]/h.Scale[0] - dx,
+		origin[1] / h.Scale[1],
+		origin[2]/h.Scale[2] - dz,
+	}
+	d := d3dmath.Vec3{
+		dir[0] / h.Scale[0],
+		dir[1] / h.Scale[1],
+		dir[2] / h.Scale[2],
+	}
+	if d.Norm() == 0 {
+		return RayHit{}, false
+	}
+
+	// clip the ray to the grid's extent in x and z
+	size := [3]float32{float32(h.CellsX()), 0, float32(h.CellsZ())}
+	tMin, tMax := float32(0), float32(math.Inf(1))
+	for _, axis := range [2]int{0, 2} {
+		if d[axis] == 0 {
+			if o[axis] < 0 || o[axis] > size[axis] {
+				return RayHit{}, false
+			}
+			continue
+		}
+		t1 := -o[axis] / d[axis]
+		t2 := (size[axis] - o[axis]) / d[axis]
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		if t1 > tMin {
+			tMin = t1
+		}
+		if t2 < tMax {
+			tMax = t2
+		}
+	}
+	if tMin > tMax {
+		return RayHit{}, false
+	}
+
+	// walk through the cells along the ray, see Amanatides and Woo, "A Fast
+	// Voxel Traversal Algorithm for Ray Tracing"
+	entry := o.Add(d.MulScalar(tMin))
+	x := clampInt(int(math.Floor(float64(entry[0]))), 0, h.CellsX()-1)
+	z := clampInt(int(math.Floor(float64(entry[2]))), 0, h.CellsZ()-1)
+	stepX, nextX, deltaX := traversalStep(o[0], d[0], x)
+	stepZ, nextZ, deltaZ := traversalStep(o[2], d[2], z)
+	t := tMin
+	for {
+		tExit := nextX
+		if nextZ < tExit {
+			tExit = nextZ
+		}
+		if tMax < tExit {
+			tExit = tMax
+		}
+		if cellT, normal, ok := castRayInCell(o, d, x, z, t, tExit, h); ok {
+			// the normal was computed in tile coordinates, the inverse
+			// transpose of the scaling brings it to world coordinates
+			normal = d3dmath.Vec3{
+				normal[0] / h.Scale[0],
+				normal[1] / h.Scale[1],
+				normal[2] / h.Scale[2],
+			}.Normalized()
+			return RayHit{
+				Point:    origin.Add(dir.MulScalar(cellT)),
+				Normal:   normal,
+				Distance: cellT * dir.Norm(),
+				CellX:    x,
+				CellZ:    z,
+			}, true
+		}
+		if tExit >= tMax {
+			return RayHit{}, false
+		}
+		if nextX < nextZ {
+			x += stepX
+			t = nextX
+			nextX += deltaX
+		} else {
+			z += stepZ
+			t = nextZ
+			nextZ += deltaZ
+		}
+		if x < 0 || z < 0 || x >= h.CellsX() || z >= h.CellsZ() {
+			return RayHit{}, false
+		}
+	}
+}
+
+// traversalStep returns the direction to step through cells along one axis,
+// the ray parameter t where the ray leaves the current cell and the change in t
+// from one cell to the next.
+func traversalStep(o, d float32, cell int) (step int, next, delta float32) {
+	if d > 0 {
+		return 1, (float32(cell+1) - o) / d, 1 / d
+	}
+	if d < 0 {
+		return -1, (float32(cell) - o) / d, -1 / d
+	}
+	inf := float32(math.Inf(1))
+	return 0, inf, inf
+}
+
+// castRayInCell intersects the ray (in tile coordinates) with the two
+// triangles of cell x,z. Only hits with a ray parameter between tFrom and tTo
+// count. It returns the ray parameter of the closer hit and the triangle's
+// normal, also in tile coordinates.
+func castRayInCell(o, d d3dmath.Vec3, x, z int, tFrom, tTo float32, h HeightField) (float32, d3dmath.Vec3, bool) {
+	// the corners of the cell, the same triangles as in HeightAt
+	row := h.CellsZ() - z
+	fx, fz := float32(x), float32(z)
+	bottomLeft := d3dmath.Vec3{fx, h.Heights[row][x], fz}
+	bottomRight := d3dmath.Vec3{fx + 1, h.Heights[row][x+1], fz}
+	topLeft := d3dmath.Vec3{fx, h.Heights[row-1][x], fz + 1}
+	topRight := d3dmath.Vec3{fx + 1, h.Heights[row-1][x+1], fz + 1}
+
+	// skip the cell if the ray passes above all of its corners
+	maxY := bottomLeft[1]
+	for _, y := range []float32{bottomRight[1], topLeft[1], topRight[1]} {
+		if y > maxY {
+			maxY = y
+		}
+	}
+	if o[1]+tFrom*d[1] > maxY && o[1]+tTo*d[1] > maxY {
+		return 0, d3dmath.Vec3{}, false
+	}
+
+	const eps = 1e-4
+	bestT := float32(math.Inf(1))
+	var bestNormal d3dmath.Vec3
+	ray := geometry.Ray{Origin: o, Dir: d}
+	for _, tri := range [2]geometry.Triangle{
+		{bottomRight, topLeft, bottomLeft},
+		{bottomRight, topRight, topLeft},
+	} {
+		// a ray that is parallel or coincident with a triangle can at most
+		// touch its edges, which belong to the neighbors as well
+		t, res := ray.IntersectTriangle(tri)
+		if res != geometry.Intersecting || t < tFrom-eps || t > tTo+eps || t >= bestT {
+			continue
+		}
+		plane, _ := tri.Plane()
+		bestT, bestNormal = t, plane.Normal
+		if bestNormal[1] < 0 {
+			bestNormal = bestNormal.MulScalar(-1)
+		}
+	}
+	if math.IsInf(float64(bestT), 1) {
+		return 0, d3dmath.Vec3{}, false
+	}
+	return bestT, bestNormal, true
+}
+
+func clampInt(x, min, max int) int {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
@@ -0,0 +1,146 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+)
+
+// ramp rises by 1 per cell along x, it has cells*cells cells.
+func ramp(cells int) HeightField {
+	h := flatGround(cells, 0)
+	for i := range h.Heights {
+		for j := range h.Heights[i] {
+			h.Heights[i][j] = float32(j)
+		}
+	}
+	return h
+}
+
+func vecNear(a, b d3dmath.Vec3) bool {
+	return a.Sub(b).Norm() < 1e-4
+}
+
+func TestRayStraightDownHitsHeightAt(t *testing.T) {
+	h := ramp(10)
+	h.Scale = d3dmath.Vec3{0.5, 0.2, 0.25}
+	for _, p := range []d3dmath.Vec3{
+		{0, 0, 0},
+		{0.3, 0, -0.7},
+		{-2.2, 0, 1.1},
+		{0.5, 0, 0.25}, // on a grid point
+	} {
+		origin := d3dmath.Vec3{p[0], 10, p[2]}
+		hit, ok := CastRay(origin, d3dmath.Vec3{0, -1, 0}, h)
+		if !ok {
+			t.Errorf("%v: ray straight down must hit", p)
+			continue
+		}
+		want := d3dmath.Vec3{p[0], HeightAt(p[0], p[2], h), p[2]}
+		if !vecNear(hit.Point, want) {
+			t.Errorf("%v: want hit at %v but have %v", p, want, hit.Point)
+		}
+		if abs(hit.Distance-(10-want[1])) > 1e-4 {
+			t.Errorf("%v: want distance %v but have %v", p, 10-want[1], hit.Distance)
+		}
+	}
+}
+
+func TestRayNormalAndCell(t *testing.T) {
+	h := ramp(4)
+	hit, ok := CastRay(d3dmath.Vec3{-1.5, 10, 0.5}, d3dmath.Vec3{0, -1, 0}, h)
+	if !ok {
+		t.Fatal("ray must hit")
+	}
+	if hit.CellX != 0 || hit.CellZ != 2 {
+		t.Errorf("want cell 0,2 but have %d,%d", hit.CellX, hit.CellZ)
+	}
+	// the ramp rises by 1 per unit in x, so the normal is (-1, 1, 0)
+	want := d3dmath.Vec3{-1, 1, 0}.Normalized()
+	if !vecNear(hit.Normal, want) {
+		t.Errorf("want normal %v but have %v", want, hit.Normal)
+	}
+}
+
+func TestRayUpOrBesideTheMapMisses(t *testing.T) {
+	h := flatGround(4, 0)
+	if _, ok := CastRay(d3dmath.Vec3{0, 1, 0}, d3dmath.Vec3{0, 1, 0}, h); ok {
+		t.Error("ray straight up must not hit")
+	}
+	if _, ok := CastRay(d3dmath.Vec3{5, 1, 0}, d3dmath.Vec3{0, -1, 0}, h); ok {
+		t.Error("ray next to the map must not hit")
+	}
+	if _, ok := CastRay(d3dmath.Vec3{5, 1, 0}, d3dmath.Vec3{1, -0.1, 0}, h); ok {
+		t.Error("ray away from the map must not hit")
+	}
+}
+
+func TestRayLeavingTheMapMisses(t *testing.T) {
+	h := flatGround(8, 0)
+	// this would hit the ground at x = 10, outside the map
+	if _, ok := CastRay(d3dmath.Vec3{0, 1, 0}, d3dmath.Vec3{1, -0.1, 0}, h); ok {
+		t.Error("ray must leave the map without hitting")
+	}
+	// from outside, into the map
+	hit, ok := CastRay(d3dmath.Vec3{-6, 1, 0.5}, d3dmath.Vec3{1, -0.25, 0}, h)
+	if !ok {
+		t.Fatal("ray into the map must hit")
+	}
+	if want := (d3dmath.Vec3{-2, 0, 0.5}); !vecNear(hit.Point, want) {
+		t.Errorf("want hit at %v but have %v", want, hit.Point)
+	}
+}
+
+func TestGrazingRays(t *testing.T) {
+	// a ridge of height 1 along z at x = 0
+	h := flatGround(8, 0)
+	for i := range h.Heights {
+		h.Heights[i][4] = 1
+	}
+
+	// barely above the ridge
+	if hit, ok := CastRay(d3dmath.Vec3{-3.5, 1.001, 0.3}, d3dmath.Vec3{1, 0, 0}, h); ok {
+		t.Errorf("ray above the ridge must not hit but hits %v", hit.Point)
+	}
+
+	// barely below the ridge top
+	hit, ok := CastRay(d3dmath.Vec3{-3.5, 0.999, 0.3}, d3dmath.Vec3{1, 0, 0}, h)
+	if !ok {
+		t.Fatal("ray just below the ridge top must hit")
+	}
+	if abs(hit.Point[0]) > 0.01 || hit.Point[1] != 0.999 {
+		t.Errorf("want hit just before the ridge top but have %v", hit.Point)
+	}
+
+	// a shallow shot over flat ground, hitting it far away
+	hit, ok = CastRay(d3dmath.Vec3{-3.5, 0.01, -2}, d3dmath.Vec3{1, -0.002, 0}, h)
+	if !ok {
+		t.Fatal("shallow ray must hit the ridge's slope")
+	}
+	if hit.Point[0] <= -1 || hit.Point[0] > 0 || hit.CellX != 3 {
+		t.Errorf("want hit on the ridge's slope but have %v in cell %d", hit.Point, hit.CellX)
+	}
+
+	// along the flat ground, in the plane of its triangles
+	if hit, ok := CastRay(d3dmath.Vec3{-3.5, 0, 2}, d3dmath.Vec3{0, 0, 1}, h); ok {
+		t.Errorf("ray in the ground's plane must not hit but hits %v", hit.Point)
+	}
+}
+
+func TestLaserEndsAtTheHitPoint(t *testing.T) {
+	w := NewWorld(flatGround(20, 0))
+	w.SetViewDir(d3dmath.Vec3{0, -1, 1}.Normalized())
+	w.Step(Input{Shoot: true}, 1.0/60)
+	end := w.LaserBeams[0].End
+	want := d3dmath.Vec3{0, 0, w.PlayerHeight * 0.9}
+	if !vecNear(end, want) {
+		t.Errorf("want laser to end at %v but it ends at %v", want, end)
+	}
+
+	w.SetViewDir(d3dmath.Vec3{0, 1, 1}.Normalized())
+	w.Step(Input{Shoot: true}, 1.0/60)
+	beam := w.LaserBeams[1]
+	if d := beam.End.Sub(beam.Start).Norm(); abs(d-MaxLaserRange) > 1e-3 {
+		t.Errorf("laser into the sky should be %v long but is %v", MaxLaserRange, d)
+	}
+}
@@ -0,0 +1,254 @@
+// Package game contains the simulation of the game world: player movement,
+// jumping, gravity, the terrain and laser shots. It does not know anything
+// about windows, input devices or graphics, the front end feeds it an Input
+// snapshot every step and reads the resulting state back out of the World.
+package game
+
+import (
+	"math"
+
+	"github.com/gonutz/d3dmath"
+)
+
+const (
+	LaserBeamDecay = -3 // life per second
+	// MaxLaserRange is the length of laser beams that do not hit the ground.
+	MaxLaserRange = 100
+	// RespawnDepth is how far the player can fall below the spawn point, e.g.
+	// after walking off the map, before being put back at the spawn point.
+	RespawnDepth = 20
+)
+
+// Input is a snapshot of the player's controls for one simulation step.
+type Input struct {
+	Forward  bool
+	Backward bool
+	Left     bool
+	Right    bool
+	Run      bool
+	Sneak    bool
+	Jump     bool
+	Shoot    bool
+	// MouseDx and MouseDy are the mouse movement in pixels since the last step.
+	MouseDx, MouseDy int
+	// MoveForward and MoveRight are analog movement, e.g. from a gamepad's
+	// stick, from -1 to 1. They add to the Forward, Backward, Left and Right
+	// keys.
+	MoveForward, MoveRight float32
+	// TurnX and TurnY turn the view like MouseDx and MouseDy, but fractions of
+	// pixels are possible. Mouse and turn are scaled by LookSensitivity.
+	TurnX, TurnY float32
+}
+
+type World struct {
+	Ground       HeightField
+	Pos          d3dmath.Vec3 // player position in the world
+	PlayerHeight float32
+	VelY         float32 // units per second
+	State        JumpState
+	MoveSpeed    float32 // units per second
+	RunSpeed     float32 // multiplies MoveSpeed while running
+	SneakSpeed   float32 // multiplies MoveSpeed while sneaking
+	JumpSpeed    float32 // units per second
+	Gravity      float32 // units per second squared
+	Controller   Controller
+	Spawn        d3dmath.Vec3 // see RespawnDepth
+	LaserBeams   []LaserBeam
+
+	// ImpactSpeed is the downward speed, in units per second, of the last
+	// landing. It is set in the step in which State becomes Landing.
+	ImpactSpeed float32
+	// CoyoteTime is how many seconds after walking off an edge the player can
+	// still jump.
+	CoyoteTime float32
+	// JumpBuffer is how many seconds a jump that is pressed in the air is
+	// remembered, so it happens when landing shortly after.
+	JumpBuffer float32
+	// AirAcceleration is how fast the player can change the horizontal
+	// velocity in the air, in units per second squared. On the ground, changes
+	// are instant.
+	AirAcceleration float32
+
+	// Yaw and Pitch are the view direction in degrees, see ViewDir. Yaw is
+	// kept in [-180, 180), Pitch is positive when looking up and stays within
+	// MaxPitch.
+	Yaw, Pitch float32
+	// MaxPitch is how many degrees the player can look up or down. It is kept
+	// short of 90, looking straight up or down has no defined yaw.
+	MaxPitch float32
+	// LookSensitivity is how many degrees the view turns per mouse pixel.
+	LookSensitivity float32
+	// InvertY makes moving the mouse up look down.
+	InvertY bool
+
+	// PrevPos, PrevYaw and PrevPitch are the player state before the last
+	// Step, see Interpolated.
+	PrevPos            d3dmath.Vec3
+	PrevYaw, PrevPitch float32
+
+	moveVel      d3dmath.Vec3 // horizontal velocity in the last step
+	airTime      float32      // seconds since leaving the ground
+	jumped       bool         // the player left the ground by jumping
+	jumpBuffered float32      // seconds left of the last jump press
+}
+
+type LaserBeam struct {
+	Life       float32
+	Start, End d3dmath.Vec3
+}
+
+// NewWorld places the player on the ground at the origin of the given terrain,
+// looking down the z-axis.
+func NewWorld(ground HeightField) *World {
+	w := &World{
+		Ground:       ground,
+		MoveSpeed:    1.8,
+		RunSpeed:     2,
+		SneakSpeed:   0.5,
+		JumpSpeed:    2.76,
+		Gravity:      -9,
+		PlayerHeight: 0.4,
+		CoyoteTime:   0.1,
+		JumpBuffer:   0.1,
+		Pos:          d3dmath.Vec3{0, 0, 0},
+		MaxPitch:     89,
+		Controller: Controller{
+			Radius:     0.1,
+			MaxSlope:   50,
+			StepHeight: 0.08,
+			SlideSpeed: 1.8,
+			Edges:      EdgeWall,
+		},
+		AirAcceleration: 6,
+		LookSensitivity: 0.125,
+	}
+	w.Respawn()
+	return w
+}
+
+// Respawn puts the player back at the spawn point, standing on the ground
+// below or above it if there is any.
+func (w *World) Respawn() {
+	w.Pos = w.Spawn
+	w.VelY = 0
+	w.moveVel = d3dmath.Vec3{}
+	if y, _, ok := w.Controller.Ground(w.Pos[0], w.Pos[2], w.Ground); ok {
+		w.Pos[1] = y
+		w.State = Grounded
+	} else {
+		w.State = Airborne
+		w.jumped = true
+	}
+	w.PrevPos = w.Pos
+}
+
+// Interpolated returns the player position and view direction between the
+// previous (alpha = 0) and the current step (alpha = 1). The simulation runs
+// at a fixed time step, rendering at a different rate uses this for smooth
+// motion, see Clock.Alpha.
+func (w *World) Interpolated(alpha float32) (pos, dir d3dmath.Vec3) {
+	pos = lerp(w.PrevPos, w.Pos, alpha)
+	// turn the short way around when crossing from -180 to 180 degrees
+	yaw := w.PrevYaw + wrapDegrees(w.Yaw-w.PrevYaw)*alpha
+	pitch := w.PrevPitch + (w.Pitch-w.PrevPitch)*alpha
+	return pos, direction(yaw, pitch)
+}
+
+func lerp(a, b d3dmath.Vec3, t float32) d3dmath.Vec3 {
+	return a.Add(b.Sub(a).MulScalar(t))
+}
+
+// Step advances the simulation by dt seconds. The game always steps with a
+// fixed dt, see Clock.
+func (w *World) Step(in Input, dt float32) {
+	w.PrevPos = w.Pos
+	w.PrevYaw, w.PrevPitch = w.Yaw, w.Pitch
+	viewDir := w.ViewDir()
+
+	// the step in which a jump starts is still on the ground, the player has
+	// full control over where to jump
+	wasInAir := w.InAir()
+	w.startJump(in, dt)
+
+	speed := w.MoveSpeed * dt
+	if in.Run {
+		speed *= w.RunSpeed
+	} else if in.Sneak {
+		speed *= w.SneakSpeed
+	}
+	moveDir := viewDir
+	moveDir[1] = 0
+	moveDir = moveDir.Normalized()
+	var move d3dmath.Vec3
+	if in.Forward {
+		move = move.Add(moveDir.MulScalar(speed))
+	}
+	if in.Backward {
+		move = move.Add(moveDir.MulScalar(-speed))
+	}
+	if in.Left {
+		move = move.Add(
+			viewDir.Cross(d3dmath.Vec3{0, 1, 0}).MulScalar(speed),
+		)
+	}
+	if in.Right {
+		move = move.Add(
+			d3dmath.Vec3{0, 1, 0}.Cross(viewDir).MulScalar(speed),
+		)
+	}
+	if in.MoveForward != 0 {
+		move = move.Add(moveDir.MulScalar(speed * in.MoveForward))
+	}
+	if in.MoveRight != 0 {
+		move = move.Add(
+			d3dmath.Vec3{0, 1, 0}.Cross(viewDir).MulScalar(speed * in.MoveRight),
+		)
+	}
+	if wasInAir {
+		move = w.airMove(move, dt)
+	} else {
+		move = move.Add(w.Controller.Slide(w.Pos, dt, w.Ground))
+	}
+	before := w.Pos
+	w.Pos = w.Controller.Move(w.Pos, move, w.Ground)
+	w.moveVel = w.Pos.Sub(before).MulScalar(1 / dt)
+	w.moveVel[1] = 0
+	w.look(float32(in.MouseDx)+in.TurnX, float32(in.MouseDy)+in.TurnY)
+
+	w.stepVertical(dt)
+	if w.Pos[1] < w.Spawn[1]-RespawnDepth {
+		w.Respawn()
+	}
+
+	if in.Shoot {
+		origin := w.Pos.Add(d3dmath.Vec3{0, w.PlayerHeight * 0.9, 0})
+		dir := w.ViewDir()
+		end := origin.Add(dir.MulScalar(MaxLaserRange))
+		if hit, ok := CastRay(origin, dir, w.Ground); ok && hit.Distance < MaxLaserRange {
+			end = hit.Point
+		}
+		w.shootLaser(origin, end)
+	}
+
+	i := 0
+	for i < len(w.LaserBeams) {
+		if w.LaserBeams[i].Life <= 0 {
+			w.LaserBeams = append(w.LaserBeams[:i], w.LaserBeams[i+1:]...)
+		} else {
+			w.LaserBeams[i].Life += LaserBeamDecay * dt
+			i++
+		}
+	}
+}
+
+func (w *World) shootLaser(from, to d3dmath.Vec3) {
+	w.LaserBeams = append(w.LaserBeams, LaserBeam{
+		Life:  1,
+		Start: from,
+		End:   to,
+	})
+}
+
+func deg2rad(x float32) float32 {
+	return x * math.Pi / 180
+}
@@ -0,0 +1,112 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+)
+
+func flatGround(size int, height float32) HeightField {
+	heights := make([][]float32, size+1)
+	for i := range heights {
+		heights[i] = make([]float32, size+1)
+		for j := range heights[i] {
+			heights[i][j] = height
+		}
+	}
+	return HeightField{Heights: heights, Scale: d3dmath.Vec3{1, 1, 1}}
+}
+
+func TestWalkingForwardMovesAlongViewDirection(t *testing.T) {
+	w := NewWorld(flatGround(10, 0))
+	for i := 0; i < 60; i++ {
+		w.Step(Input{Forward: true}, 1.0/60)
+	}
+	if w.Pos[0] != 0 || w.Pos[1] != 0 || abs(w.Pos[2]-w.MoveSpeed) > 0.001 {
+		t.Errorf("want (0 0 %v) after one second but have %v", w.MoveSpeed, w.Pos)
+	}
+}
+
+func TestJumpLandsBackOnGround(t *testing.T) {
+	w := NewWorld(flatGround(10, 0.5))
+	w.Step(Input{}, 1.0/60)
+	if w.Pos[1] != 0.5 {
+		t.Fatalf("player should stand on the ground at 0.5 but is at %v", w.Pos[1])
+	}
+	w.Step(Input{Jump: true}, 1.0/60)
+	if !w.InAir() || w.Pos[1] <= 0.5 {
+		t.Fatalf("player should be in the air, position is %v", w.Pos)
+	}
+	for i := 0; i < 120 && w.InAir(); i++ {
+		w.Step(Input{}, 1.0/60)
+	}
+	if w.InAir() || w.Pos[1] != 0.5 {
+		t.Errorf("player should have landed but is at %v, in air: %v", w.Pos, w.InAir())
+	}
+}
+
+func TestLaserBeamsDecay(t *testing.T) {
+	w := NewWorld(flatGround(10, 0))
+	w.Step(Input{Shoot: true}, 1.0/60)
+	if len(w.LaserBeams) != 1 {
+		t.Fatalf("want 1 laser beam but have %d", len(w.LaserBeams))
+	}
+	for i := 0; i < 60; i++ {
+		w.Step(Input{}, 1.0/60)
+	}
+	if len(w.LaserBeams) != 0 {
+		t.Errorf("laser beam should have decayed but is %v", w.LaserBeams)
+	}
+}
+
+func TestAnalogMovement(t *testing.T) {
+	walk := func(in Input) d3dmath.Vec3 {
+		w := NewWorld(flatGround(10, 0))
+		for i := 0; i < 60; i++ {
+			w.Step(in, 1.0/60)
+		}
+		return w.Pos
+	}
+	keys := walk(Input{Forward: true, Right: true})
+	stick := walk(Input{MoveForward: 0.5, MoveRight: 0.5})
+	for i := range keys {
+		if abs(stick[i]-keys[i]/2) > 0.001 {
+			t.Fatalf("half tilt should walk half as far as the keys: %v vs %v", stick, keys)
+		}
+	}
+}
+
+func TestAxesStayUntilChanged(t *testing.T) {
+	var c Controls
+	c.Handle(Event{Kind: AxisMove, Axis: AxisForward, Value: 0.5})
+	c.Handle(Event{Kind: AxisMove, Axis: AxisTurnX, Value: 2})
+	for i := 0; i < 2; i++ {
+		in := c.NextInput()
+		if in.MoveForward != 0.5 || in.TurnX != 2 || in.MoveRight != 0 {
+			t.Errorf("step %d: want forward 0.5 and turn 2 but have %+v", i, in)
+		}
+	}
+	c.Handle(Event{Kind: AxisMove, Axis: AxisForward, Value: 0})
+	if in := c.NextInput(); in.MoveForward != 0 {
+		t.Errorf("axis should be released but is %v", in.MoveForward)
+	}
+}
+
+func TestMouseDeltasAddUp(t *testing.T) {
+	var c Controls
+	c.Handle(Event{Kind: MouseDelta, X: 3, Y: -1})
+	c.Handle(Event{Kind: MouseDelta, X: 4, Y: -1})
+	if in := c.NextInput(); in.MouseDx != 7 || in.MouseDy != -2 {
+		t.Errorf("want mouse 7, -2 but have %d, %d", in.MouseDx, in.MouseDy)
+	}
+	if in := c.NextInput(); in.MouseDx != 0 || in.MouseDy != 0 {
+		t.Errorf("mouse should be reset after a step but is %d, %d", in.MouseDx, in.MouseDy)
+	}
+}
+
+func abs(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
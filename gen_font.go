@@ -0,0 +1,66 @@
+//go:build ignore
+// +build ignore
+
+// gen_font creates the overlay font: font.png has the glyphs in white with
+// their coverage in alpha, font.txt has their places in font.png and metrics,
+// see render.ParseFont. Run it with
+//
+//	go run gen_font.go
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	atlasSize = 128
+	columns   = 16
+)
+
+func main() {
+	face := basicfont.Face7x13
+	cellW, cellH := face.Width, face.Ascent+face.Descent
+
+	atlas := image.NewRGBA(image.Rect(0, 0, atlasSize, atlasSize))
+	metrics, err := os.Create("font.txt")
+	check(err)
+	defer metrics.Close()
+	fmt.Fprintln(metrics, "# generated by gen_font.go from the X11 misc-fixed 7x13 font")
+	fmt.Fprintln(metrics, "texture font.png")
+	fmt.Fprintln(metrics, "size", atlasSize, atlasSize)
+	fmt.Fprintln(metrics, "line", face.Height)
+	fmt.Fprintln(metrics, "# rune x y width height xOffset yOffset advance")
+
+	for r := rune(' '); r <= '~'; r++ {
+		i := int(r - ' ')
+		x, y := i%columns*cellW, i/columns*cellH
+		// the dot is on the base line, the metrics are relative to the top of
+		// the line
+		dr, mask, maskp, advance, ok := face.Glyph(fixed.P(0, face.Ascent), r)
+		if !ok {
+			panic(fmt.Sprintf("no glyph for %q", r))
+		}
+		dst := image.Rect(x, y, x+dr.Dx(), y+dr.Dy())
+		draw.DrawMask(atlas, dst, image.NewUniform(color.White), image.Point{}, mask, maskp, draw.Over)
+		fmt.Fprintln(metrics, r, x, y, dr.Dx(), dr.Dy(), dr.Min.X, dr.Min.Y, advance.Round())
+	}
+
+	f, err := os.Create("font.png")
+	check(err)
+	defer f.Close()
+	check(png.Encode(f, atlas))
+}
+
+func check(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
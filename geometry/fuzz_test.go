@@ -0,0 +1,95 @@
+//go:build go1.18
+
+package geometry
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+)
+
+func finite(values ...float32) bool {
+	for _, v := range values {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) || abs(v) > 1e6 {
+			return false
+		}
+	}
+	return true
+}
+
+// FuzzRayIntersectTriangle checks that reported hits lie on the triangle's
+// plane and that no input, however degenerate, produces NaN or panics.
+func FuzzRayIntersectTriangle(f *testing.F) {
+	f.Add(float32(0.25), float32(1), float32(0.25), float32(0), float32(-1), float32(0),
+		float32(1), float32(0), float32(0), float32(0), float32(0), float32(1))
+	f.Add(float32(-1), float32(0), float32(0.25), float32(1), float32(0), float32(0),
+		float32(1), float32(0), float32(0), float32(0), float32(0), float32(1))
+	f.Add(float32(0), float32(0), float32(0), float32(0), float32(0), float32(0),
+		float32(0), float32(0), float32(0), float32(0), float32(0), float32(0))
+	f.Fuzz(func(t *testing.T, ox, oy, oz, dx, dy, dz, ax, ay, az, bx, by, bz float32) {
+		if !finite(ox, oy, oz, dx, dy, dz, ax, ay, az, bx, by, bz) {
+			t.Skip()
+		}
+		ray := Ray{Origin: d3dmath.Vec3{ox, oy, oz}, Dir: d3dmath.Vec3{dx, dy, dz}}
+		tri := Triangle{{ax, ay, az}, {bx, by, bz}, {0, 0, 0}}
+		tt, res := ray.IntersectTriangle(tri)
+		if res != Intersecting {
+			if tt != 0 {
+				t.Errorf("%v but t is %v", res, tt)
+			}
+			return
+		}
+		if tt < 0 || math.IsNaN(float64(tt)) {
+			t.Fatalf("hit at invalid t %v", tt)
+		}
+		plane, res := tri.Plane()
+		if res != Intersecting {
+			t.Fatalf("hit on a %v triangle", res)
+		}
+		p := ray.At(tt)
+		scale := 1 + p.Norm() + tri[0].Norm() + tri[1].Norm()
+		if d := abs(plane.Distance(p)); d > 1e-3*scale {
+			t.Errorf("hit point %v is %v away from the triangle's plane", p, d)
+		}
+	})
+}
+
+// FuzzLinePlane checks that intersection points are on the plane and that the
+// other results are consistent with the line's direction.
+func FuzzLinePlane(f *testing.F) {
+	f.Add(float32(0), float32(2), float32(0), float32(0), float32(-1), float32(0),
+		float32(0), float32(1), float32(0), float32(0))
+	f.Add(float32(0), float32(1), float32(0), float32(1), float32(0), float32(0),
+		float32(0), float32(1), float32(0), float32(0))
+	f.Fuzz(func(t *testing.T, ox, oy, oz, dx, dy, dz, nx, ny, nz, d float32) {
+		if !finite(ox, oy, oz, dx, dy, dz, nx, ny, nz, d) {
+			t.Skip()
+		}
+		n := d3dmath.Vec3{nx, ny, nz}
+		if n.Norm() < 1e-3 {
+			t.Skip()
+		}
+		plane := Plane{Normal: n.Normalized(), D: d}
+		origin, dir := d3dmath.Vec3{ox, oy, oz}, d3dmath.Vec3{dx, dy, dz}
+		tt, res := LinePlane(origin, dir, plane)
+		switch res {
+		case Intersecting:
+			p := origin.Add(dir.MulScalar(tt))
+			scale := 1 + p.Norm() + origin.Norm() + abs(d)
+			if dist := abs(plane.Distance(p)); dist > 1e-3*scale {
+				t.Errorf("intersection %v is %v away from the plane", p, dist)
+			}
+		case Parallel, Coincident:
+			if abs(plane.Normal.Dot(dir)) > 1e-5*dir.Norm() {
+				t.Errorf("%v but direction %v is not parallel to %v", res, dir, plane)
+			}
+		case Degenerate:
+			if dir.Norm() != 0 {
+				t.Errorf("degenerate with direction %v", dir)
+			}
+		default:
+			t.Errorf("unexpected result %v", res)
+		}
+	})
+}
@@ -0,0 +1,243 @@
+// Package geometry has the primitives that the game intersects: planes, rays,
+// line segments, triangles and axis-aligned boxes. Intersections report
+// degenerate cases explicitly instead of dividing by zero, see Result.
+package geometry
+
+import (
+	"math"
+
+	"github.com/gonutz/d3dmath"
+)
+
+// Epsilon is the tolerance for treating directions as parallel and points as
+// lying on an edge or plane.
+const Epsilon = 1e-6
+
+// Result tells how two primitives intersect.
+type Result int
+
+const (
+	// NoIntersection means the primitives do not touch, e.g. a ray pointing
+	// away from a plane or passing beside a triangle.
+	NoIntersection Result = iota
+	// Intersecting means they meet in a single point.
+	Intersecting
+	// Parallel means the direction of a ray, line or segment is parallel to a
+	// plane that it does not lie in.
+	Parallel
+	// Coincident means a ray, line or segment lies in the plane.
+	Coincident
+	// Degenerate means one of the inputs is invalid, e.g. a ray without a
+	// direction or a triangle whose corners are on one line.
+	Degenerate
+)
+
+func (r Result) String() string {
+	switch r {
+	case NoIntersection:
+		return "no intersection"
+	case Intersecting:
+		return "intersecting"
+	case Parallel:
+		return "parallel"
+	case Coincident:
+		return "coincident"
+	case Degenerate:
+		return "degenerate"
+	}
+	return "unknown result"
+}
+
+// Plane contains all points p with Normal.Dot(p) == D. Normal is unit length.
+type Plane struct {
+	Normal d3dmath.Vec3
+	D      float32
+}
+
+// PlaneFromPoints returns the plane through a, b and c with the normal in the
+// direction of (b-a) x (c-a). The result is Degenerate if the points are on one
+// line.
+func PlaneFromPoints(a, b, c d3dmath.Vec3) (Plane, Result) {
+	n := b.Sub(a).Cross(c.Sub(a))
+	length := n.Norm()
+	if length == 0 || length <= Epsilon*b.Sub(a).Norm()*c.Sub(a).Norm() {
+		return Plane{}, Degenerate
+	}
+	n = n.MulScalar(1 / length)
+	return Plane{Normal: n, D: n.Dot(a)}, Intersecting
+}
+
+// Distance is the signed distance of p to the plane, positive on the side that
+// the normal points to.
+func (p Plane) Distance(v d3dmath.Vec3) float32 {
+	return p.Normal.Dot(v) - p.D
+}
+
+// LinePlane intersects the infinite line through origin in direction dir with
+// the plane. The intersection point is origin + t*dir.
+func LinePlane(origin, dir d3dmath.Vec3, p Plane) (t float32, r Result) {
+	if dir.Norm() == 0 {
+		return 0, Degenerate
+	}
+	denom := p.Normal.Dot(dir)
+	dist := p.Distance(origin)
+	if abs(denom) <= Epsilon*dir.Norm() {
+		if abs(dist) <= Epsilon {
+			return 0, Coincident
+		}
+		return 0, Parallel
+	}
+	return -dist / denom, Intersecting
+}
+
+// Ray starts at Origin and goes on infinitely in direction Dir. Dir does not
+// need to be unit length, positions on the ray are Origin + t*Dir for t >= 0.
+type Ray struct {
+	Origin, Dir d3dmath.Vec3
+}
+
+// At returns the point at parameter t.
+func (r Ray) At(t float32) d3dmath.Vec3 {
+	return r.Origin.Add(r.Dir.MulScalar(t))
+}
+
+// IntersectPlane returns where the ray hits the plane. A plane behind the ray
+// is NoIntersection.
+func (r Ray) IntersectPlane(p Plane) (t float32, res Result) {
+	t, res = LinePlane(r.Origin, r.Dir, p)
+	if res == Intersecting && t < 0 {
+		return 0, NoIntersection
+	}
+	return t, res
+}
+
+// IntersectTriangle returns where the ray hits the triangle, from either side.
+// Points on the triangle's edges count as hits. If the ray lies in the
+// triangle's plane the result is Coincident, no matter if it crosses the
+// triangle or not.
+func (r Ray) IntersectTriangle(tri Triangle) (t float32, res Result) {
+	// Möller and Trumbore, "Fast, Minimum Storage Ray/Triangle Intersection"
+	if r.Dir.Norm() == 0 {
+		return 0, Degenerate
+	}
+	plane, res := tri.Plane()
+	if res != Intersecting {
+		return 0, res
+	}
+	if _, res := LinePlane(r.Origin, r.Dir, plane); res != Intersecting {
+		return 0, res
+	}
+	e1 := tri[1].Sub(tri[0])
+	e2 := tri[2].Sub(tri[0])
+	p := r.Dir.Cross(e2)
+	det := e1.Dot(p)
+	inv := 1 / det
+	s := r.Origin.Sub(tri[0])
+	u := s.Dot(p) * inv
+	if u < -Epsilon || u > 1+Epsilon {
+		return 0, NoIntersection
+	}
+	q := s.Cross(e1)
+	v := r.Dir.Dot(q) * inv
+	if v < -Epsilon || u+v > 1+Epsilon {
+		return 0, NoIntersection
+	}
+	t = e2.Dot(q) * inv
+	if t < 0 {
+		return 0, NoIntersection
+	}
+	return t, Intersecting
+}
+
+// IntersectAABB returns the range of ray parameters for which the ray is
+// inside the box. If the ray starts inside the box, tNear is 0.
+func (r Ray) IntersectAABB(b AABB) (tNear, tFar float32, res Result) {
+	if r.Dir.Norm() == 0 {
+		return 0, 0, Degenerate
+	}
+	tNear, tFar = 0, float32(math.Inf(1))
+	for i := 0; i < 3; i++ {
+		if r.Dir[i] == 0 {
+			if r.Origin[i] < b.Min[i] || r.Origin[i] > b.Max[i] {
+				return 0, 0, NoIntersection
+			}
+			continue
+		}
+		t1 := (b.Min[i] - r.Origin[i]) / r.Dir[i]
+		t2 := (b.Max[i] - r.Origin[i]) / r.Dir[i]
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		if t1 > tNear {
+			tNear = t1
+		}
+		if t2 < tFar {
+			tFar = t2
+		}
+		if tNear > tFar {
+			return 0, 0, NoIntersection
+		}
+	}
+	return tNear, tFar, Intersecting
+}
+
+// Segment is the straight line between A and B.
+type Segment struct {
+	A, B d3dmath.Vec3
+}
+
+// IntersectPlane returns the point where the segment crosses the plane.
+func (s Segment) IntersectPlane(p Plane) (d3dmath.Vec3, Result) {
+	dir := s.B.Sub(s.A)
+	t, res := LinePlane(s.A, dir, p)
+	if res != Intersecting {
+		return d3dmath.Vec3{}, res
+	}
+	if t < 0 || t > 1 {
+		return d3dmath.Vec3{}, NoIntersection
+	}
+	return s.A.Add(dir.MulScalar(t)), Intersecting
+}
+
+// Triangle is given by its three corners.
+type Triangle [3]d3dmath.Vec3
+
+// Plane returns the plane that the triangle lies in, see PlaneFromPoints.
+func (t Triangle) Plane() (Plane, Result) {
+	return PlaneFromPoints(t[0], t[1], t[2])
+}
+
+// AABB is an axis-aligned bounding box.
+type AABB struct {
+	Min, Max d3dmath.Vec3
+}
+
+// Contains tells whether p is inside the box or on its surface.
+func (b AABB) Contains(p d3dmath.Vec3) bool {
+	for i := 0; i < 3; i++ {
+		if p[i] < b.Min[i] || p[i] > b.Max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Distance returns how far p is from the box, 0 if it is inside.
+func (b AABB) Distance(p d3dmath.Vec3) float32 {
+	var d d3dmath.Vec3
+	for i := 0; i < 3; i++ {
+		if p[i] < b.Min[i] {
+			d[i] = b.Min[i] - p[i]
+		} else if p[i] > b.Max[i] {
+			d[i] = p[i] - b.Max[i]
+		}
+	}
+	return d.Norm()
+}
+
+func abs(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,212 @@
+package geometry
+
+import (
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+)
+
+func near(a, b float32) bool {
+	return abs(a-b) < 1e-5
+}
+
+func vecNear(a, b d3dmath.Vec3) bool {
+	return a.Sub(b).Norm() < 1e-5
+}
+
+// ground is the plane y = 0 with the normal pointing up.
+var ground = Plane{Normal: d3dmath.Vec3{0, 1, 0}, D: 0}
+
+func TestPlaneFromPoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b, c d3dmath.Vec3
+		want    Plane
+		res     Result
+	}{
+		{
+			name: "ground",
+			a:    d3dmath.Vec3{0, 0, 0},
+			b:    d3dmath.Vec3{0, 0, 1},
+			c:    d3dmath.Vec3{1, 0, 0},
+			want: Plane{Normal: d3dmath.Vec3{0, 1, 0}, D: 0},
+			res:  Intersecting,
+		},
+		{
+			name: "shifted wall",
+			a:    d3dmath.Vec3{2, 0, 0},
+			b:    d3dmath.Vec3{2, 1, 0},
+			c:    d3dmath.Vec3{2, 0, 1},
+			want: Plane{Normal: d3dmath.Vec3{1, 0, 0}, D: 2},
+			res:  Intersecting,
+		},
+		{
+			name: "collinear",
+			a:    d3dmath.Vec3{0, 0, 0},
+			b:    d3dmath.Vec3{1, 1, 1},
+			c:    d3dmath.Vec3{3, 3, 3},
+			res:  Degenerate,
+		},
+		{
+			name: "same point twice",
+			a:    d3dmath.Vec3{1, 2, 3},
+			b:    d3dmath.Vec3{1, 2, 3},
+			c:    d3dmath.Vec3{0, 0, 0},
+			res:  Degenerate,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, res := PlaneFromPoints(test.a, test.b, test.c)
+			if res != test.res {
+				t.Fatalf("want %v but have %v", test.res, res)
+			}
+			if res == Intersecting && (!vecNear(p.Normal, test.want.Normal) || !near(p.D, test.want.D)) {
+				t.Errorf("want %v but have %v", test.want, p)
+			}
+		})
+	}
+}
+
+func TestLinePlane(t *testing.T) {
+	tests := []struct {
+		name        string
+		origin, dir d3dmath.Vec3
+		t           float32
+		res         Result
+	}{
+		{"straight down", d3dmath.Vec3{0, 2, 0}, d3dmath.Vec3{0, -1, 0}, 2, Intersecting},
+		{"from below", d3dmath.Vec3{5, -1, 3}, d3dmath.Vec3{0, 2, 0}, 0.5, Intersecting},
+		{"behind the origin", d3dmath.Vec3{0, 2, 0}, d3dmath.Vec3{0, 1, 0}, -2, Intersecting},
+		{"slanted", d3dmath.Vec3{0, 1, 0}, d3dmath.Vec3{1, -1, 0}, 1, Intersecting},
+		{"on the plane", d3dmath.Vec3{0, 0, 0}, d3dmath.Vec3{1, 1, 0}, 0, Intersecting},
+		{"parallel", d3dmath.Vec3{0, 1, 0}, d3dmath.Vec3{1, 0, 1}, 0, Parallel},
+		{"coincident", d3dmath.Vec3{4, 0, 4}, d3dmath.Vec3{1, 0, -1}, 0, Coincident},
+		{"no direction", d3dmath.Vec3{0, 1, 0}, d3dmath.Vec3{}, 0, Degenerate},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tt, res := LinePlane(test.origin, test.dir, ground)
+			if res != test.res || !near(tt, test.t) {
+				t.Errorf("want %v at %v but have %v at %v", test.res, test.t, res, tt)
+			}
+		})
+	}
+}
+
+func TestRayIntersectPlane(t *testing.T) {
+	if _, res := (Ray{d3dmath.Vec3{0, 2, 0}, d3dmath.Vec3{0, 1, 0}}).IntersectPlane(ground); res != NoIntersection {
+		t.Errorf("plane behind the ray: want no intersection but have %v", res)
+	}
+	r := Ray{d3dmath.Vec3{1, 2, 3}, d3dmath.Vec3{0, -4, 0}}
+	tt, res := r.IntersectPlane(ground)
+	if res != Intersecting || !vecNear(r.At(tt), d3dmath.Vec3{1, 0, 3}) {
+		t.Errorf("want hit at 1,0,3 but have %v at %v", res, r.At(tt))
+	}
+}
+
+func TestRayIntersectTriangle(t *testing.T) {
+	tri := Triangle{{1, 0, 0}, {0, 0, 1}, {0, 0, 0}}
+	tests := []struct {
+		name string
+		ray  Ray
+		t    float32
+		res  Result
+	}{
+		{"down inside", Ray{d3dmath.Vec3{0.25, 1, 0.25}, d3dmath.Vec3{0, -1, 0}}, 1, Intersecting},
+		{"up from below", Ray{d3dmath.Vec3{0.25, -1, 0.25}, d3dmath.Vec3{0, 2, 0}}, 0.5, Intersecting},
+		{"on the diagonal", Ray{d3dmath.Vec3{0.5, 1, 0.5}, d3dmath.Vec3{0, -1, 0}}, 1, Intersecting},
+		{"through a corner", Ray{d3dmath.Vec3{1, 1, 0}, d3dmath.Vec3{0, -1, 0}}, 1, Intersecting},
+		{"beside", Ray{d3dmath.Vec3{0.75, 1, 0.75}, d3dmath.Vec3{0, -1, 0}}, 0, NoIntersection},
+		{"pointing away", Ray{d3dmath.Vec3{0.25, 1, 0.25}, d3dmath.Vec3{0, 1, 0}}, 0, NoIntersection},
+		{"parallel above", Ray{d3dmath.Vec3{-1, 1, 0.25}, d3dmath.Vec3{1, 0, 0}}, 0, Parallel},
+		{"coincident, crossing", Ray{d3dmath.Vec3{-1, 0, 0.25}, d3dmath.Vec3{1, 0, 0}}, 0, Coincident},
+		{"coincident, missing", Ray{d3dmath.Vec3{-1, 0, 5}, d3dmath.Vec3{1, 0, 0}}, 0, Coincident},
+		{"no direction", Ray{d3dmath.Vec3{0.25, 1, 0.25}, d3dmath.Vec3{}}, 0, Degenerate},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tt, res := test.ray.IntersectTriangle(tri)
+			if res != test.res || !near(tt, test.t) {
+				t.Errorf("want %v at %v but have %v at %v", test.res, test.t, res, tt)
+			}
+		})
+	}
+
+	line := Triangle{{0, 0, 0}, {1, 0, 0}, {2, 0, 0}}
+	if _, res := (Ray{d3dmath.Vec3{0.5, 1, 0}, d3dmath.Vec3{0, -1, 0}}).IntersectTriangle(line); res != Degenerate {
+		t.Errorf("collinear triangle: want degenerate but have %v", res)
+	}
+}
+
+func TestRayIntersectAABB(t *testing.T) {
+	box := AABB{Min: d3dmath.Vec3{-1, -1, -1}, Max: d3dmath.Vec3{1, 1, 1}}
+	tests := []struct {
+		name      string
+		ray       Ray
+		near, far float32
+		res       Result
+	}{
+		{"through the middle", Ray{d3dmath.Vec3{-3, 0, 0}, d3dmath.Vec3{1, 0, 0}}, 2, 4, Intersecting},
+		{"from inside", Ray{d3dmath.Vec3{0, 0, 0}, d3dmath.Vec3{0, 0, 2}}, 0, 0.5, Intersecting},
+		{"diagonal", Ray{d3dmath.Vec3{-2, -2, -2}, d3dmath.Vec3{1, 1, 1}}, 1, 3, Intersecting},
+		{"along a face", Ray{d3dmath.Vec3{-3, 1, 0}, d3dmath.Vec3{1, 0, 0}}, 2, 4, Intersecting},
+		{"beside", Ray{d3dmath.Vec3{-3, 2, 0}, d3dmath.Vec3{1, 0, 0}}, 0, 0, NoIntersection},
+		{"away", Ray{d3dmath.Vec3{-3, 0, 0}, d3dmath.Vec3{-1, 0, 0}}, 0, 0, NoIntersection},
+		{"missing a corner", Ray{d3dmath.Vec3{-3, 0, 0}, d3dmath.Vec3{1, 1.1, 0}}, 0, 0, NoIntersection},
+		{"no direction", Ray{d3dmath.Vec3{0, 0, 0}, d3dmath.Vec3{}}, 0, 0, Degenerate},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tNear, tFar, res := test.ray.IntersectAABB(box)
+			if res != test.res || !near(tNear, test.near) || !near(tFar, test.far) {
+				t.Errorf("want %v from %v to %v but have %v from %v to %v",
+					test.res, test.near, test.far, res, tNear, tFar)
+			}
+		})
+	}
+}
+
+func TestSegmentIntersectPlane(t *testing.T) {
+	tests := []struct {
+		name string
+		seg  Segment
+		want d3dmath.Vec3
+		res  Result
+	}{
+		{"crossing", Segment{d3dmath.Vec3{0, 1, 0}, d3dmath.Vec3{2, -1, 0}}, d3dmath.Vec3{1, 0, 0}, Intersecting},
+		{"ending on the plane", Segment{d3dmath.Vec3{0, 1, 0}, d3dmath.Vec3{0, 0, 0}}, d3dmath.Vec3{0, 0, 0}, Intersecting},
+		{"too short", Segment{d3dmath.Vec3{0, 2, 0}, d3dmath.Vec3{0, 1, 0}}, d3dmath.Vec3{}, NoIntersection},
+		{"parallel", Segment{d3dmath.Vec3{0, 1, 0}, d3dmath.Vec3{1, 1, 0}}, d3dmath.Vec3{}, Parallel},
+		{"coincident", Segment{d3dmath.Vec3{0, 0, 0}, d3dmath.Vec3{1, 0, 0}}, d3dmath.Vec3{}, Coincident},
+		{"a point", Segment{d3dmath.Vec3{0, 0, 0}, d3dmath.Vec3{0, 0, 0}}, d3dmath.Vec3{}, Degenerate},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, res := test.seg.IntersectPlane(ground)
+			if res != test.res || !vecNear(p, test.want) {
+				t.Errorf("want %v at %v but have %v at %v", test.res, test.want, res, p)
+			}
+		})
+	}
+}
+
+func TestAABBDistance(t *testing.T) {
+	box := AABB{Min: d3dmath.Vec3{0, 0, 0}, Max: d3dmath.Vec3{2, 1, 2}}
+	for _, test := range []struct {
+		p    d3dmath.Vec3
+		want float32
+	}{
+		{d3dmath.Vec3{1, 0.5, 1}, 0},
+		{d3dmath.Vec3{2, 1, 2}, 0},
+		{d3dmath.Vec3{5, 0.5, 1}, 3},
+		{d3dmath.Vec3{-3, 5, 1}, 5},
+	} {
+		if have := box.Distance(test.p); !near(have, test.want) {
+			t.Errorf("%v: want distance %v but have %v", test.p, test.want, have)
+		}
+		if box.Contains(test.p) != (test.want == 0) {
+			t.Errorf("%v: Contains should be %v", test.p, test.want == 0)
+		}
+	}
+}
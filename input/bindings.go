@@ -0,0 +1,356 @@
+// Package input maps keys, mouse and gamepad buttons to named actions. The
+// bindings can be changed by the player and are stored in a JSON file. Nothing
+// in here depends on the operating system, the front end translates its key
+// codes to Buttons and passes them to a Mapper. Gamepad sticks are turned into
+// analog game controls by a Gamepad.
+package input
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gonutz/ld40/game"
+)
+
+// Action is something the player can do, e.g. move forward or jump.
+type Action string
+
+const (
+	Forward     Action = "forward"
+	Back        Action = "back"
+	StrafeLeft  Action = "strafeLeft"
+	StrafeRight Action = "strafeRight"
+	Run         Action = "run"
+	Sneak       Action = "sneak"
+	Jump        Action = "jump"
+	Shoot       Action = "shoot"
+	Fullscreen  Action = "fullscreen"
+	Pause       Action = "pause"
+	Debug       Action = "debug"
+	Reload      Action = "reload"
+)
+
+// Actions lists all actions in the order they are shown to the player.
+var Actions = []Action{
+	Forward, Back, StrafeLeft, StrafeRight, Run, Sneak, Jump, Shoot,
+	Fullscreen, Pause, Debug, Reload,
+}
+
+// renamedActions maps action names from older files to their current name.
+// Escape used to quit the game right away, now it opens the pause menu.
+var renamedActions = map[Action]Action{
+	"quit": Pause,
+}
+
+// Valid tells whether a is one of Actions.
+func (a Action) Valid() bool {
+	for _, b := range Actions {
+		if a == b {
+			return true
+		}
+	}
+	return false
+}
+
+// GameKey returns the game control that the action controls. Actions that the
+// front end handles itself, like Fullscreen, Pause, Debug and Reload, have
+// none.
+func (a Action) GameKey() (game.Key, bool) {
+	switch a {
+	case Forward:
+		return game.KeyForward, true
+	case Back:
+		return game.KeyBackward, true
+	case StrafeLeft:
+		return game.KeyLeft, true
+	case StrafeRight:
+		return game.KeyRight, true
+	case Run:
+		return game.KeyRun, true
+	case Sneak:
+		return game.KeySneak, true
+	case Jump:
+		return game.KeyJump, true
+	case Shoot:
+		return game.KeyShoot, true
+	}
+	return 0, false
+}
+
+// Button is a key on the keyboard, a mouse button or a gamepad button. Letter
+// and digit keys are named by their character, e.g. "W" or "1", function keys
+// are "F1" to "F12". The other buttons are the constants below. A gamepad's
+// triggers count as buttons, see GamepadConfig.TriggerThreshold.
+type Button string
+
+const (
+	MouseLeft   Button = "MouseLeft"
+	MouseRight  Button = "MouseRight"
+	MouseMiddle Button = "MouseMiddle"
+	Space       Button = "Space"
+	Shift       Button = "Shift"
+	Control     Button = "Control"
+	Alt         Button = "Alt"
+	Escape      Button = "Escape"
+	Enter       Button = "Enter"
+	Tab         Button = "Tab"
+	Backspace   Button = "Backspace"
+	Up          Button = "Up"
+	Down        Button = "Down"
+	Left        Button = "Left"
+	Right       Button = "Right"
+
+	PadA             Button = "PadA"
+	PadB             Button = "PadB"
+	PadX             Button = "PadX"
+	PadY             Button = "PadY"
+	PadUp            Button = "PadUp"
+	PadDown          Button = "PadDown"
+	PadLeft          Button = "PadLeft"
+	PadRight         Button = "PadRight"
+	PadStart         Button = "PadStart"
+	PadBack          Button = "PadBack"
+	PadLeftThumb     Button = "PadLeftThumb"
+	PadRightThumb    Button = "PadRightThumb"
+	PadLeftShoulder  Button = "PadLeftShoulder"
+	PadRightShoulder Button = "PadRightShoulder"
+	PadLeftTrigger   Button = "PadLeftTrigger"
+	PadRightTrigger  Button = "PadRightTrigger"
+)
+
+var namedButtons = []Button{
+	MouseLeft, MouseRight, MouseMiddle, Space, Shift, Control, Alt, Escape,
+	Enter, Tab, Backspace, Up, Down, Left, Right,
+	PadA, PadB, PadX, PadY, PadUp, PadDown, PadLeft, PadRight, PadStart,
+	PadBack, PadLeftThumb, PadRightThumb, PadLeftShoulder, PadRightShoulder,
+	PadLeftTrigger, PadRightTrigger,
+}
+
+// Valid tells whether b is a button name that the game knows.
+func (b Button) Valid() bool {
+	if len(b) == 1 {
+		c := b[0]
+		return 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+	}
+	for i := 1; i <= 12; i++ {
+		if b == FunctionKey(i) {
+			return true
+		}
+	}
+	for _, n := range namedButtons {
+		if b == n {
+			return true
+		}
+	}
+	return false
+}
+
+// FunctionKey returns the button F1 to F12.
+func FunctionKey(n int) Button {
+	return Button(fmt.Sprintf("F%d", n))
+}
+
+// Bindings says which buttons trigger which action. An action can have any
+// number of buttons, but each button should only trigger one action, see
+// Conflicts.
+type Bindings map[Action][]Button
+
+// DefaultBindings returns the controls that the game starts with.
+func DefaultBindings() Bindings {
+	return Bindings{
+		Forward:     {"W"},
+		Back:        {"S"},
+		StrafeLeft:  {"A"},
+		StrafeRight: {"D"},
+		Run:         {Shift, PadRightShoulder},
+		Sneak:       {Control, PadLeftShoulder},
+		Jump:        {Space, PadA},
+		Shoot:       {MouseLeft, PadLeftTrigger, PadRightTrigger},
+		Fullscreen:  {FunctionKey(11)},
+		Pause:       {Escape, PadStart},
+		Debug:       {FunctionKey(3), PadBack},
+		Reload:      {FunctionKey(5)},
+	}
+}
+
+// Conflict is a button that is bound to more than one action.
+type Conflict struct {
+	Button  Button
+	Actions []Action
+}
+
+func (c Conflict) String() string {
+	actions := make([]string, len(c.Actions))
+	for i := range c.Actions {
+		actions[i] = string(c.Actions[i])
+	}
+	return fmt.Sprintf("%s is bound to %s", c.Button, strings.Join(actions, " and "))
+}
+
+// ConflictError is returned when bindings would have Conflicts.
+type ConflictError []Conflict
+
+func (e ConflictError) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].String()
+	}
+	return "input: " + strings.Join(msgs, ", ")
+}
+
+// Conflicts returns all buttons that trigger more than one action, sorted by
+// button name. The actions are in the order of Actions.
+func (b Bindings) Conflicts() []Conflict {
+	actions := map[Button][]Action{}
+	for _, a := range Actions {
+		for _, button := range unique(b[a]) {
+			actions[button] = append(actions[button], a)
+		}
+	}
+	var conflicts []Conflict
+	for button, a := range actions {
+		if len(a) > 1 {
+			conflicts = append(conflicts, Conflict{Button: button, Actions: a})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Button < conflicts[j].Button
+	})
+	return conflicts
+}
+
+func unique(buttons []Button) []Button {
+	var u []Button
+	for _, b := range buttons {
+		dup := false
+		for _, have := range u {
+			dup = dup || have == b
+		}
+		if !dup {
+			u = append(u, b)
+		}
+	}
+	return u
+}
+
+// Bind sets the buttons for an action. If one of them is already bound to a
+// different action, the bindings stay unchanged and a ConflictError is
+// returned.
+func (b Bindings) Bind(a Action, buttons ...Button) error {
+	if !a.Valid() {
+		return fmt.Errorf("input: unknown action %q", a)
+	}
+	for _, button := range buttons {
+		if !button.Valid() {
+			return fmt.Errorf("input: unknown button %q for %s", button, a)
+		}
+	}
+	changed := b.copy()
+	changed[a] = append([]Button{}, buttons...)
+	if c := changed.Conflicts(); len(c) > 0 {
+		return ConflictError(c)
+	}
+	b[a] = changed[a]
+	return nil
+}
+
+func (b Bindings) copy() Bindings {
+	c := make(Bindings, len(b))
+	for a, buttons := range b {
+		c[a] = append([]Button(nil), buttons...)
+	}
+	return c
+}
+
+// Load reads bindings in JSON format, an object with actions as keys and lists
+// of button names as values, e.g.
+//
+//	{
+//		"forward": ["W", "Up"],
+//		"jump": ["Space", "MouseRight"]
+//	}
+//
+// Actions that are not in the file keep their default buttons, an empty list
+// unbinds an action. Actions that were renamed can be given by their old name.
+// Unknown names and conflicts are errors.
+func Load(r io.Reader) (Bindings, error) {
+	var file map[Action][]Button
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("input: %v", err)
+	}
+	b := DefaultBindings()
+	for a, buttons := range file {
+		if renamed, ok := renamedActions[a]; ok {
+			if _, ok := file[renamed]; ok {
+				continue
+			}
+			a = renamed
+		}
+		if !a.Valid() {
+			return nil, fmt.Errorf("input: unknown action %q", a)
+		}
+		for _, button := range buttons {
+			if !button.Valid() {
+				return nil, fmt.Errorf("input: unknown button %q for %s", button, a)
+			}
+		}
+		b[a] = buttons
+	}
+	if c := b.Conflicts(); len(c) > 0 {
+		return nil, ConflictError(c)
+	}
+	return b, nil
+}
+
+// Save writes the bindings in the format that Load reads.
+func (b Bindings) Save(w io.Writer) error {
+	file := make(map[Action][]Button, len(Actions))
+	for _, a := range Actions {
+		file[a] = b[a]
+		if file[a] == nil {
+			file[a] = []Button{}
+		}
+	}
+	data, err := json.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// LoadFile loads bindings from the given file. If it does not exist, the
+// default bindings are returned.
+func LoadFile(path string) (Bindings, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultBindings(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return b, nil
+}
+
+// SaveFile writes the bindings to the given file, replacing it.
+func (b Bindings) SaveFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := b.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
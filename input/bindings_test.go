@@ -0,0 +1,190 @@
+package input
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/ld40/game"
+)
+
+func TestDefaultBindingsHaveNoConflicts(t *testing.T) {
+	b := DefaultBindings()
+	if c := b.Conflicts(); len(c) != 0 {
+		t.Errorf("default bindings have conflicts: %v", c)
+	}
+	for _, a := range Actions {
+		if len(b[a]) == 0 {
+			t.Errorf("%s has no default button", a)
+		}
+		for _, button := range b[a] {
+			if !button.Valid() {
+				t.Errorf("%s: invalid default button %q", a, button)
+			}
+		}
+	}
+}
+
+func TestValidButtons(t *testing.T) {
+	for _, b := range []Button{"A", "Z", "0", "9", "F1", "F12", Space, MouseLeft} {
+		if !b.Valid() {
+			t.Errorf("%q should be valid", b)
+		}
+	}
+	for _, b := range []Button{"", "a", "F0", "F13", "AB", "Mouse", "space"} {
+		if b.Valid() {
+			t.Errorf("%q should not be valid", b)
+		}
+	}
+}
+
+func TestGameKeys(t *testing.T) {
+	keys := map[game.Key]bool{}
+	for _, a := range Actions {
+		if k, ok := a.GameKey(); ok {
+			keys[k] = true
+		}
+	}
+	if len(keys) != int(game.KeyCount) {
+		t.Errorf("want actions for all %d game keys but have %d", game.KeyCount, len(keys))
+	}
+	if _, ok := Pause.GameKey(); ok {
+		t.Error("pause is not a game key")
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	b := DefaultBindings()
+	b[Jump] = []Button{Space, "W"}
+	b[Shoot] = []Button{"W", MouseLeft, MouseLeft}
+	want := []Conflict{{Button: "W", Actions: []Action{Forward, Jump, Shoot}}}
+	if have := b.Conflicts(); !reflect.DeepEqual(have, want) {
+		t.Errorf("want conflicts %v but have %v", want, have)
+	}
+}
+
+func TestBind(t *testing.T) {
+	b := DefaultBindings()
+	if err := b.Bind(Forward, "W", Up); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b[Forward], []Button{"W", Up}) {
+		t.Errorf("forward should be W and Up but is %v", b[Forward])
+	}
+
+	err := b.Bind(Jump, "S")
+	var conflict ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("want conflict error but have %v", err)
+	}
+	if len(conflict) != 1 || conflict[0].Button != "S" {
+		t.Errorf("want conflict for S but have %v", conflict)
+	}
+	if !reflect.DeepEqual(b[Jump], DefaultBindings()[Jump]) {
+		t.Errorf("conflicting binding must not change jump but it is %v", b[Jump])
+	}
+
+	if err := b.Bind("dance", "X"); err == nil {
+		t.Error("unknown action must be an error")
+	}
+	if err := b.Bind(Jump, "space"); err == nil {
+		t.Error("unknown button must be an error")
+	}
+}
+
+func TestLoadKeepsDefaultsForMissingActions(t *testing.T) {
+	b, err := Load(strings.NewReader(`{"forward": ["Up"], "sneak": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b[Forward], []Button{Up}) {
+		t.Errorf("want forward on Up but have %v", b[Forward])
+	}
+	if len(b[Sneak]) != 0 {
+		t.Errorf("sneak should be unbound but is %v", b[Sneak])
+	}
+	if !reflect.DeepEqual(b[Jump], DefaultBindings()[Jump]) {
+		t.Errorf("jump should have its default binding but has %v", b[Jump])
+	}
+}
+
+func TestLoadRenamedActions(t *testing.T) {
+	b, err := Load(strings.NewReader(`{"quit": ["Q"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b[Pause], []Button{"Q"}) {
+		t.Errorf("quit should now be pause on Q but pause is %v", b[Pause])
+	}
+	if _, ok := b["quit"]; ok {
+		t.Error("the old action name should be gone")
+	}
+
+	b, err = Load(strings.NewReader(`{"quit": ["Q"], "pause": ["P"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b[Pause], []Button{"P"}) {
+		t.Errorf("the new name should win, pause is %v", b[Pause])
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, test := range []struct {
+		file, msg string
+	}{
+		{`{"forward": ["W"`, "unexpected EOF"},
+		{`{"dance": ["X"]}`, `unknown action "dance"`},
+		{`{"jump": ["Space", "Shft"]}`, `unknown button "Shft" for jump`},
+		{`{"jump": ["W"]}`, "W is bound to forward and jump"},
+	} {
+		_, err := Load(strings.NewReader(test.file))
+		if err == nil || !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("%s: want error containing %q but have %v", test.file, test.msg, err)
+		}
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	b := DefaultBindings()
+	if err := b.Bind(Shoot, MouseLeft, "E"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Bind(Sneak); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := b.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, b) {
+		t.Errorf("want %v after loading but have %v", b, loaded)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "controls.json")
+	b, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b, DefaultBindings()) {
+		t.Errorf("missing file should give the defaults but has %v", b)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"jump": ["X", "X", "MouseLeft"]}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadFile(path)
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("want error mentioning the file but have %v", err)
+	}
+}
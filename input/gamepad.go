@@ -0,0 +1,232 @@
+package input
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/gonutz/ld40/game"
+)
+
+// GamepadState is a snapshot of a gamepad, independent of the API that reads
+// it. A disconnected gamepad is the zero GamepadState.
+type GamepadState struct {
+	// LeftX, LeftY, RightX and RightY are the sticks, from -1 to 1. Positive
+	// is right and up.
+	LeftX, LeftY   float32
+	RightX, RightY float32
+	// LeftTrigger and RightTrigger are from 0 (released) to 1 (fully pressed).
+	LeftTrigger, RightTrigger float32
+	// Buttons are the buttons held down, except for the triggers.
+	Buttons []Button
+}
+
+// GamepadConfig says how a gamepad's sticks and triggers are turned into game
+// controls. The player can change it in a JSON file, see LoadGamepadFile.
+type GamepadConfig struct {
+	// MoveDeadZone and LookDeadZone are the parts of the left and right
+	// stick's range, from 0 to 1, that are ignored. Sticks do not return to
+	// exactly 0 when released.
+	MoveDeadZone float32 `json:"moveDeadZone"`
+	LookDeadZone float32 `json:"lookDeadZone"`
+	// MoveCurve and LookCurve are the exponents applied to how far a stick is
+	// pushed, after the dead zone. 1 is linear, higher values give more
+	// precision for small movements.
+	MoveCurve float32 `json:"moveCurve"`
+	LookCurve float32 `json:"lookCurve"`
+	// LookSpeed is how far the view turns in every step when the right stick
+	// is pushed all the way, in mouse pixels.
+	LookSpeed float32 `json:"lookSpeed"`
+	// TriggerThreshold is how far a trigger has to be pressed to count as the
+	// button PadLeftTrigger or PadRightTrigger.
+	TriggerThreshold float32 `json:"triggerThreshold"`
+}
+
+// DefaultGamepadConfig returns dead zones that work for typical XInput
+// controllers.
+func DefaultGamepadConfig() GamepadConfig {
+	return GamepadConfig{
+		MoveDeadZone:     0.24,
+		LookDeadZone:     0.265,
+		MoveCurve:        1,
+		LookCurve:        2,
+		LookSpeed:        16,
+		TriggerThreshold: 0.12,
+	}
+}
+
+// Validate returns an error for a configuration that makes the gamepad
+// unusable.
+func (c GamepadConfig) Validate() error {
+	switch {
+	case c.MoveDeadZone < 0 || c.MoveDeadZone >= 1 || c.LookDeadZone < 0 || c.LookDeadZone >= 1:
+		return fmt.Errorf("dead zones must be from 0 to below 1 but are %v and %v", c.MoveDeadZone, c.LookDeadZone)
+	case c.MoveCurve <= 0 || c.LookCurve <= 0:
+		return fmt.Errorf("curves must be positive but are %v and %v", c.MoveCurve, c.LookCurve)
+	case c.LookSpeed <= 0:
+		return fmt.Errorf("look speed must be positive but is %v", c.LookSpeed)
+	case c.TriggerThreshold <= 0 || c.TriggerThreshold > 1:
+		return fmt.Errorf("trigger threshold must be above 0 and at most 1 but is %v", c.TriggerThreshold)
+	}
+	return nil
+}
+
+// LoadGamepadConfig reads a GamepadConfig in JSON format, e.g.
+//
+//	{"lookDeadZone": 0.2, "lookCurve": 1.5}
+//
+// Values that are not in the file keep their defaults. Unknown names and
+// invalid values are errors.
+func LoadGamepadConfig(r io.Reader) (GamepadConfig, error) {
+	c := DefaultGamepadConfig()
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return GamepadConfig{}, fmt.Errorf("input: %v", err)
+	}
+	if err := c.Validate(); err != nil {
+		return GamepadConfig{}, fmt.Errorf("input: %v", err)
+	}
+	return c, nil
+}
+
+// Save writes the configuration in the format that LoadGamepadConfig reads.
+func (c GamepadConfig) Save(w io.Writer) error {
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// LoadGamepadFile loads the gamepad configuration from the given file. If it
+// does not exist, the default configuration is returned.
+func LoadGamepadFile(path string) (GamepadConfig, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultGamepadConfig(), nil
+	}
+	if err != nil {
+		return GamepadConfig{}, err
+	}
+	defer f.Close()
+	c, err := LoadGamepadConfig(f)
+	if err != nil {
+		return GamepadConfig{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// SaveFile writes the configuration to the given file, replacing it.
+func (c GamepadConfig) SaveFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := c.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Move returns the analog movement for the left stick at x, y, see
+// game.AxisForward and game.AxisRight.
+func (c GamepadConfig) Move(x, y float32) (forward, right float32) {
+	right, forward = stick(x, y, c.MoveDeadZone, c.MoveCurve)
+	return
+}
+
+// Look returns the view rotation for the right stick at x, y, see
+// game.AxisTurnX and game.AxisTurnY. Pushing the stick up looks up.
+func (c GamepadConfig) Look(x, y float32) (turnX, turnY float32) {
+	x, y = stick(x, y, c.LookDeadZone, c.LookCurve)
+	return x * c.LookSpeed, -y * c.LookSpeed
+}
+
+// stick applies a radial dead zone and response curve to a stick position. The
+// direction is kept and the distance from the center is rescaled so it starts
+// at 0 right outside the dead zone and reaches 1 at the stick's edge.
+func stick(x, y, deadZone, curve float32) (float32, float32) {
+	length := float32(math.Hypot(float64(x), float64(y)))
+	if length <= deadZone || deadZone >= 1 {
+		return 0, 0
+	}
+	scaled := (min(length, 1) - deadZone) / (1 - deadZone)
+	if curve > 0 {
+		scaled = float32(math.Pow(float64(scaled), float64(curve)))
+	}
+	return x / length * scaled, y / length * scaled
+}
+
+func min(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Gamepad turns gamepad snapshots into changes: buttons going up and down,
+// which are passed on to a Mapper, and analog game controls.
+type Gamepad struct {
+	Config GamepadConfig
+	down   map[Button]bool
+	axes   [game.AxisCount]float32
+}
+
+// NewGamepad creates a Gamepad with no buttons down and the sticks centered.
+func NewGamepad(c GamepadConfig) *Gamepad {
+	return &Gamepad{Config: c, down: map[Button]bool{}}
+}
+
+// GamepadChange is the difference between two gamepad snapshots.
+type GamepadChange struct {
+	Pressed, Released []Button
+	// Events are AxisMove events for the analog controls that changed.
+	Events []game.Event
+}
+
+// Update returns what changed since the last update.
+func (g *Gamepad) Update(s GamepadState) GamepadChange {
+	down := map[Button]bool{}
+	for _, b := range s.Buttons {
+		down[b] = true
+	}
+	if s.LeftTrigger >= g.Config.TriggerThreshold && s.LeftTrigger > 0 {
+		down[PadLeftTrigger] = true
+	}
+	if s.RightTrigger >= g.Config.TriggerThreshold && s.RightTrigger > 0 {
+		down[PadRightTrigger] = true
+	}
+
+	var change GamepadChange
+	for _, b := range namedButtons {
+		if down[b] && !g.down[b] {
+			change.Pressed = append(change.Pressed, b)
+		}
+		if !down[b] && g.down[b] {
+			change.Released = append(change.Released, b)
+		}
+	}
+	g.down = down
+
+	var axes [game.AxisCount]float32
+	axes[game.AxisForward], axes[game.AxisRight] = g.Config.Move(s.LeftX, s.LeftY)
+	axes[game.AxisTurnX], axes[game.AxisTurnY] = g.Config.Look(s.RightX, s.RightY)
+	for i := range axes {
+		if axes[i] != g.axes[i] {
+			change.Events = append(change.Events, game.Event{
+				Kind:  game.AxisMove,
+				Axis:  game.Axis(i),
+				Value: axes[i],
+			})
+		}
+	}
+	g.axes = axes
+
+	return change
+}
@@ -0,0 +1,151 @@
+package input
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/ld40/game"
+)
+
+func near(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-5
+}
+
+func TestStickToMovement(t *testing.T) {
+	c := GamepadConfig{MoveDeadZone: 0.2, MoveCurve: 1}
+	for _, test := range []struct {
+		name           string
+		x, y           float32
+		forward, right float32
+	}{
+		{"centered", 0, 0, 0, 0},
+		{"inside dead zone", 0.1, -0.15, 0, 0},
+		{"edge of dead zone", 0, 0.2, 0, 0},
+		{"half way up", 0, 0.6, 0.5, 0},
+		{"full forward", 0, 1, 1, 0},
+		{"full back", 0, -1, -1, 0},
+		{"full right", 1, 0, 0, 1},
+		{"diagonal keeps direction", 0.6, 0.8, 0.8, 0.6},
+		{"corner is clamped", 1, 1, float32(math.Sqrt(0.5)), float32(math.Sqrt(0.5))},
+	} {
+		forward, right := c.Move(test.x, test.y)
+		if !near(forward, test.forward) || !near(right, test.right) {
+			t.Errorf("%s: want %v, %v but have %v, %v",
+				test.name, test.forward, test.right, forward, right)
+		}
+	}
+}
+
+func TestResponseCurve(t *testing.T) {
+	c := GamepadConfig{MoveDeadZone: 0, MoveCurve: 2}
+	if forward, _ := c.Move(0, 0.5); !near(forward, 0.25) {
+		t.Errorf("quadratic curve: want 0.25 at half way but have %v", forward)
+	}
+	if forward, _ := c.Move(0, 1); !near(forward, 1) {
+		t.Errorf("quadratic curve: want 1 at full tilt but have %v", forward)
+	}
+}
+
+func TestStickToLook(t *testing.T) {
+	c := GamepadConfig{LookDeadZone: 0.2, LookCurve: 1, LookSpeed: 10}
+	x, y := c.Look(1, 0)
+	if !near(x, 10) || !near(y, 0) {
+		t.Errorf("right: want 10, 0 but have %v, %v", x, y)
+	}
+	// the mouse moving up, i.e. negative y, looks up
+	x, y = c.Look(0, 1)
+	if !near(x, 0) || !near(y, -10) {
+		t.Errorf("up: want 0, -10 but have %v, %v", x, y)
+	}
+	if x, y := c.Look(0.1, 0.1); x != 0 || y != 0 {
+		t.Errorf("dead zone: want 0, 0 but have %v, %v", x, y)
+	}
+}
+
+func TestGamepadUpdate(t *testing.T) {
+	c := DefaultGamepadConfig()
+	c.MoveCurve = 1
+	g := NewGamepad(c)
+
+	change := g.Update(GamepadState{
+		LeftY:        1,
+		RightTrigger: 0.5,
+		LeftTrigger:  c.TriggerThreshold / 2,
+		Buttons:      []Button{PadA},
+	})
+	if want := []Button{PadA, PadRightTrigger}; !reflect.DeepEqual(change.Pressed, want) {
+		t.Errorf("want %v pressed but have %v", want, change.Pressed)
+	}
+	if len(change.Released) != 0 {
+		t.Errorf("nothing should be released but have %v", change.Released)
+	}
+	want := []game.Event{{Kind: game.AxisMove, Axis: game.AxisForward, Value: 1}}
+	if !reflect.DeepEqual(change.Events, want) {
+		t.Errorf("want events %v but have %v", want, change.Events)
+	}
+
+	change = g.Update(GamepadState{LeftY: 1, RightTrigger: 0.5, Buttons: []Button{PadA}})
+	if len(change.Pressed)+len(change.Released)+len(change.Events) != 0 {
+		t.Errorf("same state should not change anything but have %+v", change)
+	}
+
+	// a disconnected gamepad releases everything
+	change = g.Update(GamepadState{})
+	if want := []Button{PadA, PadRightTrigger}; !reflect.DeepEqual(change.Released, want) {
+		t.Errorf("want %v released but have %v", want, change.Released)
+	}
+	want = []game.Event{{Kind: game.AxisMove, Axis: game.AxisForward, Value: 0}}
+	if !reflect.DeepEqual(change.Events, want) {
+		t.Errorf("want events %v but have %v", want, change.Events)
+	}
+}
+
+func TestLoadGamepadConfig(t *testing.T) {
+	c, err := LoadGamepadConfig(strings.NewReader(`{"lookSpeed": 20, "moveCurve": 1.5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultGamepadConfig()
+	want.LookSpeed = 20
+	want.MoveCurve = 1.5
+	if c != want {
+		t.Errorf("want %+v but have %+v", want, c)
+	}
+
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := LoadGamepadConfig(&buf); err != nil || loaded != c {
+		t.Errorf("want %+v after saving but have %+v, %v", c, loaded, err)
+	}
+}
+
+func TestLoadGamepadConfigErrors(t *testing.T) {
+	for _, test := range []struct {
+		file, msg string
+	}{
+		{`{"lookSpeed": 20`, "unexpected EOF"},
+		{`{"lookSpead": 20}`, "unknown field"},
+		{`{"lookDeadZone": 1}`, "dead zones"},
+		{`{"moveCurve": 0}`, "curves"},
+		{`{"lookSpeed": -1}`, "look speed"},
+		{`{"triggerThreshold": 0}`, "trigger threshold"},
+	} {
+		_, err := LoadGamepadConfig(strings.NewReader(test.file))
+		if err == nil || !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("%s: want error containing %q but have %v", test.file, test.msg, err)
+		}
+	}
+}
+
+func TestMissingGamepadFileGivesDefaults(t *testing.T) {
+	c, err := LoadGamepadFile(filepath.Join(t.TempDir(), "gamepad.json"))
+	if err != nil || c != DefaultGamepadConfig() {
+		t.Errorf("want the defaults but have %+v, %v", c, err)
+	}
+}
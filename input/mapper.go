@@ -0,0 +1,77 @@
+package input
+
+// Mapper turns button presses and releases into actions starting and
+// stopping. An action is active while any of its buttons is held down, so
+// holding two buttons of the same action and releasing one keeps it active.
+type Mapper struct {
+	actions map[Button][]Action
+	down    map[Button]bool
+	active  map[Action]int
+}
+
+// NewMapper creates a Mapper with no buttons held down.
+func NewMapper(b Bindings) *Mapper {
+	m := &Mapper{
+		actions: map[Button][]Action{},
+		down:    map[Button]bool{},
+		active:  map[Action]int{},
+	}
+	for _, a := range Actions {
+		for _, button := range unique(b[a]) {
+			m.actions[button] = append(m.actions[button], a)
+		}
+	}
+	return m
+}
+
+// Press returns the actions that start because the button went down. Pressing
+// a button that is already down, e.g. by key repeat, starts nothing.
+func (m *Mapper) Press(b Button) []Action {
+	if m.down[b] {
+		return nil
+	}
+	m.down[b] = true
+	var started []Action
+	for _, a := range m.actions[b] {
+		m.active[a]++
+		if m.active[a] == 1 {
+			started = append(started, a)
+		}
+	}
+	return started
+}
+
+// Release returns the actions that stop because the button went up.
+func (m *Mapper) Release(b Button) []Action {
+	if !m.down[b] {
+		return nil
+	}
+	delete(m.down, b)
+	var stopped []Action
+	for _, a := range m.actions[b] {
+		m.active[a]--
+		if m.active[a] == 0 {
+			stopped = append(stopped, a)
+		}
+	}
+	return stopped
+}
+
+// Active tells whether any button of the action is held down.
+func (m *Mapper) Active(a Action) bool {
+	return m.active[a] > 0
+}
+
+// ReleaseAll stops all active actions and returns them, e.g. when the window
+// loses the focus and will not report the buttons going up.
+func (m *Mapper) ReleaseAll() []Action {
+	var stopped []Action
+	for _, a := range Actions {
+		if m.active[a] > 0 {
+			stopped = append(stopped, a)
+		}
+	}
+	m.down = map[Button]bool{}
+	m.active = map[Action]int{}
+	return stopped
+}
@@ -0,0 +1,35 @@
+package input
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMapperStartsAndStopsActions(t *testing.T) {
+	b := DefaultBindings()
+	b[Forward] = []Button{"W", Up}
+	m := NewMapper(b)
+
+	check := func(what string, have, want []Action) {
+		t.Helper()
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("%s: want %v but have %v", what, want, have)
+		}
+	}
+	check("press W", m.Press("W"), []Action{Forward})
+	check("repeat W", m.Press("W"), nil)
+	check("press Up", m.Press(Up), nil)
+	check("release W", m.Release("W"), nil)
+	if !m.Active(Forward) {
+		t.Error("forward should still be active while Up is held")
+	}
+	check("release Up", m.Release(Up), []Action{Forward})
+	check("release Up again", m.Release(Up), nil)
+	check("unbound button", m.Press("Q"), nil)
+	check("mouse", m.Press(MouseLeft), []Action{Shoot})
+	check("release all", m.ReleaseAll(), []Action{Shoot})
+	if m.Active(Shoot) {
+		t.Error("shoot should not be active after releasing all")
+	}
+	check("press after release all", m.Press(MouseLeft), []Action{Shoot})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/gonutz/ld40/input"
+	"github.com/gonutz/ld40/menu"
+	"github.com/gonutz/w32/v2"
+)
+
+// keyButton translates a virtual key code to the button name that the
+// bindings use.
+func keyButton(vk uintptr) (input.Button, bool) {
+	switch {
+	case 'A' <= vk && vk <= 'Z', '0' <= vk && vk <= '9':
+		return input.Button(rune(vk)), true
+	case w32.VK_F1 <= vk && vk <= w32.VK_F12:
+		return input.FunctionKey(int(vk-w32.VK_F1) + 1), true
+	}
+	switch vk {
+	case w32.VK_SPACE:
+		return input.Space, true
+	case w32.VK_SHIFT:
+		return input.Shift, true
+	case w32.VK_CONTROL:
+		return input.Control, true
+	case w32.VK_MENU:
+		return input.Alt, true
+	case w32.VK_ESCAPE:
+		return input.Escape, true
+	case w32.VK_RETURN:
+		return input.Enter, true
+	case w32.VK_TAB:
+		return input.Tab, true
+	case w32.VK_BACK:
+		return input.Backspace, true
+	case w32.VK_UP:
+		return input.Up, true
+	case w32.VK_DOWN:
+		return input.Down, true
+	case w32.VK_LEFT:
+		return input.Left, true
+	case w32.VK_RIGHT:
+		return input.Right, true
+	}
+	return "", false
+}
+
+// menuKey returns the menu navigation key for a button. The arrow keys, WASD
+// and the gamepad's d-pad move through the menu.
+func menuKey(b input.Button) (menu.Key, bool) {
+	switch b {
+	case input.Up, "W", input.PadUp:
+		return menu.Up, true
+	case input.Down, "S", input.PadDown:
+		return menu.Down, true
+	case input.Left, "A", input.PadLeft:
+		return menu.Left, true
+	case input.Right, "D", input.PadRight:
+		return menu.Right, true
+	case input.Enter, input.Space, input.PadA:
+		return menu.Enter, true
+	case input.Escape, input.Backspace, input.PadB, input.PadStart:
+		return menu.Back, true
+	}
+	return 0, false
+}
+
+// mouseButton translates a mouse button message to the button it is about and
+// whether the button went down.
+func mouseButton(msg uint32) (b input.Button, down, ok bool) {
+	switch msg {
+	case w32.WM_LBUTTONDOWN:
+		return input.MouseLeft, true, true
+	case w32.WM_LBUTTONUP:
+		return input.MouseLeft, false, true
+	case w32.WM_RBUTTONDOWN:
+		return input.MouseRight, true, true
+	case w32.WM_RBUTTONUP:
+		return input.MouseRight, false, true
+	case w32.WM_MBUTTONDOWN:
+		return input.MouseMiddle, true, true
+	case w32.WM_MBUTTONUP:
+		return input.MouseMiddle, false, true
+	}
+	return "", false, false
+}
@@ -0,0 +1,267 @@
+// Package level loads level description files. A level is a JSON file that
+// names the height map and textures, tells where the player starts and which
+// props are placed in the world, e.g.:
+//
+//	{
+//		"heightMap": "heights.png",
+//		"scale": [0.25, 1.3, 0.25],
+//		"terrainTexture": "floor.png",
+//		"skyTexture": "sky.png",
+//		"spawn": [0, 0, 0],
+//		"viewDir": [0, 0, 1],
+//		"props": [
+//			{"texture": "texture.png", "position": [-3, 0, 0]}
+//		],
+//		"light": {
+//			"direction": [-0.7, -0.1, 0.7],
+//			"color": [1, 1, 1],
+//			"ambient": [0.5, 0.5, 0.5]
+//		},
+//		"fog": {"mode": "linear", "color": [0.7, 0.8, 0.9], "start": 5, "end": 30}
+//	}
+//
+// All file names are relative to the level file. The light is optional, see
+// DefaultLight, and there is no fog unless it is given.
+package level
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+)
+
+type Level struct {
+	HeightMap string `json:"heightMap"`
+	// HeightMapWidth is only needed for RAW height maps that are not square.
+	// If it is set, the height map is read as RAW data.
+	HeightMapWidth int          `json:"heightMapWidth,omitempty"`
+	Scale          d3dmath.Vec3 `json:"scale"`
+	TerrainTexture string       `json:"terrainTexture"`
+	SkyTexture     string       `json:"skyTexture"`
+	Spawn          d3dmath.Vec3 `json:"spawn"`
+	ViewDir        d3dmath.Vec3 `json:"viewDir"`
+	Props          []Prop       `json:"props"`
+	Light          Light        `json:"light"`
+	Fog            Fog          `json:"fog"`
+}
+
+// Prop is a textured triangle standing upright in the world. Its corners are
+// (0,0,0), (1,0,0) and (0,1,0) before it is scaled, rotated around the y-axis
+// and moved to Position.
+type Prop struct {
+	Texture  string       `json:"texture"`
+	Position d3dmath.Vec3 `json:"position"`
+	YawDeg   float32      `json:"yaw,omitempty"`
+	Scale    float32      `json:"scale,omitempty"` // 0 means 1
+	// UV are the texture coordinates of the three corners, if not given they
+	// are (0,1), (1,1) and (0,0).
+	UV *[3][2]float32 `json:"uv,omitempty"`
+}
+
+// DefaultUV are the texture coordinates of a Prop without UV.
+var DefaultUV = [3][2]float32{{0, 1}, {1, 1}, {0, 0}}
+
+// Light is a directional light like the sun, it lights the terrain.
+type Light struct {
+	// Direction is where the light shines to, it need not be unit length.
+	Direction d3dmath.Vec3 `json:"direction"`
+	// Color is the light on a surface that faces the light. Its intensity is
+	// part of the color, values above 1 make it brighter.
+	Color [3]float32 `json:"color"`
+	// Ambient is added everywhere, also on surfaces facing away from the
+	// light, e.g. [0.5, 0.5, 0.5] is white at half intensity.
+	Ambient [3]float32 `json:"ambient"`
+}
+
+// DefaultLight lights levels that have no light. Fields that a level's light
+// leaves out keep these values.
+var DefaultLight = Light{
+	Direction: d3dmath.Vec3{-0.7, -0.1, 0.7},
+	Color:     [3]float32{1, 1, 1},
+	Ambient:   [3]float32{0.5, 0.5, 0.5},
+}
+
+// FogMode is how the fog gets denser with the distance from the camera.
+type FogMode string
+
+const (
+	NoFog FogMode = ""
+	// LinearFog starts at Fog.Start and hides everything from Fog.End on.
+	LinearFog FogMode = "linear"
+	// ExpFog lets exp(-Fog.Density * distance) of a surface through.
+	ExpFog FogMode = "exp"
+)
+
+// Fog blends the terrain into Color with the distance from the camera.
+type Fog struct {
+	Mode    FogMode    `json:"mode"`
+	Color   [3]float32 `json:"color"`
+	Start   float32    `json:"start"`
+	End     float32    `json:"end"`
+	Density float32    `json:"density"`
+}
+
+// Visibility is how much of a surface at the given distance from the camera
+// is seen through the fog, from 1 for all of it to 0 for only fog.
+func (f Fog) Visibility(distance float32) float32 {
+	var v float32 = 1
+	switch f.Mode {
+	case LinearFog:
+		v = (f.End - distance) / (f.End - f.Start)
+	case ExpFog:
+		v = float32(math.Exp(float64(-f.Density * distance)))
+	}
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// Opener opens the files that a level refers to.
+type Opener func(path string) (io.ReadCloser, error)
+
+// DirOpener opens files relative to the given folder.
+func DirOpener(dir string) Opener {
+	return func(path string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, path))
+	}
+}
+
+// Parse reads and validates a level file.
+func Parse(r io.Reader) (*Level, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	l := Level{Light: DefaultLight}
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("level: %v", err)
+	}
+	if err := l.validate(); err != nil {
+		return nil, fmt.Errorf("level: %v", err)
+	}
+	return &l, nil
+}
+
+// Load opens and parses the level file with the given name.
+func Load(name string, open Opener) (*Level, error) {
+	f, err := open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	l, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return l, nil
+}
+
+func (l *Level) validate() error {
+	if l.HeightMap == "" {
+		return errors.New("heightMap is missing")
+	}
+	if l.TerrainTexture == "" {
+		return errors.New("terrainTexture is missing")
+	}
+	if l.SkyTexture == "" {
+		return errors.New("skyTexture is missing")
+	}
+	if l.Scale[0] <= 0 || l.Scale[1] <= 0 || l.Scale[2] <= 0 {
+		return fmt.Errorf("scale must be positive but is %v", l.Scale)
+	}
+	if l.ViewDir[0] == 0 && l.ViewDir[2] == 0 {
+		return errors.New("viewDir must not point straight up or down")
+	}
+	for i, p := range l.Props {
+		if p.Texture == "" {
+			return fmt.Errorf("prop %d has no texture", i)
+		}
+		if p.Scale < 0 {
+			return fmt.Errorf("prop %d has negative scale %v", i, p.Scale)
+		}
+	}
+	if l.Light.Direction == (d3dmath.Vec3{}) {
+		return errors.New("light direction must not be 0")
+	}
+	if negative(l.Light.Color) || negative(l.Light.Ambient) || negative(l.Fog.Color) {
+		return errors.New("light and fog colors must not be negative")
+	}
+	switch l.Fog.Mode {
+	case NoFog:
+	case LinearFog:
+		if l.Fog.End <= l.Fog.Start {
+			return fmt.Errorf("fog end %v must be after start %v", l.Fog.End, l.Fog.Start)
+		}
+	case ExpFog:
+		if l.Fog.Density <= 0 {
+			return fmt.Errorf("fog density must be positive but is %v", l.Fog.Density)
+		}
+	default:
+		return fmt.Errorf("unknown fog mode %q", l.Fog.Mode)
+	}
+	return nil
+}
+
+func negative(color [3]float32) bool {
+	return color[0] < 0 || color[1] < 0 || color[2] < 0
+}
+
+// LoadGround loads the height map and applies the level's scale.
+func (l *Level) LoadGround(open Opener) (game.HeightField, error) {
+	f, err := open(l.HeightMap)
+	if err != nil {
+		return game.HeightField{}, err
+	}
+	defer f.Close()
+	var ground game.HeightField
+	if l.HeightMapWidth != 0 {
+		var data []byte
+		data, err = ioutil.ReadAll(f)
+		if err != nil {
+			return game.HeightField{}, err
+		}
+		ground, err = game.HeightFieldFromRaw(data, l.HeightMapWidth)
+	} else {
+		ground, err = game.LoadHeightField(f, l.HeightMap)
+	}
+	if err != nil {
+		return game.HeightField{}, err
+	}
+	ground.Scale = l.Scale
+	return ground, nil
+}
+
+// NewWorld creates the game world with the player at the spawn point.
+func (l *Level) NewWorld(ground game.HeightField) *game.World {
+	w := game.NewWorld(ground)
+	w.Spawn = l.Spawn
+	w.Respawn()
+	w.SetViewDir(l.ViewDir)
+	w.PrevYaw, w.PrevPitch = w.Yaw, w.Pitch
+	return w
+}
+
+// Textures lists all texture files of the level, each one only once.
+func (l *Level) Textures() []string {
+	names := []string{l.SkyTexture, l.TerrainTexture}
+	have := map[string]bool{l.SkyTexture: true, l.TerrainTexture: true}
+	for _, p := range l.Props {
+		if !have[p.Texture] {
+			have[p.Texture] = true
+			names = append(names, p.Texture)
+		}
+	}
+	return names
+}
@@ -0,0 +1,107 @@
+package level
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+)
+
+const validLevel = `{
+	"heightMap": "heights.png",
+	"scale": [0.25, 1.3, 0.25],
+	"terrainTexture": "floor.png",
+	"skyTexture": "sky.png",
+	"spawn": [1, 0, 2],
+	"viewDir": [0, 0, 2],
+	"props": [
+		{"texture": "a.png", "position": [-3, 0, 0]},
+		{"texture": "floor.png", "position": [5, 0, 0]},
+		{"texture": "a.png", "position": [0, 0, 5]}
+	],
+	"light": {"color": [1, 0.9, 0.8]},
+	"fog": {"mode": "linear", "color": [0.5, 0.5, 0.5], "start": 10, "end": 20}
+}`
+
+func TestParseValidLevel(t *testing.T) {
+	l, err := Parse(strings.NewReader(validLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.HeightMap != "heights.png" || len(l.Props) != 3 {
+		t.Errorf("unexpected level %+v", l)
+	}
+	w := l.NewWorld(game.HeightField{
+		Heights: [][]float32{{0, 0}, {0, 0}},
+		Scale:   d3dmath.Vec3{1, 1, 1},
+	})
+	if w.Pos != l.Spawn {
+		t.Errorf("want player at %v but is at %v", l.Spawn, w.Pos)
+	}
+	if dir := w.ViewDir(); dir[2] != 1 {
+		t.Errorf("view direction should be normalized but is %v", dir)
+	}
+}
+
+func TestLightDefaultsAndFog(t *testing.T) {
+	l, err := Parse(strings.NewReader(validLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultLight
+	want.Color = [3]float32{1, 0.9, 0.8}
+	if l.Light != want {
+		t.Errorf("want light %v but have %v", want, l.Light)
+	}
+	for _, test := range []struct{ distance, want float32 }{
+		{0, 1}, {10, 1}, {15, 0.5}, {20, 0}, {50, 0},
+	} {
+		if v := l.Fog.Visibility(test.distance); v != test.want {
+			t.Errorf("want visibility %v at %v but have %v", test.want, test.distance, v)
+		}
+	}
+
+	exp := Fog{Mode: ExpFog, Density: 0.5}
+	if v := exp.Visibility(2); math.Abs(float64(v)-math.Exp(-1)) > 1e-6 {
+		t.Errorf("exponential fog at 2 should be 1/e but is %v", v)
+	}
+	if v := (Fog{}).Visibility(1000); v != 1 {
+		t.Errorf("no fog should hide nothing but visibility is %v", v)
+	}
+}
+
+func TestTexturesAreListedOnce(t *testing.T) {
+	l, err := Parse(strings.NewReader(validLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"sky.png", "floor.png", "a.png"}
+	have := l.Textures()
+	if strings.Join(have, ",") != strings.Join(want, ",") {
+		t.Errorf("want textures %v but have %v", want, have)
+	}
+}
+
+func TestInvalidLevelsAreErrors(t *testing.T) {
+	for _, test := range []struct{ replace, with, want string }{
+		{`"heights.png"`, `""`, "heightMap"},
+		{`[0.25, 1.3, 0.25]`, `[0.25, 0, 0.25]`, "scale"},
+		{`"sky.png"`, `""`, "skyTexture"},
+		{`[0, 0, 2]`, `[0, 1, 0]`, "viewDir"},
+		{`"texture": "a.png", "position": [-3`, `"texture": "", "position": [-3`, "prop 0"},
+		{`"spawn"`, `"spawn": 1, "x"`, "level"},
+		{`"light": {`, `"light": {"direction": [0, 0, 0], `, "light direction"},
+		{`"color": [1, 0.9`, `"color": [-1, 0.9`, "colors"},
+		{`"start": 10`, `"start": 20`, "fog end"},
+		{`"mode": "linear"`, `"mode": "exp"`, "fog density"},
+		{`"mode": "linear"`, `"mode": "thick"`, "fog mode"},
+	} {
+		code := strings.Replace(validLevel, test.replace, test.with, 1)
+		_, err := Parse(strings.NewReader(code))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("want error containing %q but have %v", test.want, err)
+		}
+	}
+}
@@ -2,14 +2,10 @@ package main
 
 import (
 	"bytes"
-	"errors"
+	"flag"
 	"fmt"
-	"image"
-	"image/draw"
-	"image/png"
 	"io"
 	"io/ioutil"
-	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -20,12 +16,21 @@ import (
 	"github.com/gonutz/blob"
 	"github.com/gonutz/d3d9"
 	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+	"github.com/gonutz/ld40/input"
+	"github.com/gonutz/ld40/level"
+	"github.com/gonutz/ld40/menu"
+	"github.com/gonutz/ld40/render"
+	"github.com/gonutz/ld40/replay"
+	"github.com/gonutz/ld40/settings"
+	"github.com/gonutz/ld40/vertex"
 	"github.com/gonutz/payload"
 	"github.com/gonutz/w32/v2"
 	"github.com/gonutz/win"
 )
 
-var windowW, windowH = 480, 320
+// windowW and windowH are the size of the window's drawing area
+var windowW, windowH int
 
 func main() {
 	defer handlePanics()
@@ -34,6 +39,76 @@ func main() {
 
 	win.HideConsoleWindow()
 
+	levelPath := flag.String("level", "level.json", "level file to play")
+	replayPath := flag.String("replay", "", "play back a recorded session file instead of live input")
+	overrides := settings.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	settingsPath := filepath.Join(os.Getenv("APPDATA"), "ld40_settings.json")
+	saved, err := settings.LoadFile(settingsPath)
+	// a broken settings file is not overwritten so the player can fix it, the
+	// game runs with the defaults in that case
+	saveSettings := err == nil
+	if err != nil {
+		reportError("The settings cannot be loaded", err)
+	}
+	// the command line only overrides the settings for this run, they are not
+	// saved
+	gameState.options = overrides.Apply(saved)
+	check(gameState.options.Validate())
+	windowW, windowH = gameState.options.Window.Width, gameState.options.Window.Height
+	rawMouse := gameState.options.Mouse.Raw
+
+	gameLevel, err = level.Load(*levelPath, open)
+	check(err)
+	// the files that the level names are relative to the level file
+	levelDir := filepath.Dir(*levelPath)
+	ground, err := gameLevel.LoadGround(func(name string) (io.ReadCloser, error) {
+		return open(filepath.Join(levelDir, name))
+	})
+	check(err)
+	world = gameLevel.NewWorld(ground)
+	gameState.options.Apply(world)
+
+	// replays record the settings that change the simulation, they are
+	// played back with those instead of the player's
+	if *replayPath != "" {
+		gameState.replay = loadReplay(*replayPath, world)
+	} else {
+		f, err := os.Create(filepath.Join(
+			os.Getenv("APPDATA"),
+			"ld40_replay_"+time.Now().Format("2006_01_02__15_04_05")+".bin",
+		))
+		check(err)
+		defer f.Close()
+		gameState.recorder, err = replay.NewWriter(f, replay.SettingsOf(world))
+		check(err)
+		defer gameState.recorder.Flush()
+	}
+
+	controlsPath := filepath.Join(os.Getenv("APPDATA"), "ld40_controls.json")
+	bindings, err := input.LoadFile(controlsPath)
+	if err != nil {
+		// a broken controls file is not overwritten so the player can fix it,
+		// the game runs with the default controls in that case
+		reportError("The controls cannot be loaded", err)
+		bindings = input.DefaultBindings()
+	} else {
+		// write the file, so players who want to change their controls find it
+		check(bindings.SaveFile(controlsPath))
+	}
+	gameState.buttons = input.NewMapper(bindings)
+
+	gamepadPath := filepath.Join(os.Getenv("APPDATA"), "ld40_gamepad.json")
+	gamepad, err := input.LoadGamepadFile(gamepadPath)
+	if err != nil {
+		reportError("The gamepad settings cannot be loaded", err)
+		gamepad = input.DefaultGamepadConfig()
+	} else {
+		check(gamepad.SaveFile(gamepadPath))
+	}
+	gameState.gamepad = input.NewGamepad(gamepad)
+
 	// the initial values for windowW and windowH describe the desired window
 	// client size, not the overall window size (which includes borders and a
 	// title bar) so initially calculate what the window size should be to get a
@@ -44,14 +119,17 @@ func main() {
 		windowH = int(r.Height())
 	}
 
+	gameState.menu = menu.New(menu.Options{})
+
 	var oldWindowPos w32.WINDOWPLACEMENT
 	toggleFullscreen := func(window w32.HWND) {
 		if win.IsFullscreen(window) {
 			win.DisableFullscreen(window, oldWindowPos)
-			w32.ShowCursor(false)
 		} else {
 			oldWindowPos = win.EnableFullscreen(window)
 		}
+		// the win functions show and hide the cursor
+		setCursorVisible(gameState.menu.IsOpen())
 	}
 
 	active := false
@@ -61,11 +139,96 @@ func main() {
 			return
 		}
 		gameState.centerX, gameState.centerY = w32.ClientToScreen(window, windowW/2, windowH/2)
-		gameState.mouseX = gameState.centerX
-		gameState.mouseY = gameState.centerY
+		if gameState.menu.IsOpen() {
+			// the mouse is free while the menu is open
+			return
+		}
+		if !rawMouse {
+			handleInput(game.Event{Kind: game.MouseMove, X: 0, Y: 0})
+		}
 		w32.SetCursorPos(gameState.centerX, gameState.centerY)
 	}
 
+	openMenu := func() {
+		// the player stops, buttons that are still held when the menu closes
+		// have to be pressed again
+		releaseActions(gameState.buttons.ReleaseAll())
+		gameState.menu.Options = menu.Options{
+			Sensitivity: gameState.options.Mouse.Sensitivity,
+			FieldOfView: gameState.options.Graphics.FieldOfView,
+			Fullscreen:  gameState.options.Window.Fullscreen,
+			Volume:      gameState.options.Audio.Volume,
+		}
+		gameState.menu.Open()
+		setCursorVisible(true)
+	}
+
+	handleMenu := func(window w32.HWND, r menu.Result) {
+		switch r {
+		case menu.Resume:
+			setCursorVisible(false)
+			computeScreenCenter(window)
+		case menu.Quit:
+			win.CloseWindow(window)
+		case menu.Changed:
+			// options from the menu are saved, unlike command line overrides
+			o := gameState.menu.Options
+			gameState.options.Mouse.Sensitivity = o.Sensitivity
+			saved.Mouse.Sensitivity = o.Sensitivity
+			if gameState.replay == nil {
+				// the replay sets the sensitivity that it was recorded with
+				world.LookSensitivity = o.Sensitivity
+				recordSettings()
+			}
+			gameState.options.Graphics.FieldOfView = o.FieldOfView
+			saved.Graphics.FieldOfView = o.FieldOfView
+			gameState.options.Audio.Volume = o.Volume
+			saved.Audio.Volume = o.Volume
+			if o.Fullscreen != win.IsFullscreen(window) {
+				toggleFullscreen(window)
+			}
+			gameState.options.Window.Fullscreen = o.Fullscreen
+			saved.Window.Fullscreen = o.Fullscreen
+		}
+	}
+
+	// res holds the Direct3D resources once the device is created
+	var res *resources
+
+	// pressButton handles a button going down. Auto-repeated key presses are
+	// ignored by the mapper, in the menu they move the selection and sliders
+	// but do not activate or leave anything.
+	pressButton := func(window w32.HWND, b input.Button, repeated bool) {
+		if gameState.menu.IsOpen() {
+			k, ok := menuKey(b)
+			if ok && !(repeated && (k == menu.Enter || k == menu.Back)) {
+				handleMenu(window, gameState.menu.Key(k))
+			}
+			return
+		}
+		for _, a := range gameState.buttons.Press(b) {
+			if key, ok := a.GameKey(); ok {
+				handleInput(game.Event{Kind: game.KeyDown, Key: key})
+			}
+			switch a {
+			case input.Pause:
+				openMenu()
+			case input.Fullscreen:
+				toggleFullscreen(window)
+			case input.Debug:
+				gameState.hud.ShowDebug = !gameState.hud.ShowDebug
+			case input.Reload:
+				if res != nil {
+					// a broken file keeps its old version, so the player
+					// can fix it and try again
+					if err := res.reloadAll(); err != nil {
+						reportError("The game data cannot be reloaded", err)
+					}
+				}
+			}
+		}
+	}
+
 	opts := win.DefaultOptions()
 	opts.Width = windowW
 	opts.Height = windowH
@@ -74,59 +237,73 @@ func main() {
 		opts,
 		func(window w32.HWND, msg uint32, w, l uintptr) uintptr {
 			switch msg {
+			case w32.WM_INPUT:
+				raw, ok := w32.GetRawInputData(w32.HRAWINPUT(l), w32.RID_INPUT)
+				if ok && active && !gameState.menu.IsOpen() &&
+					raw.Header.Type == w32.RIM_TYPEMOUSE {
+					m := raw.GetMouse()
+					if m.Flags&w32.MOUSE_MOVE_ABSOLUTE == 0 && (m.LastX != 0 || m.LastY != 0) {
+						handleInput(game.Event{
+							Kind: game.MouseDelta,
+							X:    int(m.LastX),
+							Y:    int(m.LastY),
+						})
+					}
+				}
+				// let Windows clean up the raw input buffer
+				return w32.DefWindowProc(window, msg, w, l)
 			case w32.WM_MOUSEMOVE:
+				if gameState.menu.IsOpen() {
+					x, y := mousePos(l)
+					gameState.menu.MouseMove(x, y)
+					return 0
+				}
+				if rawMouse {
+					// the cursor is only kept in the window, see updateGame
+					return 0
+				}
 				x := int((uint(l)) & 0xFFFF)
 				y := int((uint(l) >> 16) & 0xFFFF)
-				gameState.mouseX, gameState.mouseY = w32.ClientToScreen(window, x, y)
-				return 0
-			case w32.WM_LBUTTONDOWN:
-				gameState.keyShootDown = true
+				x, y = w32.ClientToScreen(window, x, y)
+				handleInput(game.Event{
+					Kind: game.MouseMove,
+					X:    x - gameState.centerX,
+					Y:    y - gameState.centerY,
+				})
 				return 0
-			case w32.WM_LBUTTONUP:
-				gameState.keyShootDown = false
-				return 0
-			case w32.WM_KEYDOWN:
-				if l&(1<<30) != 0 {
-					// if the key was down before, ignore it, no auto-repeat
+			case w32.WM_LBUTTONDOWN, w32.WM_LBUTTONUP,
+				w32.WM_RBUTTONDOWN, w32.WM_RBUTTONUP,
+				w32.WM_MBUTTONDOWN, w32.WM_MBUTTONUP:
+				b, down, _ := mouseButton(msg)
+				if gameState.menu.IsOpen() {
+					if msg == w32.WM_LBUTTONDOWN {
+						x, y := mousePos(l)
+						handleMenu(window, gameState.menu.Click(x, y))
+					}
 					return 0
 				}
-				switch w {
-				case 'W':
-					gameState.keyForwardDown = true
-				case 'S':
-					gameState.keyBackwardDown = true
-				case 'A':
-					gameState.keyLeftDown = true
-				case 'D':
-					gameState.keyRightDown = true
-				case w32.VK_SHIFT:
-					gameState.keyRunDown = true
-				case w32.VK_CONTROL:
-					gameState.keySneakDown = true
-				case w32.VK_SPACE:
-					gameState.keyJumpDown = true
-				case w32.VK_ESCAPE:
-					win.CloseWindow(window)
-				case w32.VK_F11:
-					toggleFullscreen(window)
+				if down {
+					pressButton(window, b, false)
+				} else {
+					releaseButton(b)
 				}
 				return 0
-			case w32.WM_KEYUP:
-				switch w {
-				case 'W':
-					gameState.keyForwardDown = false
-				case 'S':
-					gameState.keyBackwardDown = false
-				case 'A':
-					gameState.keyLeftDown = false
-				case 'D':
-					gameState.keyRightDown = false
-				case w32.VK_SHIFT:
-					gameState.keyRunDown = false
-				case w32.VK_CONTROL:
-					gameState.keySneakDown = false
-				case w32.VK_SPACE:
-					gameState.keyJumpDown = false
+			case w32.WM_KEYDOWN, w32.WM_SYSKEYDOWN:
+				if b, ok := keyButton(w); ok {
+					// bit 30 is set for auto-repeated key presses
+					pressButton(window, b, l&(1<<30) != 0)
+				}
+				if msg == w32.WM_SYSKEYDOWN {
+					// keep Alt+F4 and the like working
+					return w32.DefWindowProc(window, msg, w, l)
+				}
+				return 0
+			case w32.WM_KEYUP, w32.WM_SYSKEYUP:
+				if b, ok := keyButton(w); ok {
+					releaseButton(b)
+				}
+				if msg == w32.WM_SYSKEYUP {
+					return w32.DefWindowProc(window, msg, w, l)
 				}
 				return 0
 			case w32.WM_SIZE:
@@ -139,8 +316,23 @@ func main() {
 				return 0
 			case w32.WM_ACTIVATE:
 				active = w != 0
+				if !active {
+					// buttons released while inactive are not reported
+					releaseActions(gameState.buttons.ReleaseAll())
+					updateGamepad(input.GamepadState{}, nil)
+				}
 				return 0
 			case w32.WM_DESTROY:
+				// remember the window as the player left it, for the next run
+				fullscreen := win.IsFullscreen(window)
+				if fullscreen != gameState.options.Window.Fullscreen {
+					saved.Window.Fullscreen = fullscreen
+				}
+				if !fullscreen && windowW > 0 && windowH > 0 &&
+					(windowW != gameState.options.Window.Width ||
+						windowH != gameState.options.Window.Height) {
+					saved.Window.Width, saved.Window.Height = windowW, windowH
+				}
 				w32.PostQuitMessage(0)
 				return 0
 			default:
@@ -150,8 +342,20 @@ func main() {
 	)
 	check(err)
 	w32.SetWindowText(window, "LD 40 - The more you have, the worse it is")
+	if rawMouse {
+		// raw input reports the mouse motion itself, without the jitter of
+		// reading back cursor positions after re-centering the cursor
+		rawMouse = w32.RegisterRawInputDevices(w32.RAWINPUTDEVICE{
+			UsagePage: 0x01, // generic desktop controls
+			Usage:     0x02, // mouse
+			Target:    window,
+		})
+	}
 	win.SetIconFromExe(window, 10)
-	toggleFullscreen(window)
+	if gameState.options.Window.Fullscreen {
+		toggleFullscreen(window)
+	}
+	setCursorVisible(false)
 	computeScreenCenter(window)
 
 	d3d, err := d3d9.Create(d3d9.SDK_VERSION)
@@ -186,11 +390,6 @@ func main() {
 	check(err)
 	defer device.Release()
 
-	// NOTE comment this to switch between starting in fullscreen or not
-	//      this has to come after the presentParameters so the back buffer has
-	//      the size of the whole screen
-	//toggleFullscreen(window)
-
 	setRenderState := func(device *d3d9.Device) {
 		check(device.SetRenderState(d3d9.RS_CULLMODE, d3d9.CULL_CW))
 		check(device.SetRenderState(d3d9.RS_ALPHABLENDENABLE, 0))
@@ -203,31 +402,58 @@ func main() {
 	}
 	setRenderState(device)
 
-	createGeometry(device)
-	defer destroyGeometry()
+	terrain = render.NewTerrain(ground)
+	overlayFont, err = render.LoadFont("font.txt", open)
+	check(err)
+
+	res = newResources(device)
+	backend, err := newD3D9Backend(device, res, gameLevel, levelDir, terrain, overlayFont)
+	if err != nil {
+		w32.MessageBox(
+			window,
+			err.Error(),
+			"The game data cannot be loaded",
+			w32.MB_OK|w32.MB_ICONERROR|w32.MB_TOPMOST,
+		)
+		return
+	}
+	defer backend.release()
 
 	deviceIsLost := false
-	const frameDelay = time.Second / 60
+	// frameDelay only limits the rendering frame rate, the simulation always
+	// runs at game.TimeStep
+	frameDelay := gameState.options.Graphics.FrameDelay()
+	gameState.hud.Target = float32(frameDelay.Seconds())
 	lastFrame := time.Now().Add(-frameDelay)
 	win.RunMainGameLoop(func() {
 		now := time.Now()
 		if now.Sub(lastFrame) < frameDelay {
 			time.Sleep(time.Nanosecond)
 		} else {
+			elapsed := now.Sub(lastFrame)
 			lastFrame = now
+			gameState.hud.Frames.Add(float32(elapsed.Seconds()))
 
 			if active {
-				updateGame()
+				updateGamepad(readGamepad(), func(b input.Button) {
+					pressButton(window, b, false)
+				})
+				// the game is paused while the menu is open
+				if !gameState.menu.IsOpen() {
+					for n := gameState.clock.Advance(elapsed); n > 0; n-- {
+						updateGame(gameState.clock.Dt())
+					}
+				}
 			}
 
 			if deviceIsLost {
-				_, err = device.Reset(presentParameters)
-				if err == nil {
+				switch restoreDevice(device, presentParameters, res) {
+				case deviceReset:
 					deviceIsLost = false
 					setRenderState(device)
-					// TODO reset vertex buffers
-					// TODO reset vertex declarations
-					// TODO reset textures
+				case deviceLost:
+					// do not spin while e.g. minimized
+					time.Sleep(50 * time.Millisecond)
 				}
 			}
 
@@ -251,7 +477,7 @@ func main() {
 					0,
 				))
 				check(device.BeginScene())
-				renderGeometry(device)
+				renderGeometry(backend)
 				check(device.EndScene())
 				r := &d3d9.RECT{0, 0, int32(windowW), int32(windowH)}
 				presentErr := device.Present(r, r, 0, nil)
@@ -265,6 +491,10 @@ func main() {
 			}
 		}
 	})
+
+	if saveSettings {
+		check(saved.SaveFile(settingsPath))
+	}
 }
 
 func check(err error) {
@@ -302,889 +532,527 @@ func handlePanics() {
 	}
 }
 
-var (
-	// d3d9 assets
-	uniColorVS    *d3d9.VertexShader
-	uniColorPS    *d3d9.PixelShader
-	uniColorDecl  *d3d9.VertexDeclaration
-	texVS         *d3d9.VertexShader
-	texPS         *d3d9.PixelShader
-	texDecl       *d3d9.VertexDeclaration
-	texLitVS      *d3d9.VertexShader
-	texLitPS      *d3d9.PixelShader
-	texLitDecl    *d3d9.VertexDeclaration
-	vertices      *d3d9.VertexBuffer
-	triangles     *d3d9.VertexBuffer
-	texture       *d3d9.Texture
-	sky           *d3d9.Texture
-	skyVertices   *d3d9.VertexBuffer
-	floor         *d3d9.Texture
-	floorVertices *d3d9.VertexBuffer
-	square        *d3d9.VertexBuffer
-)
+// reportError tells the player about a problem that the game can run with,
+// e.g. a broken file that is replaced by defaults. The game has no console, so
+// the error is shown in a message box.
+func reportError(title string, err error) {
+	fmt.Println(title+":", err)
+	w32.MessageBox(
+		0,
+		err.Error(),
+		title,
+		w32.MB_OK|w32.MB_ICONWARNING|w32.MB_TOPMOST,
+	)
+}
 
-func destroyGeometry() {
-	if uniColorVS != nil {
-		uniColorVS.Release()
-		uniColorVS = nil
-	}
-	if uniColorPS != nil {
-		uniColorPS.Release()
-		uniColorPS = nil
-	}
-	if uniColorDecl != nil {
-		uniColorDecl.Release()
-		uniColorDecl = nil
-	}
-	if texVS != nil {
-		texVS.Release()
-		texVS = nil
-	}
-	if texPS != nil {
-		texPS.Release()
-		texPS = nil
-	}
-	if texDecl != nil {
-		texDecl.Release()
-		texDecl = nil
-	}
-	if texLitVS != nil {
-		texLitVS.Release()
-		texLitVS = nil
-	}
-	if texLitPS != nil {
-		texLitPS.Release()
-		texLitPS = nil
-	}
-	if texLitDecl != nil {
-		texLitDecl.Release()
-		texLitDecl = nil
-	}
-	if vertices != nil {
-		vertices.Release()
-		vertices = nil
-	}
-	if triangles != nil {
-		triangles.Release()
-		triangles = nil
-	}
-	if texture != nil {
-		texture.Release()
-		texture = nil
-	}
-	if sky != nil {
-		sky.Release()
-		sky = nil
-	}
-	if skyVertices != nil {
-		skyVertices.Release()
-		skyVertices = nil
-	}
-	if floor != nil {
-		floor.Release()
-		floor = nil
+// open reads a file from the data blob that build.bat attaches to the exe.
+// Files that are not in it, e.g. levels given with -level, and all files when
+// there is no blob are read from disk.
+func open(path string) (io.ReadCloser, error) {
+	data, err := payload.Open()
+	if err != nil {
+		return os.Open(path)
 	}
-	if floorVertices != nil {
-		floorVertices.Release()
-		floorVertices = nil
+	defer data.Close()
+	dataBlob, err := blob.Read(data)
+	if err != nil {
+		return nil, err
 	}
-	if square != nil {
-		square.Release()
-		square = nil
+	d, found := dataBlob.GetByID(path)
+	if !found {
+		return os.Open(path)
 	}
+	return dummyCloser{bytes.NewReader(d)}, nil
 }
 
-func createGeometry(device *d3d9.Device) {
-	var err error
-
-	uniColorVS, err = device.CreateVertexShaderFromBytes(vertexShader_uniform_color)
-	check(err)
-	uniColorPS, err = device.CreatePixelShaderFromBytes(pixelShader_uniform_color)
-	check(err)
-
-	uniColorDecl, err = device.CreateVertexDeclaration(
-		[]d3d9.VERTEXELEMENT{
-			d3d9.VERTEXELEMENT{
-				Stream:     0,
-				Offset:     0,
-				Type:       d3d9.DECLTYPE_FLOAT3,
-				Method:     d3d9.DECLMETHOD_DEFAULT,
-				Usage:      d3d9.DECLUSAGE_POSITION,
-				UsageIndex: 0,
-			},
-			d3d9.DeclEnd(),
-		},
-	)
-	check(err)
-
-	vertices = createVertexBuffer(device, []float32{
-		0, -0.5, 0,
-		1, -0.5, 0,
-		0, 0.5, 0,
-
-		5 + 0, -0.5, 0,
-		5 + 1, -0.5, 0,
-		5 + 0, 0.5, 0,
-	})
-
-	skyVertices = createVertexBuffer(device, []float32{
-		// top
-		-1, 1, 1,
-		0, 0.5,
-		1, 1, 1,
-		1.0 / 3, 0.5,
-		-1, 1, -1,
-		0, 0,
-
-		-1, 1, -1,
-		0, 0,
-		1, 1, 1,
-		1.0 / 3, 0.5,
-		1, 1, -1,
-		1.0 / 3, 0,
-
-		// bottom
-		-1, -1, -1,
-		1.0 / 3, 0.5,
-		1, -1, -1,
-		2.0 / 3, 0.5,
-		-1, -1, 1,
-		1.0 / 3, 0,
-
-		-1, -1, 1,
-		1.0 / 3, 0,
-		1, -1, -1,
-		2.0 / 3, 0.5,
-		1, -1, 1,
-		2.0 / 3, 0,
-
-		// left
-		-1, -1, 1,
-		0, 1,
-		1, -1, 1,
-		1.0 / 3, 1,
-		-1, 1, 1,
-		0, 0.5,
-
-		-1, 1, 1,
-		0, 0.5,
-		1, -1, 1,
-		1.0 / 3, 1,
-		1, 1, 1,
-		1.0 / 3, 0.5,
-
-		// front
-		-1, -1, -1,
-		2.0 / 3, 0.5,
-		-1, -1, 1,
-		1, 0.5,
-		-1, 1, -1,
-		2.0 / 3, 0,
-
-		-1, 1, -1,
-		2.0 / 3, 0,
-		-1, -1, 1,
-		1, 0.5,
-		-1, 1, 1,
-		1, 0,
-
-		// right
-		1, -1, 1,
-		1.0 / 3, 1,
-		1, -1, -1,
-		2.0 / 3, 1,
-		1, 1, 1,
-		1.0 / 3, 0.5,
-
-		1, 1, 1,
-		1.0 / 3, 0.5,
-		1, -1, -1,
-		2.0 / 3, 1,
-		1, 1, -1,
-		2.0 / 3, 0.5,
-
-		// back
-		1, -1, -1,
-		2.0 / 3, 1,
-		-1, -1, -1,
-		1, 1,
-		1, 1, -1,
-		2.0 / 3, 0.5,
-
-		1, 1, -1,
-		2.0 / 3, 0.5,
-		-1, -1, -1,
-		1, 1,
-		-1, 1, -1,
-		1, 0.5,
-	})
-
-	texVS, err = device.CreateVertexShaderFromBytes(vertexShader_texture)
-	check(err)
-	texPS, err = device.CreatePixelShaderFromBytes(pixelShader_texture)
-	check(err)
-
-	texDecl, err = device.CreateVertexDeclaration(
-		[]d3d9.VERTEXELEMENT{
-			d3d9.VERTEXELEMENT{
-				Stream:     0,
-				Offset:     0,
-				Type:       d3d9.DECLTYPE_FLOAT3,
-				Method:     d3d9.DECLMETHOD_DEFAULT,
-				Usage:      d3d9.DECLUSAGE_POSITION,
-				UsageIndex: 0,
-			},
-			d3d9.VERTEXELEMENT{
-				Stream:     0,
-				Offset:     3 * 4,
-				Type:       d3d9.DECLTYPE_FLOAT2,
-				Method:     d3d9.DECLMETHOD_DEFAULT,
-				Usage:      d3d9.DECLUSAGE_TEXCOORD,
-				UsageIndex: 0,
-			},
-			d3d9.DeclEnd(),
-		},
-	)
-	check(err)
-
-	texLitVS, err = device.CreateVertexShaderFromBytes(vertexShader_texture_lit)
-	check(err)
-	texLitPS, err = device.CreatePixelShaderFromBytes(pixelShader_texture_lit)
-	check(err)
-	texLitDecl, err = device.CreateVertexDeclaration(
-		[]d3d9.VERTEXELEMENT{
-			d3d9.VERTEXELEMENT{
-				Stream:     0,
-				Offset:     0,
-				Type:       d3d9.DECLTYPE_FLOAT3,
-				Method:     d3d9.DECLMETHOD_DEFAULT,
-				Usage:      d3d9.DECLUSAGE_POSITION,
-				UsageIndex: 0,
-			},
-			d3d9.VERTEXELEMENT{
-				Stream:     0,
-				Offset:     3 * 4,
-				Type:       d3d9.DECLTYPE_FLOAT3,
-				Method:     d3d9.DECLMETHOD_DEFAULT,
-				Usage:      d3d9.DECLUSAGE_NORMAL,
-				UsageIndex: 0,
-			},
-			d3d9.VERTEXELEMENT{
-				Stream:     0,
-				Offset:     (3 + 3) * 4,
-				Type:       d3d9.DECLTYPE_FLOAT2,
-				Method:     d3d9.DECLMETHOD_DEFAULT,
-				Usage:      d3d9.DECLUSAGE_TEXCOORD,
-				UsageIndex: 0,
-			},
-			d3d9.DeclEnd(),
-		},
-	)
-	check(err)
-
-	triangles = createVertexBuffer(device, []float32{
-		-3 + 0, 0, 0,
-		-3 + 0, 1,
-		-3 + 1, 0, 0,
-		-3 + 1, 1,
-		-3 + 0, 1, 0,
-		-3 + 0, 0,
-
-		5 + 0, 0, 0,
-		0, 0,
-		5 + 1, 0, 0,
-		0, 1,
-		5 + 0, 1, 0,
-		1, 0,
-	})
-
-	square = createVertexBuffer(device, []float32{
-		0, 0, 0,
-		1, 0, 0,
-		0, 0, 1,
-
-		0, 0, 1,
-		1, 0, 0,
-		1, 0, 1,
-	})
-
-	texture = loadTexture(device, "texture.png")
-	sky = loadTexture(device, "sky.png")
-
-	floor = loadTexture(device, "floor.png")
-
-	// height field from black and white image
-	ground = loadHeightField("heights.png")
-	ground.scale = d3dmath.Vec3{0.25, 1.3, 0.25}
-
-	floorVertices = createVertexBuffer(device, heightFieldVertices(ground))
+type dummyCloser struct {
+	io.Reader
 }
 
-func loadHeightField(path string) heightField {
-	const scale = 1.0 / 127
-	var field heightField
+func (dummyCloser) Close() error { return nil }
 
-	img := loadPng(path)
-	b := img.Bounds()
-	w, h := b.Dx(), b.Dy()
-	if w != h {
-		panic("can only handle square height fields right now")
-	}
-	heights := make([]float32, 0, w*h)
-	for y := b.Min.Y; y < b.Max.Y; y++ {
-		for x := b.Min.X; x < b.Max.X; x++ {
-			heights = append(heights, (float32(img.RGBAAt(x, y).R)-127)*scale)
-		}
+func updateGame(dt float32) {
+	w32.SetCursorPos(gameState.centerX, gameState.centerY)
+	if gameState.replay != nil {
+		gameState.replay.Feed(gameState.frame, &gameState.controls)
 	}
+	world.Step(gameState.controls.NextInput(), dt)
+	gameState.frame++
+}
 
-	// slice the linear array into a 2D array for the result
-	field.heights = make([][]float32, h)
-	for i := range field.heights {
-		field.heights[i] = heights[i*w : (i+1)*w]
+// handleInput passes an input event from the window to the game and records
+// it. While a replay is running, live input is ignored.
+func handleInput(e game.Event) {
+	if gameState.replay != nil {
+		return
 	}
-
-	return field
+	if gameState.recorder != nil {
+		check(gameState.recorder.Write(replay.Event{Frame: gameState.frame, Event: e}))
+	}
+	gameState.controls.Handle(e)
 }
 
-type heightField struct {
-	heights [][]float32
-	scale   d3dmath.Vec3 // the height field is first offset, then scaled
+// recordSettings records a change of the world's settings, e.g. from the menu.
+func recordSettings() {
+	if gameState.recorder != nil {
+		check(gameState.recorder.WriteSettings(replay.SettingsChange{
+			Frame:    gameState.frame,
+			Settings: replay.SettingsOf(world),
+		}))
+	}
 }
 
-func (h heightField) size() int {
-	return len(h.heights) - 1
+// releaseButton tells the game about the controls that stop because the button
+// went up.
+func releaseButton(b input.Button) {
+	releaseActions(gameState.buttons.Release(b))
 }
 
-func (h heightField) offset() (x, y, z float32) {
-	x = -float32(h.size()) / 2
-	z = x
-	return
+// updateGamepad passes the changes of the gamepad's buttons and sticks on to
+// the game. Pressed buttons go to press.
+func updateGamepad(s input.GamepadState, press func(input.Button)) {
+	change := gameState.gamepad.Update(s)
+	for _, b := range change.Pressed {
+		if press != nil {
+			press(b)
+		}
+	}
+	for _, b := range change.Released {
+		releaseButton(b)
+	}
+	for _, e := range change.Events {
+		handleInput(e)
+	}
 }
 
-func (h heightField) modelTransform() d3dmath.Mat4 {
-	return d3dmath.Mul4(
-		d3dmath.Translate(h.offset()),
-		d3dmath.ScaleV(h.scale),
-	)
+// mousePos returns the mouse position of a mouse message relative to the
+// window size, see menu.Rect.
+func mousePos(l uintptr) (x, y float32) {
+	x = float32(int16(l & 0xFFFF))
+	y = float32(int16((l >> 16) & 0xFFFF))
+	return x / float32(windowW), y / float32(windowH)
 }
 
-func heightAt(x, z float32, h heightField) float32 {
-	x /= h.scale[0]
-	z /= h.scale[2]
-	dx, _, dz := h.offset()
-	x -= dx
-	z -= dz
-	size := float32(h.size())
-	if x < 0 || z < 0 || x >= size || z >= size {
-		return 0
-	}
-	/* at this point x,z are in tile coordinates
-	        z
-	        ^
-	        |
-	        |
-	   03 13|23 33
-	   02 12|22 32
-	--------+----------> x
-	   01 11|21 31
-	   00 10|20 30
-	        |
-	        |
-	*/
-	ix, iz := int(x), int(z)
-	fx, fz := x-float32(ix), z-float32(iz)
-	onLeftTriangle := 1.0-fx > fz
-
-	heightBottomLeft := h.heights[h.size()-iz][ix]
-	heightTopLeft := h.heights[h.size()-iz-1][ix]
-	heightBottomRight := h.heights[h.size()-iz][ix+1]
-	heightTopRight := h.heights[h.size()-iz-1][ix+1]
-	triangle := [3]d3dmath.Vec3{
-		d3dmath.Vec3{1, heightBottomRight, 0},
-		d3dmath.Vec3{0, heightTopLeft, 1},
-	}
-	if onLeftTriangle {
-		triangle[2] = d3dmath.Vec3{0, heightBottomLeft, 0}
+// setCursorVisible shows or hides the mouse cursor. Windows counts how often
+// it was shown and hidden, this brings the count to where the cursor is just
+// visible or just hidden.
+func setCursorVisible(show bool) {
+	if show {
+		for w32.ShowCursor(true) < 0 {
+		}
 	} else {
-		triangle[2] = d3dmath.Vec3{1, heightTopRight, 1}
-	}
-	line := [2]d3dmath.Vec3{
-		d3dmath.Vec3{fx, 0, fz},
-		d3dmath.Vec3{fx, 1, fz},
+		for w32.ShowCursor(false) >= 0 {
+		}
 	}
-	p := planeLineIntersection(triangle, line)
-	return p[1] * h.scale[1]
 }
 
-var ground heightField
-
-func heightFieldVertices(heightField heightField) []float32 {
-	size := len(heightField.heights) - 1
-	h := make([]float32, 0, size*size*6*(3+3+2)) // 2 triangles: pos, normal, uv
-	for z := 0; z < size; z++ {
-		for x := 0; x < size; x++ {
-			fx, fz := float32(x), float32(z)
-			i, j := size-z, x
-			y1 := heightField.heights[i][j]
-			y2 := heightField.heights[i][j+1]
-			y3 := heightField.heights[i-1][j]
-			y4 := heightField.heights[i-1][j+1]
-			if z == 0 || x == 0 || z == size-1 || x == size-1 {
-				// at the edges the normals are set to 0,1,0
-				h = append(h, []float32{
-					fx, y1, fz,
-					0, 1, 0,
-					0, 1,
-					fx + 1, y2, fz,
-					0, 1, 0,
-					1, 1,
-					fx, y3, fz + 1,
-					0, 1, 0,
-					0, 0,
-
-					fx, y3, fz + 1,
-					0, 1, 0,
-					0, 0,
-					fx + 1, y2, fz,
-					0, 1, 0,
-					1, 1,
-					fx + 1, y4, fz + 1,
-					0, 1, 0,
-					1, 0,
-				}...)
-			} else {
-				n := [2 + 3 + 4 + 3 + 2]d3dmath.Vec3{
-					{fx + 0, heightField.heights[i+1][j+0], fz - 1},
-					{fx - 1, heightField.heights[i+0][j-1], fz + 0},
-					{fx + 1, heightField.heights[i+1][j+1], fz - 1},
-					{fx + 0, heightField.heights[i+0][j+0], fz + 0},
-					{fx - 1, heightField.heights[i-1][j-1], fz + 1},
-					{fx + 2, heightField.heights[i+1][j+2], fz - 1},
-					{fx + 1, heightField.heights[i+0][j+1], fz + 0},
-					{fx + 0, heightField.heights[i-1][j+0], fz + 1},
-					{fx - 1, heightField.heights[i-2][j-1], fz + 2},
-					{fx + 2, heightField.heights[i+0][j+2], fz + 0},
-					{fx + 1, heightField.heights[i-1][j+1], fz + 1},
-					{fx + 0, heightField.heights[i-2][j+0], fz + 2},
-					{fx + 2, heightField.heights[i-1][j+2], fz + 1},
-					{fx + 1, heightField.heights[i-2][j+1], fz + 2},
-				}
-				for i := range n {
-					n[i][0] *= heightField.scale[0]
-					n[i][1] *= heightField.scale[1]
-					n[i][2] *= heightField.scale[2]
-				}
-				normals := [16]d3dmath.Vec3{
-					n[3].Sub(n[0]).Cross(n[2].Sub(n[0])),
-					n[0].Sub(n[3]).Cross(n[1].Sub(n[3])),
-					n[4].Sub(n[1]).Cross(n[3].Sub(n[1])),
-					n[6].Sub(n[2]).Cross(n[5].Sub(n[2])),
-					n[2].Sub(n[6]).Cross(n[3].Sub(n[6])),
-					n[7].Sub(n[3]).Cross(n[6].Sub(n[3])),
-					n[3].Sub(n[7]).Cross(n[4].Sub(n[7])),
-					n[8].Sub(n[4]).Cross(n[7].Sub(n[4])),
-					n[5].Sub(n[9]).Cross(n[6].Sub(n[9])),
-					n[10].Sub(n[6]).Cross(n[9].Sub(n[6])),
-					n[6].Sub(n[10]).Cross(n[7].Sub(n[10])),
-					n[11].Sub(n[7]).Cross(n[10].Sub(n[7])),
-					n[7].Sub(n[11]).Cross(n[8].Sub(n[11])),
-					n[9].Sub(n[12]).Cross(n[10].Sub(n[12])),
-					n[13].Sub(n[10]).Cross(n[12].Sub(n[10])),
-					n[10].Sub(n[13]).Cross(n[11].Sub(n[13])),
-				}
-				n00 := d3dmath.AddVec3(
-					normals[0], normals[1], normals[2],
-					normals[4], normals[5], normals[6],
-				).Normalized()
-				n10 := d3dmath.AddVec3(
-					normals[3], normals[4], normals[5],
-					normals[8], normals[9], normals[10],
-				).Normalized()
-				n01 := d3dmath.AddVec3(
-					normals[5], normals[6], normals[7],
-					normals[10], normals[11], normals[12],
-				).Normalized()
-				n11 := d3dmath.AddVec3(
-					normals[9], normals[10], normals[11],
-					normals[13], normals[14], normals[15],
-				).Normalized()
-				h = append(h, []float32{
-					fx, y1, fz,
-					n00[0], n00[1], n00[2],
-					0, 1,
-					fx + 1, y2, fz,
-					n10[0], n10[1], n10[2],
-					1, 1,
-					fx, y3, fz + 1,
-					n01[0], n01[1], n01[2],
-					0, 0,
-
-					fx, y3, fz + 1,
-					n01[0], n01[1], n01[2],
-					0, 0,
-					fx + 1, y2, fz,
-					n10[0], n10[1], n10[2],
-					1, 1,
-					fx + 1, y4, fz + 1,
-					n11[0], n11[1], n11[2],
-					1, 0,
-				}...)
-			}
+func releaseActions(actions []input.Action) {
+	for _, a := range actions {
+		if key, ok := a.GameKey(); ok {
+			handleInput(game.Event{Kind: game.KeyUp, Key: key})
 		}
 	}
-	return h
 }
 
-func loadTexture(device *d3d9.Device, path string) *d3d9.Texture {
-	img := loadPng(path)
-	texture, err := device.CreateTexture(
-		uint(img.Bounds().Dx()),
-		uint(img.Bounds().Dy()),
-		1,
-		d3d9.USAGE_SOFTWAREPROCESSING,
-		d3d9.FMT_A8R8G8B8,
-		d3d9.POOL_MANAGED,
-		0,
-	)
+// loadReplay reads a recorded session and sets its settings in w.
+func loadReplay(path string, w *game.World) *replay.Player {
+	f, err := os.Open(path)
 	check(err)
-	r, err := texture.LockRect(0, nil, d3d9.LOCK_DISCARD)
+	defer f.Close()
+	rec, err := replay.Read(f)
 	check(err)
-	r.SetAllBytes(img.Pix, img.Stride)
-	check(texture.UnlockRect(0))
-	return texture
+	return rec.Play(w)
 }
 
-func loadPng(path string) *image.RGBA {
-	f, err := open(path)
-	check(err)
-	defer f.Close()
+func renderGeometry(b *d3d9Backend) {
+	scene := render.NewScene(
+		world,
+		gameLevel,
+		terrain,
+		gameState.clock.Alpha(),
+		float32(windowW)/float32(windowH),
+	)
+	scene.FieldOfView = gameState.options.Graphics.FieldOfView
 
-	img, err := png.Decode(f)
-	check(err)
+	//caps, err := device.GetDeviceCaps()
+	//check(err)
+	//check(device.SetSamplerState(0, d3d9.SAMP_MAXANISOTROPY, caps.MaxAnisotropy))
+	//check(device.SetSamplerState(0, d3d9.SAMP_MINFILTER, d3d9.TEXF_LINEAR))
+	//check(device.SetSamplerState(0, d3d9.SAMP_MAGFILTER, d3d9.TEXF_LINEAR))
+	//check(device.SetSamplerState(0, d3d9.SAMP_MIPFILTER, d3d9.TEXF_LINEAR))
 
-	if n, ok := img.(*image.RGBA); ok {
-		return n
+	render.Draw(b, scene)
+	overlay.Reset(float32(windowW), float32(windowH))
+	if gameState.menu.IsOpen() {
+		render.DrawMenu(b, gameState.menu)
+		render.DrawMenuText(overlay, overlayFont, gameState.menu)
 	} else {
-		n := image.NewRGBA(img.Bounds())
-		draw.Draw(n, n.Bounds(), img, img.Bounds().Min, draw.Src)
-		return n
+		render.DrawHUD(overlay, overlayFont, &gameState.hud, world)
 	}
+	b.DrawOverlay(overlay)
+	device := b.device
+	check(device.SetRenderState(d3d9.RS_ALPHABLENDENABLE, 0))
+	check(device.SetRenderState(d3d9.RS_ZENABLE, d3d9.ZB_TRUE))
+	check(device.SetRenderState(d3d9.RS_CULLMODE, d3d9.CULL_CW))
 }
 
-func open(path string) (io.ReadCloser, error) {
-	data, err := payload.Open()
-	if err != nil {
-		return os.Open(path)
-	}
-	defer data.Close()
-	dataBlob, err := blob.Read(data)
-	if err != nil {
-		return nil, err
-	}
-	d, found := dataBlob.GetByID(path)
-	if !found {
-		return nil, errors.New("data for '" + path + "' not found in payload blob")
-	}
-	return dummyCloser{bytes.NewReader(d)}, nil
-}
-
-type dummyCloser struct {
-	io.Reader
-}
-
-func (dummyCloser) Close() error { return nil }
-
-func createVertexBuffer(device *d3d9.Device, data []float32) *d3d9.VertexBuffer {
-	buf, err := device.CreateVertexBuffer(
-		uint(len(data))*4,
-		d3d9.USAGE_WRITEONLY,
-		0,
-		d3d9.POOL_DEFAULT,
-		0,
-	)
-	check(err)
-	mem, err := buf.Lock(0, 0, d3d9.LOCK_DISCARD)
-	check(err)
-	mem.SetFloat32s(0, data)
-	check(buf.Unlock())
-	return buf
-}
-
-func rad2deg(x float32) float32 {
-	return x * 180 / math.Pi
+// d3d9Backend executes render.DrawCalls with the game's shaders and buffers.
+// It holds references to all resources that it draws with, see release.
+type d3d9Backend struct {
+	device            *d3d9.Device
+	res               *resources
+	programs          map[render.Shader]shaderProgram
+	meshes            map[render.Mesh]vertexBuffer
+	groundIndices     indexBuffer
+	groundVertexCount int
+	textures          map[render.Texture]texture
+	// overlayVertices is rewritten every frame, it grows when the overlay
+	// has more than overlayCapacity vertices
+	overlayVertices vertexBuffer
+	overlayCapacity int
+	overlayData     []overlayVertex
+	held            []*resource
 }
 
-func deg2rad(x float32) float32 {
-	return x * math.Pi / 180
+// shaderProgram is a vertex and pixel shader pair and the layout of the
+// vertices that it draws.
+type shaderProgram struct {
+	vs     vertexShader
+	ps     pixelShader
+	decl   vertexDeclaration
+	stride uint // in bytes
 }
 
-func updateGame() {
-	if gameState.keyJumpDown && !gameState.inAir {
-		gameState.inAir = true
-		gameState.velY = gameState.jumpSpeed
+// newD3D9Backend loads everything that is needed to draw the level, the
+// terrain and the overlay text. The level's textures are relative to levelDir.
+func newD3D9Backend(
+	device *d3d9.Device,
+	res *resources,
+	l *level.Level,
+	levelDir string,
+	t *render.Terrain,
+	font *render.Font,
+) (*d3d9Backend, error) {
+	b := &d3d9Backend{
+		device:            device,
+		res:               res,
+		programs:          make(map[render.Shader]shaderProgram),
+		meshes:            make(map[render.Mesh]vertexBuffer),
+		groundVertexCount: t.Mesh.VertexCount(),
+		textures:          make(map[render.Texture]texture),
 	}
-	gameState.keyJumpDown = false
-
-	if gameState.inAir {
-		gameState.pos[1] += gameState.velY
-		gameState.velY += gameState.gravity
+	if err := b.load(l, levelDir, t, font); err != nil {
+		b.release()
+		return nil, err
 	}
+	return b, nil
+}
 
-	mouseDx := gameState.mouseX - gameState.centerX
-	mouseDy := gameState.mouseY - gameState.centerY
-	w32.SetCursorPos(gameState.centerX, gameState.centerY)
-
-	speed := gameState.moveSpeed
-	if gameState.keyRunDown {
-		speed *= runSpeedMultiplier
-	} else if gameState.keySneakDown {
-		speed *= sneakSpeedMultiplier
-	}
-	moveDir := gameState.viewDir
-	moveDir[1] = 0
-	moveDir = moveDir.Normalized()
-	if gameState.keyForwardDown {
-		gameState.pos = gameState.pos.Add(moveDir.MulScalar(speed))
-	}
-	if gameState.keyBackwardDown {
-		gameState.pos = gameState.pos.Add(moveDir.MulScalar(-speed))
+func (b *d3d9Backend) load(l *level.Level, levelDir string, t *render.Terrain, font *render.Font) error {
+	for _, s := range []struct {
+		shader render.Shader
+		name   string
+		vs, ps []byte
+	}{
+		{render.UniformColor, "uniform_color", vertexShader_uniform_color, pixelShader_uniform_color},
+		{render.Textured, "texture", vertexShader_texture, pixelShader_texture},
+		{render.TexturedLit, "texture_lit", vertexShader_texture_lit, pixelShader_texture_lit},
+	} {
+		format := s.shader.Format()
+		if err := format.Check(s.shader.Inputs()); err != nil {
+			return fmt.Errorf("%s.vs: %v", s.name, err)
+		}
+		p := shaderProgram{stride: uint(format.Stride)}
+		var err error
+		if p.vs, err = b.res.vertexShader(s.name+".vs", s.vs); err != nil {
+			return err
+		}
+		b.hold(p.vs.resource)
+		if p.ps, err = b.res.pixelShader(s.name+".ps", s.ps); err != nil {
+			return err
+		}
+		b.hold(p.ps.resource)
+		if p.decl, err = b.res.vertexDeclaration(s.name+" vertex declaration", vertexElements(format)); err != nil {
+			return err
+		}
+		b.hold(p.decl.resource)
+		b.programs[s.shader] = p
 	}
-	if gameState.keyLeftDown {
-		gameState.pos = gameState.pos.Add(
-			gameState.viewDir.Cross(d3dmath.Vec3{0, 1, 0}).MulScalar(speed),
-		)
+
+	meshes := []struct {
+		mesh     render.Mesh
+		name     string
+		vertices interface{}
+	}{
+		{render.SkyMesh, "sky vertices", render.SkyVertices},
+		{render.GroundMesh, "ground vertices", t.Mesh.Vertices},
+		{render.LaserMesh, "square vertices", render.SquareVertices},
 	}
-	if gameState.keyRightDown {
-		gameState.pos = gameState.pos.Add(
-			d3dmath.Vec3{0, 1, 0}.Cross(gameState.viewDir).MulScalar(speed),
-		)
+	if len(l.Props) > 0 {
+		meshes = append(meshes, struct {
+			mesh     render.Mesh
+			name     string
+			vertices interface{}
+		}{render.PropMesh, "prop vertices", render.PropVertices(l.Props)})
 	}
-	if mouseDx != 0 {
-		gameState.viewDir = gameState.viewDir.Homogeneous().MulMat(
-			d3dmath.RotateY(deg2rad(float32(mouseDx) * 0.125)),
-		).DropW().Normalized()
+	for _, m := range meshes {
+		buf, err := b.res.vertexBuffer(m.name, m.mesh.Format(), m.vertices)
+		if err != nil {
+			return err
+		}
+		b.hold(buf.resource)
+		b.meshes[m.mesh] = buf
 	}
-	if mouseDy != 0 {
-		gameState.viewDir[1] -= float32(mouseDy) / 500
-		gameState.viewDir = gameState.viewDir.Normalized()
+	indices, err := b.res.indexBuffer("ground indices", t.Mesh)
+	if err != nil {
+		return err
 	}
+	b.hold(indices.resource)
+	b.groundIndices = indices
 
-	y := heightAt(gameState.pos[0], gameState.pos[2], ground)
-	if gameState.pos[1] < y {
-		gameState.inAir = false
+	paths := make(map[string]string)
+	for _, name := range l.Textures() {
+		paths[name] = filepath.Join(levelDir, name)
 	}
-	if !gameState.inAir {
-		gameState.pos[1] = y
-	}
-
-	if gameState.keyShootDown {
-		gameState.keyShootDown = false
-		origin := gameState.pos.Add(
-			d3dmath.Vec3{0, gameState.playerHeight * 0.9, 0},
-		)
-		step := 1.0 * min(ground.scale[0], ground.scale[2]) * 0.5
-		dir := gameState.viewDir.MulScalar(step)
-		p := origin
-		const maxShootSteps = 1000
-		for i := 0; i < maxShootSteps; i++ {
-			y := heightAt(p[0], p[2], ground)
-			if p[1] <= y {
-				// TODO at this point maybe iterate a couple of times with dir/2
-				// towards the actual collision point; the closer it is to the
-				// player, the more important it is to locate it properly since
-				// the player will see it better
-				p[1] = y
-				break
-			}
-			p = p.Add(dir)
+	paths[string(font.Texture)] = string(font.Texture)
+	for name, path := range paths {
+		tex, err := b.res.texture(path)
+		if err != nil {
+			return err
 		}
-		shootLaser(origin, p)
+		b.hold(tex.resource)
+		b.textures[render.Texture(name)] = tex
 	}
+	return nil
+}
 
-	i := 0
-	for i < len(gameState.laserBeams) {
-		if gameState.laserBeams[i].life <= 0 {
-			gameState.laserBeams = append(
-				gameState.laserBeams[:i],
-				gameState.laserBeams[i+1:]...,
-			)
-		} else {
-			gameState.laserBeams[i].life += laserBeamDecay
-			i++
-		}
-	}
+func (b *d3d9Backend) hold(r *resource) {
+	b.held = append(b.held, r)
 }
 
-func min(a, b float32) float32 {
-	if a < b {
-		return a
+// release gives up all resources of the backend.
+func (b *d3d9Backend) release() {
+	for i := len(b.held) - 1; i >= 0; i-- {
+		b.res.release(b.held[i])
 	}
-	return b
+	b.held = nil
+	b.res.release(b.overlayVertices.resource)
+	b.overlayVertices, b.overlayCapacity = vertexBuffer{}, 0
 }
 
-func max(a, b float32) float32 {
-	if a > b {
-		return a
+// setTexture sets the texture for stage 0, "" or unknown textures unset it.
+func (b *d3d9Backend) setTexture(name render.Texture) {
+	if t := b.textures[name].get(); t != nil {
+		check(b.device.SetTexture(0, t))
+	} else {
+		check(b.device.SetTexture(0, nil))
 	}
-	return b
 }
 
-func shootLaser(from, to d3dmath.Vec3) {
-	gameState.laserBeams = append(gameState.laserBeams, laserBeam{
-		life:  1,
-		start: from,
-		end:   to,
-	})
-}
+func (b *d3d9Backend) Draw(c render.DrawCall) {
+	device := b.device
 
-type laserBeam struct {
-	life       float32
-	start, end d3dmath.Vec3
-}
+	p := b.programs[c.Shader]
+	check(device.SetVertexShader(p.vs.get()))
+	check(device.SetPixelShader(p.ps.get()))
+	check(device.SetVertexDeclaration(p.decl.get()))
+	if c.Shader == render.UniformColor {
+		check(device.SetPixelShaderConstantF(0, c.Color[:]))
+	}
+	if c.Shader == render.TexturedLit {
+		setLighting(device, c)
+	}
+	check(device.SetStreamSource(0, b.meshes[c.Mesh].get(), 0, p.stride))
+	b.setTexture(c.Texture)
 
-func skyMVP() d3dmath.Mat4 {
-	m := d3dmath.Translate(0, 0, 0)
-	v := d3dmath.LookAt(
-		d3dmath.Vec3{},
-		gameState.viewDir,
-		d3dmath.Vec3{0, 1, 0},
-	)
-	p := d3dmath.Perspective(
-		deg2rad(fieldOfViewDeg),
-		float32(windowW)/float32(windowH),
-		100,
-		0.001,
-	)
-	return d3dmath.Mul4(m, v, p)
+	if c.DepthTest {
+		check(device.SetRenderState(d3d9.RS_ZENABLE, d3d9.ZB_TRUE))
+	} else {
+		check(device.SetRenderState(d3d9.RS_ZENABLE, d3d9.ZB_FALSE))
+	}
+	if c.CullClockwise {
+		check(device.SetRenderState(d3d9.RS_CULLMODE, d3d9.CULL_CW))
+	} else {
+		check(device.SetRenderState(d3d9.RS_CULLMODE, d3d9.CULL_NONE))
+	}
+	if c.Blend {
+		check(device.SetRenderState(d3d9.RS_ALPHABLENDENABLE, 1))
+		check(device.SetRenderState(d3d9.RS_SRCBLEND, d3d9.BLEND_SRCALPHA))
+		check(device.SetRenderState(d3d9.RS_DESTBLEND, d3d9.BLEND_INVSRCALPHA))
+	} else {
+		check(device.SetRenderState(d3d9.RS_ALPHABLENDENABLE, 0))
+	}
+
+	mvp := c.MVP.Transposed() // shader expects column-major ordering
+	check(device.SetVertexShaderConstantF(0, mvp[:]))
+	if c.Mesh == render.GroundMesh {
+		check(device.SetIndices(b.groundIndices.get()))
+		device.DrawIndexedPrimitive(
+			d3d9.PT_TRIANGLELIST,
+			0,
+			0,
+			uint(b.groundVertexCount),
+			uint(c.FirstTriangle*3),
+			uint(c.Triangles),
+		)
+	} else {
+		device.DrawPrimitive(d3d9.PT_TRIANGLELIST, uint(c.FirstTriangle*3), uint(c.Triangles))
+	}
 }
 
-func renderGeometry(device *d3d9.Device) {
-	camPos := gameState.pos
-	camPos[1] += gameState.playerHeight
-	v := d3dmath.LookAt(
-		camPos,
-		camPos.Add(gameState.viewDir),
-		d3dmath.Vec3{0, 1, 0},
-	)
-	p := d3dmath.Perspective(
-		deg2rad(fieldOfViewDeg),
-		float32(windowW)/float32(windowH),
-		100,
-		0.001,
+// setLighting sets the shader constants for the light and fog of the
+// texture_lit shaders, see texture_lit.vs and texture_lit.ps for the registers.
+func setLighting(device *d3d9.Device, c render.DrawCall) {
+	var fog, fogMode [4]float32
+	switch c.Fog.Mode {
+	case level.LinearFog:
+		fog[0], fog[1] = c.Fog.End, 1/(c.Fog.End-c.Fog.Start)
+		fogMode[1] = 1
+	case level.ExpFog:
+		fog[2] = c.Fog.Density
+		fogMode[2] = 1
+	default:
+		fogMode[0] = 1
+	}
+	// matrices are transposed because the shader expects column-major ordering
+	model := c.Model.Transposed()
+	normals := render.NormalMatrix(c.Model).Transposed()
+	toLight := c.Light.Direction.Normalized().MulScalar(-1)
+	color, eye := c.Light.Color, c.Eye
+	vs := append(model[:], normals[:]...)
+	vs = append(vs,
+		toLight[0], toLight[1], toLight[2], 0,
+		color[0], color[1], color[2], 0,
+		eye[0], eye[1], eye[2], 1,
 	)
-	vp := d3dmath.Mul4(v, p)
+	vs = append(vs, fog[:]...)
+	vs = append(vs, fogMode[:]...)
+	check(device.SetVertexShaderConstantF(4, vs))
+
+	ambient, fogColor := c.Light.Ambient, c.Fog.Color
+	check(device.SetPixelShaderConstantF(0, []float32{
+		ambient[0], ambient[1], ambient[2], 0,
+		fogColor[0], fogColor[1], fogColor[2], 0,
+	}))
+}
 
-	//caps, err := device.GetDeviceCaps()
-	//check(err)
-	//check(device.SetSamplerState(0, d3d9.SAMP_MAXANISOTROPY, caps.MaxAnisotropy))
-	//check(device.SetSamplerState(0, d3d9.SAMP_MINFILTER, d3d9.TEXF_LINEAR))
-	//check(device.SetSamplerState(0, d3d9.SAMP_MAGFILTER, d3d9.TEXF_LINEAR))
-	//check(device.SetSamplerState(0, d3d9.SAMP_MIPFILTER, d3d9.TEXF_LINEAR))
+// overlayVertex is the layout of the overlay vertex buffer. The overlay is
+// drawn with the fixed function pipeline so it is given as an FVF.
+type overlayVertex struct {
+	Pos   [3]float32 `vertex:"position"`
+	Color uint32     `vertex:"color"`
+	UV    [2]float32 `vertex:"texcoord"`
+}
 
-	check(device.SetVertexShader(texVS))
-	check(device.SetPixelShader(texPS))
-	//check(device.SetVertexShaderConstantF(4, []float32{gameState.red, 0, 1, 1}))
-	check(device.SetVertexDeclaration(texDecl))
+var (
+	overlayFormat = vertex.MustOf(overlayVertex{})
+	overlayFVF    = mustFVF(overlayFormat)
+)
 
-	// draw sky box
+// DrawOverlay draws the 2D overlay with the fixed function pipeline, which
+// multiplies the texture with the vertex colors, so it needs no shaders of its
+// own.
+func (b *d3d9Backend) DrawOverlay(o *render.Overlay) {
+	if len(o.Vertices) == 0 {
+		return
+	}
+	device := b.device
+
+	if len(o.Vertices) > b.overlayCapacity {
+		b.res.release(b.overlayVertices.resource)
+		b.overlayCapacity = 2 * len(o.Vertices)
+		var err error
+		b.overlayVertices, err = b.res.dynamicVertexBuffer(
+			"overlay vertices",
+			uint(b.overlayCapacity*overlayFormat.Stride),
+			overlayFVF,
+		)
+		check(err)
+	}
+	overlayVertices := b.overlayVertices.get()
+	b.overlayData = b.overlayData[:0]
+	for _, v := range o.Vertices {
+		b.overlayData = append(b.overlayData, overlayVertex{
+			Pos:   [3]float32{v.X, v.Y, 0},
+			Color: v.Color,
+			UV:    [2]float32{v.U, v.V},
+		})
+	}
+	data, err := overlayFormat.Pack(b.overlayData)
+	check(err)
+	mem, err := overlayVertices.Lock(0, uint(len(data)), d3d9.LOCK_DISCARD)
+	check(err)
+	mem.SetBytes(0, data)
+	check(overlayVertices.Unlock())
+
+	check(device.SetVertexShader(nil))
+	check(device.SetPixelShader(nil))
+	check(device.SetFVF(overlayFVF))
+	check(device.SetStreamSource(0, overlayVertices, 0, uint(overlayFormat.Stride)))
+
+	identity := d3d9.MATRIX(d3dmath.Identity4())
+	// Direct3D 9 has pixel centers at whole numbers, the projection has them
+	// at .5 like the soft renderer, so everything is moved by half a pixel
+	proj := d3dmath.Mul4(d3dmath.Translate(-0.5, -0.5, 0), o.Projection())
+	check(device.SetTransform(worldTransform, identity))
+	check(device.SetTransform(d3d9.TS_VIEW, identity))
+	check(device.SetTransform(d3d9.TS_PROJECTION, d3d9.MATRIX(proj)))
+
+	check(device.SetRenderState(d3d9.RS_LIGHTING, 0))
 	check(device.SetRenderState(d3d9.RS_ZENABLE, d3d9.ZB_FALSE))
-	skyMVP := skyMVP().Transposed() // shader expects column-major ordering
-	check(device.SetVertexShaderConstantF(0, skyMVP[:]))
-	check(device.SetTexture(0, sky))
-	check(device.SetStreamSource(0, skyVertices, 0, (3+2)*4))
-	device.DrawPrimitive(d3d9.PT_TRIANGLELIST, 0, 12)
-
-	// draw triangles
 	check(device.SetRenderState(d3d9.RS_CULLMODE, d3d9.CULL_NONE))
-	check(device.SetRenderState(d3d9.RS_ZENABLE, d3d9.ZB_TRUE))
-	triMVP := vp.Transposed() // shader expects column-major ordering
-	check(device.SetVertexShaderConstantF(0, triMVP[:]))
-	check(device.SetTexture(0, texture))
-	check(device.SetStreamSource(0, triangles, 0, (3+2)*4))
-	device.DrawPrimitive(d3d9.PT_TRIANGLELIST, 0, 2)
-	check(device.SetRenderState(d3d9.RS_CULLMODE, d3d9.CULL_CW))
-
-	// draw floor
-	check(device.SetVertexShader(texLitVS))
-	check(device.SetPixelShader(texLitPS))
-	check(device.SetVertexDeclaration(texLitDecl))
-	size := ground.size()
-	floorMVP := ground.modelTransform().Mul(vp).Transposed()
-	check(device.SetVertexShaderConstantF(0, floorMVP[:]))
-	check(device.SetTexture(0, floor))
-	check(device.SetStreamSource(0, floorVertices, 0, (3+3+2)*4))
-	device.DrawPrimitive(d3d9.PT_TRIANGLELIST, 0, uint(size*size*2))
-
-	// draw laser beams
-	if len(gameState.laserBeams) > 0 {
-		check(device.SetRenderState(d3d9.RS_ALPHABLENDENABLE, 1))
-		check(device.SetRenderState(d3d9.RS_SRCBLEND, d3d9.BLEND_SRCALPHA))
-		check(device.SetRenderState(d3d9.RS_DESTBLEND, d3d9.BLEND_INVSRCALPHA))
-		check(device.SetVertexDeclaration(uniColorDecl))
-		check(device.SetVertexShader(uniColorVS))
-		check(device.SetPixelShader(uniColorPS))
-		check(device.SetStreamSource(0, square, 0, 3*4))
-		for _, beam := range gameState.laserBeams {
-			diff := beam.end.Sub(beam.start)
-			length := diff.Norm()
-			scale := d3dmath.Scale(0.005, 1, length)
-			offset := d3dmath.TranslateV(beam.start)
-			yRad := math.Atan2(float64(diff[2]), float64(diff[0]))
-			rotY := d3dmath.RotateY(math.Pi/2 - float32(yRad))
-			xRad := math.Atan2(float64(length), float64(-diff[1]))
-			rotX := d3dmath.RotateX(math.Pi/2 - float32(xRad))
-			m := d3dmath.Mul4(scale, rotX, rotY, offset)
-			mvp := d3dmath.Mul4(m, vp).Transposed()
-			check(device.SetPixelShaderConstantF(0, []float32{1, 0, 0, beam.life}))
-			check(device.SetVertexShaderConstantF(0, mvp[:]))
-			device.DrawPrimitive(d3d9.PT_TRIANGLELIST, 0, 2)
+	check(device.SetRenderState(d3d9.RS_ALPHABLENDENABLE, 1))
+	check(device.SetRenderState(d3d9.RS_SRCBLEND, d3d9.BLEND_SRCALPHA))
+	check(device.SetRenderState(d3d9.RS_DESTBLEND, d3d9.BLEND_INVSRCALPHA))
+	check(device.SetSamplerState(0, d3d9.SAMP_MINFILTER, d3d9.TEXF_POINT))
+	check(device.SetSamplerState(0, d3d9.SAMP_MAGFILTER, d3d9.TEXF_POINT))
+
+	for _, batch := range o.Batches {
+		op := uint32(d3d9.TOP_MODULATE)
+		if batch.Texture == "" {
+			// only the vertex color
+			op = d3d9.TOP_SELECTARG2
 		}
-		check(device.SetRenderState(d3d9.RS_ALPHABLENDENABLE, 0))
+		b.setTexture(batch.Texture)
+		check(device.SetTextureStageState(0, d3d9.TSS_COLOROP, op))
+		check(device.SetTextureStageState(0, d3d9.TSS_COLORARG1, d3d9.TA_TEXTURE))
+		check(device.SetTextureStageState(0, d3d9.TSS_COLORARG2, d3d9.TA_DIFFUSE))
+		check(device.SetTextureStageState(0, d3d9.TSS_ALPHAOP, op))
+		check(device.SetTextureStageState(0, d3d9.TSS_ALPHAARG1, d3d9.TA_TEXTURE))
+		check(device.SetTextureStageState(0, d3d9.TSS_ALPHAARG2, d3d9.TA_DIFFUSE))
+		check(device.DrawPrimitive(d3d9.PT_TRIANGLELIST, uint(batch.First), uint(batch.Triangles)))
 	}
 }
 
-// TODO bites me a lot: implicit connection between
-// - CreateVertexDeclaration
-// - SetStreamSource
-// - createVertexBuffer
-// these all have to know what a vertex for the shader is made of
-
-const (
-	fieldOfViewDeg       = 60
-	runSpeedMultiplier   = 2
-	sneakSpeedMultiplier = 0.5
-	laserBeamDecay       = -0.05
-)
+// worldTransform is D3DTS_WORLD, which the d3d9 package has no constant for.
+const worldTransform d3d9.TRANSFORMSTATETYPE = 256
+
+// world is the game simulation, the window procedure collects input in
+// gameState.controls which is fed into the world once per frame
+var world *game.World
+
+// gameLevel describes the scene that is currently played
+var gameLevel *level.Level
+
+// terrain is the chunked ground mesh of the world
+var terrain *render.Terrain
+
+// overlayFont is used for all text in the overlay
+var overlayFont *render.Font
+
+// overlay collects the 2D parts of each frame, it is reused to keep its memory
+var overlay = render.NewOverlay(0, 0)
 
 var gameState struct {
 	centerX, centerY int
-	mouseX, mouseY   int
-
-	keyForwardDown  bool
-	keyBackwardDown bool
-	keyLeftDown     bool
-	keyRightDown    bool
-	keyRunDown      bool
-	keySneakDown    bool
-	keyJumpDown     bool
-	keyShootDown    bool
-
-	moveSpeed    float32
-	pos          d3dmath.Vec3 // player position in the world
-	viewDir      d3dmath.Vec3 // must be kept unit length
-	playerHeight float32
-	velY         float32
-	inAir        bool
-	jumpSpeed    float32
-	gravity      float32
-	laserBeams   []laserBeam
-}
-
-func init() {
-	gameState.moveSpeed = 0.03
-	gameState.jumpSpeed = 0.046
-	gameState.gravity = -0.0025
-	gameState.playerHeight = 0.4
-	gameState.pos = d3dmath.Vec3{0, 0, 0}
-	gameState.viewDir = d3dmath.Vec3{0, 0, 1}.Normalized()
+	controls         game.Controls
+	options          settings.Settings // with command line overrides
+	buttons          *input.Mapper
+	menu             *menu.Menu
+	hud              render.HUD
+	gamepad          *input.Gamepad
+	clock            game.Clock
+	frame            uint32 // number of simulation steps so far
+	recorder         *replay.Writer
+	replay           *replay.Player // nil unless a recorded session is played
 }
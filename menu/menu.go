@@ -0,0 +1,312 @@
+// Package menu is the pause menu: a main page with Resume, Options and Quit
+// and an options page with sliders and toggles. It only keeps track of the
+// items, the selection and the option values, the front end draws the Items
+// at their ItemRect and passes in keys and mouse positions.
+package menu
+
+import "fmt"
+
+// Options are the settings that can be changed in the menu.
+type Options struct {
+	Sensitivity float32 // mouse look in degrees per pixel
+	FieldOfView float32 // vertical, in degrees
+	Fullscreen  bool
+	Volume      float32 // from 0 to 1
+}
+
+// Key is a navigation key.
+type Key int
+
+const (
+	Up Key = iota
+	Down
+	Left
+	Right
+	Enter
+	Back
+)
+
+// Result tells the front end what a key press or click did.
+type Result int
+
+const (
+	// Nothing means that only the selection changed, if anything.
+	Nothing Result = iota
+	// Resume means the menu was closed and the game continues.
+	Resume
+	// Quit means the player wants to leave the game.
+	Quit
+	// Changed means one of the Options changed.
+	Changed
+)
+
+type ItemKind int
+
+const (
+	Button ItemKind = iota
+	Slider
+	Toggle
+)
+
+// Item is what is drawn for one entry of the current page.
+type Item struct {
+	Label string
+	Kind  ItemKind
+	// Fill is the slider position from 0 to 1. For toggles it is 1 when on and
+	// 0 when off.
+	Fill float32
+	// Value is the slider or toggle value as text, buttons have none.
+	Value string
+}
+
+type page int
+
+const (
+	mainPage page = iota
+	optionsPage
+)
+
+type Menu struct {
+	Options  Options
+	open     bool
+	page     page
+	selected int
+}
+
+// New creates a closed menu.
+func New(o Options) *Menu {
+	return &Menu{Options: o}
+}
+
+// Open shows the main page with Resume selected.
+func (m *Menu) Open() {
+	m.open = true
+	m.page = mainPage
+	m.selected = 0
+}
+
+// Close hides the menu.
+func (m *Menu) Close() {
+	m.open = false
+}
+
+func (m *Menu) IsOpen() bool {
+	return m.open
+}
+
+// Selected is the index of the selected item in Items.
+func (m *Menu) Selected() int {
+	return m.selected
+}
+
+// Items returns the entries of the current page, from top to bottom.
+func (m *Menu) Items() []Item {
+	entries := m.entries()
+	items := make([]Item, len(entries))
+	for i, e := range entries {
+		items[i] = e.item()
+	}
+	return items
+}
+
+// Key handles a navigation key. Up and Down move the selection, Left and Right
+// change sliders and toggles, Enter activates the selected item and Back
+// leaves the options page or resumes the game.
+func (m *Menu) Key(k Key) Result {
+	if !m.open {
+		return Nothing
+	}
+	entries := m.entries()
+	e := entries[m.selected]
+	switch k {
+	case Up:
+		m.selected = (m.selected + len(entries) - 1) % len(entries)
+	case Down:
+		m.selected = (m.selected + 1) % len(entries)
+	case Left:
+		return e.change(-1)
+	case Right:
+		return e.change(1)
+	case Enter:
+		return m.activate(e)
+	case Back:
+		if m.page == optionsPage {
+			m.showMain()
+			return Nothing
+		}
+		m.Close()
+		return Resume
+	}
+	return Nothing
+}
+
+// MouseMove selects the item under the mouse. x and y are relative to the
+// window, from 0 at the left and top to 1 at the right and bottom.
+func (m *Menu) MouseMove(x, y float32) {
+	if i, ok := m.itemAt(x, y); ok {
+		m.selected = i
+	}
+}
+
+// Click activates the item under the mouse. Clicking a slider sets it to the
+// clicked position.
+func (m *Menu) Click(x, y float32) Result {
+	i, ok := m.itemAt(x, y)
+	if !ok {
+		return Nothing
+	}
+	m.selected = i
+	e := m.entries()[i]
+	if e.slider != nil {
+		r := ItemRect(i, len(m.entries()))
+		return e.slider.set(e.slider.min + (x-r.X)/r.W*(e.slider.max-e.slider.min))
+	}
+	return m.activate(e)
+}
+
+func (m *Menu) itemAt(x, y float32) (int, bool) {
+	if !m.open {
+		return 0, false
+	}
+	n := len(m.entries())
+	for i := 0; i < n; i++ {
+		if ItemRect(i, n).Contains(x, y) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (m *Menu) activate(e entry) Result {
+	switch {
+	case e.toggle != nil:
+		return e.change(1)
+	case e.action != nil:
+		return e.action()
+	}
+	return Nothing
+}
+
+func (m *Menu) showMain() {
+	m.page = mainPage
+	m.selected = 1 // Options
+}
+
+// entry is an item with what it does.
+type entry struct {
+	label  string
+	slider *slider
+	toggle *bool
+	action func() Result
+}
+
+type slider struct {
+	value          *float32
+	min, max, step float32
+	format         string
+}
+
+func (s *slider) set(v float32) Result {
+	// snap to the step so the value text stays short
+	v = s.min + float32(int((v-s.min)/s.step+0.5))*s.step
+	if v < s.min {
+		v = s.min
+	}
+	if v > s.max {
+		v = s.max
+	}
+	if v == *s.value {
+		return Nothing
+	}
+	*s.value = v
+	return Changed
+}
+
+func (e entry) change(dir float32) Result {
+	switch {
+	case e.slider != nil:
+		return e.slider.set(*e.slider.value + dir*e.slider.step)
+	case e.toggle != nil:
+		*e.toggle = !*e.toggle
+		return Changed
+	}
+	return Nothing
+}
+
+func (e entry) item() Item {
+	switch {
+	case e.slider != nil:
+		s := e.slider
+		return Item{
+			Label: e.label,
+			Kind:  Slider,
+			Fill:  (*s.value - s.min) / (s.max - s.min),
+			Value: fmt.Sprintf(s.format, *s.value),
+		}
+	case e.toggle != nil:
+		item := Item{Label: e.label, Kind: Toggle, Value: "off"}
+		if *e.toggle {
+			item.Fill, item.Value = 1, "on"
+		}
+		return item
+	}
+	return Item{Label: e.label, Kind: Button}
+}
+
+func (m *Menu) entries() []entry {
+	if m.page == optionsPage {
+		o := &m.Options
+		return []entry{
+			{label: "Sensitivity", slider: &slider{&o.Sensitivity, 0.025, 0.5, 0.025, "%.3f"}},
+			{label: "Field of view", slider: &slider{&o.FieldOfView, 40, 110, 5, "%.0f"}},
+			{label: "Fullscreen", toggle: &o.Fullscreen},
+			{label: "Volume", slider: &slider{&o.Volume, 0, 1, 0.1, "%.1f"}},
+			{label: "Back", action: func() Result {
+				m.showMain()
+				return Nothing
+			}},
+		}
+	}
+	return []entry{
+		{label: "Resume", action: func() Result {
+			m.Close()
+			return Resume
+		}},
+		{label: "Options", action: func() Result {
+			m.page = optionsPage
+			m.selected = 0
+			return Nothing
+		}},
+		{label: "Quit", action: func() Result {
+			return Quit
+		}},
+	}
+}
+
+// Rect is an area of the window, relative to its size like the mouse
+// positions.
+type Rect struct {
+	X, Y, W, H float32
+}
+
+func (r Rect) Contains(x, y float32) bool {
+	return r.X <= x && x < r.X+r.W && r.Y <= y && y < r.Y+r.H
+}
+
+const (
+	itemWidth  = 0.5
+	itemHeight = 0.08
+	itemGap    = 0.02
+)
+
+// ItemRect is where item i of n items is drawn. The items are stacked and
+// centered in the window.
+func ItemRect(i, n int) Rect {
+	total := float32(n)*itemHeight + float32(n-1)*itemGap
+	return Rect{
+		X: (1 - itemWidth) / 2,
+		Y: (1-total)/2 + float32(i)*(itemHeight+itemGap),
+		W: itemWidth,
+		H: itemHeight,
+	}
+}
@@ -0,0 +1,137 @@
+package menu
+
+import (
+	"testing"
+)
+
+func options() Options {
+	return Options{Sensitivity: 0.125, FieldOfView: 60, Fullscreen: true, Volume: 1}
+}
+
+func labels(items []Item) []string {
+	l := make([]string, len(items))
+	for i := range items {
+		l[i] = items[i].Label
+	}
+	return l
+}
+
+func TestMainPage(t *testing.T) {
+	m := New(options())
+	if m.IsOpen() {
+		t.Fatal("new menu should be closed")
+	}
+	if r := m.Key(Enter); r != Nothing {
+		t.Errorf("closed menu should ignore keys but returned %v", r)
+	}
+	m.Open()
+	if have := labels(m.Items()); len(have) != 3 || have[0] != "Resume" || have[1] != "Options" || have[2] != "Quit" {
+		t.Errorf("unexpected main page %v", have)
+	}
+	if m.Selected() != 0 {
+		t.Errorf("resume should be selected but %d is", m.Selected())
+	}
+	m.Key(Up)
+	if m.Selected() != 2 {
+		t.Errorf("up from the top should wrap to quit but selected %d", m.Selected())
+	}
+	if r := m.Key(Enter); r != Quit {
+		t.Errorf("want quit but have %v", r)
+	}
+	m.Key(Down)
+	if r := m.Key(Enter); r != Resume || m.IsOpen() {
+		t.Errorf("want resume and closed menu but have %v, open: %v", r, m.IsOpen())
+	}
+	m.Open()
+	if r := m.Key(Back); r != Resume || m.IsOpen() {
+		t.Errorf("back should resume but have %v, open: %v", r, m.IsOpen())
+	}
+}
+
+func TestOptionsPage(t *testing.T) {
+	m := New(options())
+	m.Open()
+	m.Key(Down)
+	m.Key(Enter)
+	items := m.Items()
+	if have := labels(items); len(have) != 5 || have[0] != "Sensitivity" || have[4] != "Back" {
+		t.Fatalf("unexpected options page %v", have)
+	}
+	if items[0].Kind != Slider || items[0].Value != "0.125" {
+		t.Errorf("unexpected sensitivity item %+v", items[0])
+	}
+
+	if r := m.Key(Right); r != Changed || m.Options.Sensitivity != 0.15 {
+		t.Errorf("want sensitivity 0.15 but have %v, %v", r, m.Options.Sensitivity)
+	}
+	m.Key(Down)
+	for i := 0; i < 100; i++ {
+		m.Key(Left)
+	}
+	if m.Options.FieldOfView != 40 {
+		t.Errorf("field of view should stop at 40 but is %v", m.Options.FieldOfView)
+	}
+	if r := m.Key(Left); r != Nothing {
+		t.Errorf("slider at its minimum should not change but returned %v", r)
+	}
+	m.Key(Down)
+	if r := m.Key(Enter); r != Changed || m.Options.Fullscreen {
+		t.Errorf("enter should toggle fullscreen off but have %v, %v", r, m.Options.Fullscreen)
+	}
+	if item := m.Items()[2]; item.Value != "off" || item.Fill != 0 {
+		t.Errorf("unexpected toggle item %+v", item)
+	}
+
+	if r := m.Key(Back); r != Nothing || !m.IsOpen() {
+		t.Errorf("back should return to the main page but have %v, open: %v", r, m.IsOpen())
+	}
+	if have := labels(m.Items()); have[m.Selected()] != "Options" {
+		t.Errorf("options should be selected on the main page but %v is", have[m.Selected()])
+	}
+}
+
+func TestMouse(t *testing.T) {
+	m := New(options())
+	m.Open()
+	quit := ItemRect(2, 3)
+	m.MouseMove(quit.X+quit.W/2, quit.Y+quit.H/2)
+	if m.Selected() != 2 {
+		t.Errorf("mouse over quit should select it but %d is selected", m.Selected())
+	}
+	m.MouseMove(0, 0)
+	if m.Selected() != 2 {
+		t.Errorf("mouse outside of the items should keep the selection but %d is selected", m.Selected())
+	}
+	if r := m.Click(0, 0); r != Nothing {
+		t.Errorf("click outside of the items should do nothing but returned %v", r)
+	}
+
+	options := ItemRect(1, 3)
+	m.Click(options.X+1e-3, options.Y+1e-3)
+	volume := ItemRect(3, 5)
+	if r := m.Click(volume.X+volume.W*0.31, volume.Y+volume.H/2); r != Changed {
+		t.Errorf("clicking the volume slider should change it but returned %v", r)
+	}
+	if m.Options.Volume < 0.29 || m.Options.Volume > 0.31 {
+		t.Errorf("want volume 0.3 but have %v", m.Options.Volume)
+	}
+	if m.Selected() != 3 {
+		t.Errorf("volume should be selected but %d is", m.Selected())
+	}
+}
+
+func TestItemsDoNotOverlap(t *testing.T) {
+	for n := 1; n <= 5; n++ {
+		for i := 0; i < n; i++ {
+			r := ItemRect(i, n)
+			if r.X < 0 || r.Y < 0 || r.X+r.W > 1 || r.Y+r.H > 1 {
+				t.Errorf("item %d of %d is outside the window: %+v", i, n, r)
+			}
+			if i > 0 {
+				if prev := ItemRect(i-1, n); prev.Y+prev.H > r.Y {
+					t.Errorf("items %d and %d of %d overlap", i-1, i, n)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Font is a bitmap font. Its glyphs are white with their coverage in alpha in
+// a texture so that Overlay.Text can draw them in any color.
+type Font struct {
+	Texture Texture
+	// TextureW and TextureH are the size of the texture in pixels, the glyphs'
+	// texture coordinates are computed from them.
+	TextureW, TextureH int
+	// LineHeight is the distance between two lines of text, in pixels.
+	LineHeight int
+	Glyphs     map[rune]Glyph
+}
+
+// Glyph is where a character is in the font texture and how it is placed
+// relative to the pen position, all in pixels.
+type Glyph struct {
+	X, Y, W, H int // in the texture
+	// XOffset and YOffset move the glyph from the pen position, which is at
+	// the top of the line, to where it is drawn.
+	XOffset, YOffset int
+	// Advance is how far the pen moves to the right after the glyph.
+	Advance int
+}
+
+// ParseFont reads a font's metrics file. It is a text file with one entry per
+// line, empty lines and lines starting with # are ignored:
+//
+//	texture font.png
+//	size 128 128
+//	line 13
+//	65 6 26 6 13 0 0 7
+//
+// size is that of the texture, line the LineHeight. The other lines are the
+// glyphs: the character code followed by the Glyph values in the order of its
+// fields.
+func ParseFont(r io.Reader) (*Font, error) {
+	f := &Font{Glyphs: make(map[rune]Glyph)}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if err := f.parseLine(fields); err != nil {
+			return nil, fmt.Errorf("font: line %d: %v", line, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("font: %v", err)
+	}
+	switch {
+	case f.Texture == "":
+		return nil, fmt.Errorf("font: texture is missing")
+	case f.TextureW <= 0 || f.TextureH <= 0:
+		return nil, fmt.Errorf("font: texture size must be positive but is %dx%d", f.TextureW, f.TextureH)
+	case f.LineHeight <= 0:
+		return nil, fmt.Errorf("font: line height must be positive but is %d", f.LineHeight)
+	}
+	return f, nil
+}
+
+func (f *Font) parseLine(fields []string) error {
+	ints := func(want int) ([]int, error) {
+		if len(fields)-1 != want {
+			return nil, fmt.Errorf("%s needs %d values but has %d", fields[0], want, len(fields)-1)
+		}
+		values := make([]int, want)
+		for i := range values {
+			n, err := strconv.Atoi(fields[1+i])
+			if err != nil {
+				return nil, err
+			}
+			values[i] = n
+		}
+		return values, nil
+	}
+
+	switch fields[0] {
+	case "texture":
+		if len(fields) != 2 {
+			return fmt.Errorf("texture needs a file name")
+		}
+		f.Texture = Texture(fields[1])
+	case "size":
+		v, err := ints(2)
+		if err != nil {
+			return err
+		}
+		f.TextureW, f.TextureH = v[0], v[1]
+	case "line":
+		v, err := ints(1)
+		if err != nil {
+			return err
+		}
+		f.LineHeight = v[0]
+	default:
+		r, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return fmt.Errorf("unknown entry %q", fields[0])
+		}
+		v, err := ints(7)
+		if err != nil {
+			return err
+		}
+		if v[2] < 0 || v[3] < 0 {
+			return fmt.Errorf("glyph %d has a negative size", r)
+		}
+		f.Glyphs[rune(r)] = Glyph{
+			X: v[0], Y: v[1], W: v[2], H: v[3],
+			XOffset: v[4], YOffset: v[5],
+			Advance: v[6],
+		}
+	}
+	return nil
+}
+
+// LoadFont opens and parses the font's metrics file with the given name. The
+// texture is not loaded, it is named in Font.Texture.
+func LoadFont(name string, open func(string) (io.ReadCloser, error)) (*Font, error) {
+	r, err := open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	f, err := ParseFont(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return f, nil
+}
+
+// glyph returns the glyph for r, or for '?' if the font does not have r.
+func (f *Font) glyph(r rune) (Glyph, bool) {
+	if g, ok := f.Glyphs[r]; ok {
+		return g, true
+	}
+	g, ok := f.Glyphs['?']
+	return g, ok
+}
+
+// Measure returns the size of the text in pixels when drawn at scale 1. Text
+// can have multiple lines.
+func (f *Font) Measure(text string) (w, h int) {
+	x := 0
+	h = f.LineHeight
+	for _, r := range text {
+		if r == '\n' {
+			x = 0
+			h += f.LineHeight
+			continue
+		}
+		if g, ok := f.glyph(r); ok {
+			x += g.Advance
+		}
+		if x > w {
+			w = x
+		}
+	}
+	return w, h
+}
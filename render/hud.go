@@ -0,0 +1,158 @@
+package render
+
+import (
+	"fmt"
+
+	"github.com/gonutz/ld40/game"
+)
+
+// frameHistory is how many frames FrameTimes keeps, the frame time graph is
+// one pixel per frame wide.
+const frameHistory = 120
+
+// FrameTimes records how long the last frames took, for the FPS counter and
+// the frame time graph.
+type FrameTimes struct {
+	times [frameHistory]float32 // in seconds, times[next] is the oldest
+	next  int
+	count int
+}
+
+// Add records the duration of a frame in seconds.
+func (f *FrameTimes) Add(seconds float32) {
+	f.times[f.next] = seconds
+	f.next = (f.next + 1) % frameHistory
+	if f.count < frameHistory {
+		f.count++
+	}
+}
+
+// Times returns the recorded frame times from the oldest to the newest.
+func (f *FrameTimes) Times() []float32 {
+	times := make([]float32, 0, f.count)
+	for i := frameHistory - f.count; i < frameHistory; i++ {
+		times = append(times, f.times[(f.next+i)%frameHistory])
+	}
+	return times
+}
+
+// FPS is the average frame rate over the recorded frames, 0 if there are none.
+func (f *FrameTimes) FPS() float32 {
+	var sum float32
+	for _, t := range f.Times() {
+		sum += t
+	}
+	if sum <= 0 {
+		return 0
+	}
+	return float32(f.count) / sum
+}
+
+// HUD is what is shown over the game while playing: the crosshair, the frame
+// rate and, if ShowDebug is set, the player's state.
+type HUD struct {
+	Frames FrameTimes
+	// Target is the desired frame time in seconds. It is drawn as a line in
+	// the frame time graph, frames above it are drawn red.
+	Target    float32
+	ShowDebug bool
+}
+
+var (
+	hudTextColor   = [4]float32{1, 1, 1, 1}
+	hudShade       = [4]float32{0, 0, 0, 0.5}
+	hudCrosshair   = [4]float32{1, 1, 1, 0.8}
+	hudGoodFrame   = [4]float32{0.3, 0.9, 0.3, 0.8}
+	hudSlowFrame   = [4]float32{0.9, 0.2, 0.2, 0.8}
+	hudTargetColor = [4]float32{1, 1, 0.5, 0.8}
+)
+
+// hud sizes in pixels, for a screen up to 480 pixels high, they are scaled up
+// for larger screens
+const (
+	hudMargin        = 4
+	hudCrosshairSize = 6 // length of each arm
+	hudCrosshairGap  = 2 // between the center and the arms
+	hudGraphHeight   = 30
+)
+
+// DrawHUD adds the HUD to the overlay. The crosshair is in the center of the
+// screen, the frame rate and graph top right and the debug panel top left.
+func DrawHUD(o *Overlay, f *Font, h *HUD, w *game.World) {
+	scale := float32(int(o.Height / 480))
+	if scale < 1 {
+		scale = 1
+	}
+	margin := hudMargin * scale
+
+	drawCrosshair(o, scale)
+
+	// frame rate and time graph, top right
+	times := h.Frames.Times()
+	graphW, graphH := frameHistory*scale, hudGraphHeight*scale
+	graphX := o.Width - margin - graphW
+	fps := h.Frames.FPS()
+	text := fmt.Sprintf("%.0f FPS", fps)
+	if fps > 0 {
+		text += fmt.Sprintf(" %.1f ms", 1000/fps)
+	}
+	textW, textH := f.Measure(text)
+	o.Text(f, text, o.Width-margin-float32(textW)*scale, margin, scale, hudTextColor)
+	graphY := margin + float32(textH)*scale
+	o.Rect(graphX, graphY, graphW, graphH, hudShade)
+	if h.Target > 0 {
+		// the target is in the middle, twice the target fills the graph
+		for i, t := range times {
+			barH := t / (2 * h.Target) * graphH
+			if barH > graphH {
+				barH = graphH
+			}
+			color := hudGoodFrame
+			if t > h.Target*1.1 {
+				color = hudSlowFrame
+			}
+			x := graphX + float32(frameHistory-len(times)+i)*scale
+			o.Rect(x, graphY+graphH-barH, scale, barH, color)
+		}
+		o.Rect(graphX, graphY+graphH/2, graphW, scale, hudTargetColor)
+	}
+
+	if h.ShowDebug {
+		text := DebugText(w)
+		textW, textH := f.Measure(text)
+		o.Rect(margin/2, margin/2, float32(textW)*scale+margin, float32(textH)*scale+margin, hudShade)
+		o.Text(f, text, margin, margin, scale, hudTextColor)
+	}
+}
+
+func drawCrosshair(o *Overlay, scale float32) {
+	cx, cy := float32(int(o.Width/2)), float32(int(o.Height/2))
+	size, gap := hudCrosshairSize*scale, hudCrosshairGap*scale
+	o.Rect(cx-gap-size, cy, size, scale, hudCrosshair)
+	o.Rect(cx+gap+scale, cy, size, scale, hudCrosshair)
+	o.Rect(cx, cy-gap-size, scale, size, hudCrosshair)
+	o.Rect(cx, cy+gap+scale, scale, size, hudCrosshair)
+}
+
+// DebugText describes the player's state, one value per line.
+func DebugText(w *game.World) string {
+	dir := w.ViewDir()
+	ground := "off map"
+	if y, _, ok := game.SurfaceAt(w.Pos[0], w.Pos[2], w.Ground); ok {
+		ground = fmt.Sprintf("%.2f", y)
+	}
+	return fmt.Sprintf(
+		"pos     %.2f %.2f %.2f\n"+
+			"viewDir %.2f %.2f %.2f\n"+
+			"velY    %.2f\n"+
+			"inAir   %v\n"+
+			"ground  %s\n"+
+			"lasers  %d",
+		w.Pos[0], w.Pos[1], w.Pos[2],
+		dir[0], dir[1], dir[2],
+		w.VelY,
+		w.InAir(),
+		ground,
+		len(w.LaserBeams),
+	)
+}
@@ -0,0 +1,67 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+)
+
+func TestFrameTimesKeepTheLastFrames(t *testing.T) {
+	var f FrameTimes
+	if f.FPS() != 0 || len(f.Times()) != 0 {
+		t.Errorf("no frames should have 0 FPS but have %v, %v", f.FPS(), f.Times())
+	}
+	f.Add(0.5)
+	f.Add(0.25)
+	if times := f.Times(); len(times) != 2 || times[0] != 0.5 || times[1] != 0.25 {
+		t.Errorf("want times [0.5 0.25] but have %v", times)
+	}
+	for i := 0; i < frameHistory; i++ {
+		f.Add(0.02)
+	}
+	f.Add(0.01)
+	times := f.Times()
+	if len(times) != frameHistory {
+		t.Fatalf("want %d times but have %d", frameHistory, len(times))
+	}
+	if times[len(times)-1] != 0.01 || times[0] != 0.02 {
+		t.Errorf("want oldest 0.02 and newest 0.01 but have %v and %v", times[0], times[len(times)-1])
+	}
+	if fps := f.FPS(); fps < 50 || fps > 50.5 {
+		t.Errorf("want about 50 FPS but have %v", fps)
+	}
+}
+
+func TestDebugText(t *testing.T) {
+	w := game.NewWorld(hillyField(5, 3))
+	w.Pos = d3dmath.Vec3{0.5, 7, 0.5}
+	w.SetViewDir(d3dmath.Vec3{0, 0, 1})
+	w.VelY = -2
+	w.State = game.Airborne
+	w.LaserBeams = make([]game.LaserBeam, 3)
+	text := DebugText(w)
+	ground, _, ok := game.SurfaceAt(0.5, 0.5, w.Ground)
+	if !ok {
+		t.Fatal("the player should be over the terrain")
+	}
+	for _, want := range []string{
+		"pos     0.50 7.00 0.50",
+		"viewDir 0.00 0.00 1.00",
+		"velY    -2.00",
+		"inAir   true",
+		"lasers  3",
+		fmt.Sprintf("ground  %.2f", ground),
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in\n%s", want, text)
+		}
+	}
+
+	w.Pos = d3dmath.Vec3{-100, 0, -100}
+	if text := DebugText(w); !strings.Contains(text, "ground  off map") {
+		t.Errorf("outside of the terrain there should be no ground in\n%s", text)
+	}
+}
@@ -0,0 +1,101 @@
+package render
+
+import (
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/menu"
+)
+
+var (
+	menuShade        = [4]float32{0, 0, 0, 0.5}
+	menuItemColor    = [4]float32{0.2, 0.2, 0.25, 0.85}
+	menuSelected     = [4]float32{0.45, 0.4, 0.15, 0.9}
+	menuValueColor   = [4]float32{0.85, 0.85, 0.85, 0.9}
+	menuValueMargin  = float32(0.01)
+	menuToggleMargin = float32(0.015)
+	menuTextColor    = [4]float32{1, 1, 1, 1}
+	menuTextHeight   = float32(0.6) // relative to the bar
+)
+
+// DrawMenu draws the pause menu over the scene: the scene is darkened and each
+// item is a bar, the selected one highlighted. Sliders show their value as a
+// filled part of the bar, toggles as a box at the right end.
+func DrawMenu(b Backend, m *menu.Menu) {
+	quad(b, menu.Rect{X: 0, Y: 0, W: 1, H: 1}, menuShade)
+	items := m.Items()
+	for i, item := range items {
+		r := menu.ItemRect(i, len(items))
+		color := menuItemColor
+		if i == m.Selected() {
+			color = menuSelected
+		}
+		quad(b, r, color)
+		switch item.Kind {
+		case menu.Slider:
+			inner := inset(r, menuValueMargin)
+			inner.W *= item.Fill
+			if inner.W > 0 {
+				quad(b, inner, menuValueColor)
+			}
+		case menu.Toggle:
+			box := inset(menu.Rect{X: r.X + r.W - r.H, Y: r.Y, W: r.H, H: r.H}, menuToggleMargin)
+			if item.Fill > 0 {
+				quad(b, box, menuValueColor)
+			} else {
+				quad(b, box, menuShade)
+			}
+		}
+	}
+}
+
+// DrawMenuText adds the labels of the menu items to the overlay, left in their
+// bars, and the slider and toggle values right. The text is scaled to the bar
+// height in whole steps to keep the font sharp.
+func DrawMenuText(o *Overlay, f *Font, m *menu.Menu) {
+	items := m.Items()
+	for i, item := range items {
+		r := menu.ItemRect(i, len(items))
+		x, y := r.X*o.Width, r.Y*o.Height
+		w, h := r.W*o.Width, r.H*o.Height
+		scale := float32(int(menuTextHeight * h / float32(f.LineHeight)))
+		if scale < 1 {
+			scale = 1
+		}
+		margin := (h - float32(f.LineHeight)*scale) / 2
+		o.Text(f, item.Label, x+margin, y+margin, scale, menuTextColor)
+		if item.Value != "" {
+			right := x + w - margin
+			if item.Kind == menu.Toggle {
+				right -= h
+			}
+			textW, _ := f.Measure(item.Value)
+			o.Text(f, item.Value, right-float32(textW)*scale, y+margin, scale, menuTextColor)
+		}
+	}
+}
+
+func inset(r menu.Rect, d float32) menu.Rect {
+	return menu.Rect{X: r.X + d, Y: r.Y + d, W: r.W - 2*d, H: r.H - 2*d}
+}
+
+// quad draws a flat colored rectangle in window coordinates, see menu.Rect.
+func quad(b Backend, r menu.Rect, color [4]float32) {
+	b.Draw(DrawCall{
+		Shader:    UniformColor,
+		Mesh:      LaserMesh,
+		MVP:       ScreenTransform(r),
+		Color:     color,
+		Triangles: 2,
+		Blend:     color[3] < 1,
+	})
+}
+
+// ScreenTransform maps the SquareVertices onto the rectangle r of the window,
+// given relative to the window size with y pointing down.
+func ScreenTransform(r menu.Rect) d3dmath.Mat4 {
+	return d3dmath.Mat4{
+		2 * r.W, 0, 0, 0,
+		0, 0, 0, 0,
+		0, -2 * r.H, 0, 0,
+		2*r.X - 1, 1 - 2*r.Y, 0, 1,
+	}
+}
@@ -0,0 +1,309 @@
+package render
+
+import (
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+	"github.com/gonutz/ld40/level"
+)
+
+// SkyVertices is a unit cube around the camera.
+var SkyVertices = []TexturedVertex{
+	// top
+	{d3dmath.Vec3{-1, 1, 1}, [2]float32{0, 0.5}},
+	{d3dmath.Vec3{1, 1, 1}, [2]float32{1.0 / 3, 0.5}},
+	{d3dmath.Vec3{-1, 1, -1}, [2]float32{0, 0}},
+
+	{d3dmath.Vec3{-1, 1, -1}, [2]float32{0, 0}},
+	{d3dmath.Vec3{1, 1, 1}, [2]float32{1.0 / 3, 0.5}},
+	{d3dmath.Vec3{1, 1, -1}, [2]float32{1.0 / 3, 0}},
+
+	// bottom
+	{d3dmath.Vec3{-1, -1, -1}, [2]float32{1.0 / 3, 0.5}},
+	{d3dmath.Vec3{1, -1, -1}, [2]float32{2.0 / 3, 0.5}},
+	{d3dmath.Vec3{-1, -1, 1}, [2]float32{1.0 / 3, 0}},
+
+	{d3dmath.Vec3{-1, -1, 1}, [2]float32{1.0 / 3, 0}},
+	{d3dmath.Vec3{1, -1, -1}, [2]float32{2.0 / 3, 0.5}},
+	{d3dmath.Vec3{1, -1, 1}, [2]float32{2.0 / 3, 0}},
+
+	// left
+	{d3dmath.Vec3{-1, -1, 1}, [2]float32{0, 1}},
+	{d3dmath.Vec3{1, -1, 1}, [2]float32{1.0 / 3, 1}},
+	{d3dmath.Vec3{-1, 1, 1}, [2]float32{0, 0.5}},
+
+	{d3dmath.Vec3{-1, 1, 1}, [2]float32{0, 0.5}},
+	{d3dmath.Vec3{1, -1, 1}, [2]float32{1.0 / 3, 1}},
+	{d3dmath.Vec3{1, 1, 1}, [2]float32{1.0 / 3, 0.5}},
+
+	// front
+	{d3dmath.Vec3{-1, -1, -1}, [2]float32{2.0 / 3, 0.5}},
+	{d3dmath.Vec3{-1, -1, 1}, [2]float32{1, 0.5}},
+	{d3dmath.Vec3{-1, 1, -1}, [2]float32{2.0 / 3, 0}},
+
+	{d3dmath.Vec3{-1, 1, -1}, [2]float32{2.0 / 3, 0}},
+	{d3dmath.Vec3{-1, -1, 1}, [2]float32{1, 0.5}},
+	{d3dmath.Vec3{-1, 1, 1}, [2]float32{1, 0}},
+
+	// right
+	{d3dmath.Vec3{1, -1, 1}, [2]float32{1.0 / 3, 1}},
+	{d3dmath.Vec3{1, -1, -1}, [2]float32{2.0 / 3, 1}},
+	{d3dmath.Vec3{1, 1, 1}, [2]float32{1.0 / 3, 0.5}},
+
+	{d3dmath.Vec3{1, 1, 1}, [2]float32{1.0 / 3, 0.5}},
+	{d3dmath.Vec3{1, -1, -1}, [2]float32{2.0 / 3, 1}},
+	{d3dmath.Vec3{1, 1, -1}, [2]float32{2.0 / 3, 0.5}},
+
+	// back
+	{d3dmath.Vec3{1, -1, -1}, [2]float32{2.0 / 3, 1}},
+	{d3dmath.Vec3{-1, -1, -1}, [2]float32{1, 1}},
+	{d3dmath.Vec3{1, 1, -1}, [2]float32{2.0 / 3, 0.5}},
+
+	{d3dmath.Vec3{1, 1, -1}, [2]float32{2.0 / 3, 0.5}},
+	{d3dmath.Vec3{-1, -1, -1}, [2]float32{1, 1}},
+	{d3dmath.Vec3{-1, 1, -1}, [2]float32{1, 0.5}},
+}
+
+// PropVertices creates one triangle per prop, in world coordinates.
+func PropVertices(props []level.Prop) []TexturedVertex {
+	corners := [3]d3dmath.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}
+	v := make([]TexturedVertex, 0, len(props)*3)
+	for _, p := range props {
+		scale := p.Scale
+		if scale == 0 {
+			scale = 1
+		}
+		uv := level.DefaultUV
+		if p.UV != nil {
+			uv = *p.UV
+		}
+		m := d3dmath.Mul4(
+			d3dmath.Scale(scale, scale, scale),
+			d3dmath.RotateY(deg2rad(p.YawDeg)),
+			d3dmath.TranslateV(p.Position),
+		)
+		for i, c := range corners {
+			v = append(v, TexturedVertex{
+				Pos: c.Homogeneous().MulMat(m).DropW(),
+				UV:  uv[i],
+			})
+		}
+	}
+	return v
+}
+
+// SquareVertices is a unit square in the x-z plane, used for the laser beams.
+var SquareVertices = []PositionVertex{
+	{d3dmath.Vec3{0, 0, 0}},
+	{d3dmath.Vec3{1, 0, 0}},
+	{d3dmath.Vec3{0, 0, 1}},
+
+	{d3dmath.Vec3{0, 0, 1}},
+	{d3dmath.Vec3{1, 0, 0}},
+	{d3dmath.Vec3{1, 0, 1}},
+}
+
+// IndexedMesh is a vertex array with a triangle list of indices into it. Only
+// one of Indices16 and Indices32 is set, 16 bit indices are used if they can
+// address all vertices.
+type IndexedMesh struct {
+	Vertices  []LitVertex
+	Indices16 []uint16
+	Indices32 []uint32
+}
+
+func newIndexedMesh(vertices []LitVertex, indices []uint32) IndexedMesh {
+	m := IndexedMesh{Vertices: vertices}
+	if m.VertexCount() <= 1<<16 {
+		m.Indices16 = make([]uint16, len(indices))
+		for i, index := range indices {
+			m.Indices16[i] = uint16(index)
+		}
+	} else {
+		m.Indices32 = indices
+	}
+	return m
+}
+
+func (m IndexedMesh) VertexCount() int {
+	return len(m.Vertices)
+}
+
+func (m IndexedMesh) IndexCount() int {
+	if m.Indices32 != nil {
+		return len(m.Indices32)
+	}
+	return len(m.Indices16)
+}
+
+// Index returns the i'th index, regardless of its size.
+func (m IndexedMesh) Index(i int) int {
+	if m.Indices32 != nil {
+		return int(m.Indices32[i])
+	}
+	return int(m.Indices16[i])
+}
+
+// HeightFieldMesh creates an indexed triangle list for the height field with
+// one vertex per height sample. Vertices are in height field coordinates, use
+// the height field's ModelTransform to place them in the world and NormalMatrix
+// of that for the normals. The triangles are the same as in HeightFieldVertices.
+func HeightFieldMesh(h game.HeightField) IndexedMesh {
+	cellsX, cellsZ := h.CellsX(), h.CellsZ()
+	indices := make([]uint32, 0, cellsX*cellsZ*6)
+	w := uint32(cellsX + 1)
+	for z := uint32(0); z < uint32(cellsZ); z++ {
+		for x := uint32(0); x < uint32(cellsX); x++ {
+			i := z*w + x
+			indices = append(indices,
+				i, i+1, i+w,
+				i+w, i+1, i+w+1,
+			)
+		}
+	}
+	return newIndexedMesh(heightFieldGridVertices(h), indices)
+}
+
+// heightFieldGridVertices has a vertex for every grid point, row by row
+// starting at z = 0. The texture repeats once per grid cell.
+func heightFieldGridVertices(h game.HeightField) []LitVertex {
+	cellsX, cellsZ := h.CellsX(), h.CellsZ()
+	normals := gridNormals(h)
+	v := make([]LitVertex, 0, (cellsX+1)*(cellsZ+1))
+	for z := 0; z <= cellsZ; z++ {
+		for x := 0; x <= cellsX; x++ {
+			v = append(v, LitVertex{
+				Pos:    d3dmath.Vec3{float32(x), h.Heights[cellsZ-z][x], float32(z)},
+				Normal: normals[z*(cellsX+1)+x],
+				UV:     [2]float32{float32(x), -float32(z)},
+			})
+		}
+	}
+	return v
+}
+
+// gridNormals computes the vertex normal for every grid point, row by row
+// starting at z = 0, in height field coordinates. At the edges of the height
+// field the normals point up.
+func gridNormals(h game.HeightField) []d3dmath.Vec3 {
+	cellsX, cellsZ := h.CellsX(), h.CellsZ()
+	pos := func(x, z int) d3dmath.Vec3 {
+		return d3dmath.Vec3{float32(x), h.Heights[cellsZ-z][x], float32(z)}
+	}
+	face := func(a, b, c gridPoint) d3dmath.Vec3 {
+		p := pos(a.x, a.z)
+		return pos(c.x, c.z).Sub(p).Cross(pos(b.x, b.z).Sub(p))
+	}
+	normals := make([]d3dmath.Vec3, (cellsX+1)*(cellsZ+1))
+	for z := 0; z <= cellsZ; z++ {
+		for x := 0; x <= cellsX; x++ {
+			if x == 0 || z == 0 || x == cellsX || z == cellsZ {
+				normals[z*(cellsX+1)+x] = d3dmath.Vec3{0, 1, 0}
+				continue
+			}
+			// every cell is split into the triangles 00,10,01 and 01,10,11,
+			// six of them touch each inner grid point
+			normals[z*(cellsX+1)+x] = d3dmath.AddVec3(
+				face(gridPoint{x, z}, gridPoint{x + 1, z}, gridPoint{x, z + 1}),
+				face(gridPoint{x - 1, z}, gridPoint{x, z}, gridPoint{x - 1, z + 1}),
+				face(gridPoint{x - 1, z + 1}, gridPoint{x, z}, gridPoint{x, z + 1}),
+				face(gridPoint{x, z - 1}, gridPoint{x + 1, z - 1}, gridPoint{x, z}),
+				face(gridPoint{x, z}, gridPoint{x + 1, z - 1}, gridPoint{x + 1, z}),
+				face(gridPoint{x - 1, z}, gridPoint{x, z - 1}, gridPoint{x, z}),
+			).Normalized()
+		}
+	}
+	return normals
+}
+
+// HeightFieldVertices creates a non-indexed triangle list for the height field.
+// Vertices are in height field coordinates, use the height field's
+// ModelTransform to place them in the world and NormalMatrix of that for the
+// normals. The game draws the smaller HeightFieldMesh instead.
+func HeightFieldVertices(heightField game.HeightField) []LitVertex {
+	cellsX, cellsZ := heightField.CellsX(), heightField.CellsZ()
+	h := make([]LitVertex, 0, cellsX*cellsZ*6) // 2 triangles per cell
+	for z := 0; z < cellsZ; z++ {
+		for x := 0; x < cellsX; x++ {
+			fx, fz := float32(x), float32(z)
+			i, j := cellsZ-z, x
+			y1 := heightField.Heights[i][j]
+			y2 := heightField.Heights[i][j+1]
+			y3 := heightField.Heights[i-1][j]
+			y4 := heightField.Heights[i-1][j+1]
+			if z == 0 || x == 0 || z == cellsZ-1 || x == cellsX-1 {
+				// at the edges the normals are set to 0,1,0
+				up := d3dmath.Vec3{0, 1, 0}
+				h = append(h,
+					LitVertex{d3dmath.Vec3{fx, y1, fz}, up, [2]float32{0, 1}},
+					LitVertex{d3dmath.Vec3{fx + 1, y2, fz}, up, [2]float32{1, 1}},
+					LitVertex{d3dmath.Vec3{fx, y3, fz + 1}, up, [2]float32{0, 0}},
+
+					LitVertex{d3dmath.Vec3{fx, y3, fz + 1}, up, [2]float32{0, 0}},
+					LitVertex{d3dmath.Vec3{fx + 1, y2, fz}, up, [2]float32{1, 1}},
+					LitVertex{d3dmath.Vec3{fx + 1, y4, fz + 1}, up, [2]float32{1, 0}},
+				)
+			} else {
+				n := [2 + 3 + 4 + 3 + 2]d3dmath.Vec3{
+					{fx + 0, heightField.Heights[i+1][j+0], fz - 1},
+					{fx - 1, heightField.Heights[i+0][j-1], fz + 0},
+					{fx + 1, heightField.Heights[i+1][j+1], fz - 1},
+					{fx + 0, heightField.Heights[i+0][j+0], fz + 0},
+					{fx - 1, heightField.Heights[i-1][j-1], fz + 1},
+					{fx + 2, heightField.Heights[i+1][j+2], fz - 1},
+					{fx + 1, heightField.Heights[i+0][j+1], fz + 0},
+					{fx + 0, heightField.Heights[i-1][j+0], fz + 1},
+					{fx - 1, heightField.Heights[i-2][j-1], fz + 2},
+					{fx + 2, heightField.Heights[i+0][j+2], fz + 0},
+					{fx + 1, heightField.Heights[i-1][j+1], fz + 1},
+					{fx + 0, heightField.Heights[i-2][j+0], fz + 2},
+					{fx + 2, heightField.Heights[i-1][j+2], fz + 1},
+					{fx + 1, heightField.Heights[i-2][j+1], fz + 2},
+				}
+				normals := [16]d3dmath.Vec3{
+					n[3].Sub(n[0]).Cross(n[2].Sub(n[0])),
+					n[0].Sub(n[3]).Cross(n[1].Sub(n[3])),
+					n[4].Sub(n[1]).Cross(n[3].Sub(n[1])),
+					n[6].Sub(n[2]).Cross(n[5].Sub(n[2])),
+					n[2].Sub(n[6]).Cross(n[3].Sub(n[6])),
+					n[7].Sub(n[3]).Cross(n[6].Sub(n[3])),
+					n[3].Sub(n[7]).Cross(n[4].Sub(n[7])),
+					n[8].Sub(n[4]).Cross(n[7].Sub(n[4])),
+					n[5].Sub(n[9]).Cross(n[6].Sub(n[9])),
+					n[10].Sub(n[6]).Cross(n[9].Sub(n[6])),
+					n[6].Sub(n[10]).Cross(n[7].Sub(n[10])),
+					n[11].Sub(n[7]).Cross(n[10].Sub(n[7])),
+					n[7].Sub(n[11]).Cross(n[8].Sub(n[11])),
+					n[9].Sub(n[12]).Cross(n[10].Sub(n[12])),
+					n[13].Sub(n[10]).Cross(n[12].Sub(n[10])),
+					n[10].Sub(n[13]).Cross(n[11].Sub(n[13])),
+				}
+				n00 := d3dmath.AddVec3(
+					normals[0], normals[1], normals[2],
+					normals[4], normals[5], normals[6],
+				).Normalized()
+				n10 := d3dmath.AddVec3(
+					normals[3], normals[4], normals[5],
+					normals[8], normals[9], normals[10],
+				).Normalized()
+				n01 := d3dmath.AddVec3(
+					normals[5], normals[6], normals[7],
+					normals[10], normals[11], normals[12],
+				).Normalized()
+				n11 := d3dmath.AddVec3(
+					normals[9], normals[10], normals[11],
+					normals[13], normals[14], normals[15],
+				).Normalized()
+				h = append(h,
+					LitVertex{d3dmath.Vec3{fx, y1, fz}, n00, [2]float32{0, 1}},
+					LitVertex{d3dmath.Vec3{fx + 1, y2, fz}, n10, [2]float32{1, 1}},
+					LitVertex{d3dmath.Vec3{fx, y3, fz + 1}, n01, [2]float32{0, 0}},
+
+					LitVertex{d3dmath.Vec3{fx, y3, fz + 1}, n01, [2]float32{0, 0}},
+					LitVertex{d3dmath.Vec3{fx + 1, y2, fz}, n10, [2]float32{1, 1}},
+					LitVertex{d3dmath.Vec3{fx + 1, y4, fz + 1}, n11, [2]float32{1, 0}},
+				)
+			}
+		}
+	}
+	return h
+}
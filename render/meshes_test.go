@@ -0,0 +1,97 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+)
+
+func TestHeightFieldVerticesForRectangularField(t *testing.T) {
+	heights := make([][]float32, 4) // 3 cells along z
+	for i := range heights {
+		heights[i] = make([]float32, 6) // 5 cells along x
+	}
+	h := game.HeightField{Heights: heights, Scale: d3dmath.Vec3{1, 1, 1}}
+	v := HeightFieldVertices(h)
+	if want := 5 * 3 * 6; len(v) != want {
+		t.Fatalf("want %d vertices but have %d", want, len(v))
+	}
+	// the last vertex is the top-right corner of the last cell
+	last := v[len(v)-1].Pos
+	if last[0] != 5 || last[2] != 3 {
+		t.Errorf("want last vertex at 5,3 but have %v,%v", last[0], last[2])
+	}
+}
+
+func TestHeightFieldMeshSharesVertices(t *testing.T) {
+	h := hillyField(5, 3)
+	m := HeightFieldMesh(h)
+	if m.VertexCount() != 6*4 {
+		t.Errorf("want %d vertices but have %d", 6*4, m.VertexCount())
+	}
+	if m.IndexCount() != 5*3*6 {
+		t.Errorf("want %d indices but have %d", 5*3*6, m.IndexCount())
+	}
+	if m.Indices16 == nil || m.Indices32 != nil {
+		t.Error("small meshes should use 16 bit indices")
+	}
+}
+
+func TestIndexSizeDependsOnVertexCount(t *testing.T) {
+	// 256*256 vertices can just be addressed with 16 bits
+	m := HeightFieldMesh(hillyField(255, 255))
+	if m.Indices16 == nil || m.Indices32 != nil {
+		t.Error("want 16 bit indices for 65536 vertices")
+	}
+	if have := m.Index(m.IndexCount() - 1); have != 65535 {
+		t.Errorf("want last index 65535 but have %d", have)
+	}
+	m = HeightFieldMesh(hillyField(256, 255))
+	if m.Indices16 != nil || m.Indices32 == nil {
+		t.Error("want 32 bit indices for more than 65536 vertices")
+	}
+	if have := m.Index(m.IndexCount() - 1); have != 257*256-1 {
+		t.Errorf("want last index %d but have %d", 257*256-1, have)
+	}
+}
+
+func TestHeightFieldMeshMatchesTriangleList(t *testing.T) {
+	h := hillyField(12, 9)
+	h.Scale = d3dmath.Vec3{0.25, 1.3, 0.5}
+	list := HeightFieldVertices(h)
+	m := HeightFieldMesh(h)
+	if m.IndexCount() != len(list) {
+		t.Fatalf("want %d indices but have %d", len(list), m.IndexCount())
+	}
+	for i := 0; i < m.IndexCount(); i++ {
+		a := list[i]
+		b := m.Vertices[m.Index(i)]
+		if a.Pos != b.Pos {
+			t.Fatalf("vertex %d: want position %v but have %v", i, a.Pos, b.Pos)
+		}
+		// HeightFieldVertices has upward normals in the cells at the edges
+		x, z := int(a.Pos[0]), int(a.Pos[2])
+		cell := i / 6
+		cellX, cellZ := cell%12, cell/12
+		if cellX == 0 || cellZ == 0 || cellX == 11 || cellZ == 8 {
+			continue
+		}
+		if x == 0 || z == 0 || x == 12 || z == 9 {
+			t.Fatalf("vertex %d at %d,%d is not in an inner cell", i, x, z)
+		}
+		for j := range a.Normal {
+			if abs(a.Normal[j]-b.Normal[j]) > 1e-5 {
+				t.Errorf("vertex %d at %d,%d: want normal %v but have %v", i, x, z, a.Normal, b.Normal)
+				break
+			}
+		}
+	}
+}
+
+func abs(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,144 @@
+package render
+
+import (
+	"github.com/gonutz/d3dmath"
+)
+
+// Overlay collects the 2D parts of a frame, like text and the crosshair, which
+// are drawn over the 3D scene. Everything is given in pixels with the origin
+// at the top-left of the screen and y pointing down. The quads are collected
+// into one vertex list, consecutive quads with the same texture are drawn as
+// one batch.
+type Overlay struct {
+	Width, Height float32 // of the screen, in pixels
+	Vertices      []OverlayVertex
+	Batches       []OverlayBatch
+}
+
+// OverlayVertex is a 2D vertex. Its color is multiplied with the texture.
+type OverlayVertex struct {
+	X, Y float32
+	// Color is ARGB with 8 bits each, like a Direct3D color.
+	Color uint32
+	U, V  float32
+}
+
+// OverlayBatch is a part of the Overlay's Vertices, drawn as a triangle list
+// with one texture.
+type OverlayBatch struct {
+	Texture   Texture // "" draws only the vertex colors
+	First     int     // index of the first vertex
+	Triangles int
+}
+
+// OverlayBackend draws the overlay over what was drawn before, without depth
+// test and with alpha blending, using Overlay.Projection.
+type OverlayBackend interface {
+	DrawOverlay(*Overlay)
+}
+
+// NewOverlay creates an empty overlay for a screen of the given size.
+func NewOverlay(width, height float32) *Overlay {
+	return &Overlay{Width: width, Height: height}
+}
+
+// Reset removes all quads and sets the screen size, the memory is kept for
+// the next frame.
+func (o *Overlay) Reset(width, height float32) {
+	o.Width, o.Height = width, height
+	o.Vertices = o.Vertices[:0]
+	o.Batches = o.Batches[:0]
+}
+
+// Projection maps pixel coordinates to clip space.
+func (o *Overlay) Projection() d3dmath.Mat4 {
+	return OrthoProjection(o.Width, o.Height)
+}
+
+// OrthoProjection maps pixels on a screen of size w by h, with y pointing
+// down, to clip space. The depth is 0.5 so nothing is clipped at the near or
+// far plane.
+func OrthoProjection(w, h float32) d3dmath.Mat4 {
+	return d3dmath.Mat4{
+		2 / w, 0, 0, 0,
+		0, -2 / h, 0, 0,
+		0, 0, 1, 0,
+		-1, 1, 0.5, 1,
+	}
+}
+
+// Rect adds an untextured rectangle.
+func (o *Overlay) Rect(x, y, w, h float32, color [4]float32) {
+	o.Image("", x, y, w, h, 0, 0, 1, 1, color)
+}
+
+// Image adds a rectangle showing the part of the texture from (u0, v0) to
+// (u1, v1), tinted with color.
+func (o *Overlay) Image(t Texture, x, y, w, h, u0, v0, u1, v1 float32, color [4]float32) {
+	c := argb(color)
+	o.quad(t, [6]OverlayVertex{
+		{X: x, Y: y, Color: c, U: u0, V: v0},
+		{X: x + w, Y: y, Color: c, U: u1, V: v0},
+		{X: x, Y: y + h, Color: c, U: u0, V: v1},
+		{X: x, Y: y + h, Color: c, U: u0, V: v1},
+		{X: x + w, Y: y, Color: c, U: u1, V: v0},
+		{X: x + w, Y: y + h, Color: c, U: u1, V: v1},
+	})
+}
+
+// Text adds a text with its top-left corner at x, y. Its glyphs are scaled
+// by scale, use whole numbers to keep them sharp. '\n' starts a new line,
+// characters that the font does not have are drawn as '?'.
+func (o *Overlay) Text(f *Font, text string, x, y, scale float32, color [4]float32) {
+	penX, penY := x, y
+	tw, th := float32(f.TextureW), float32(f.TextureH)
+	for _, r := range text {
+		if r == '\n' {
+			penX = x
+			penY += float32(f.LineHeight) * scale
+			continue
+		}
+		g, ok := f.glyph(r)
+		if !ok {
+			continue
+		}
+		if g.W > 0 && g.H > 0 {
+			o.Image(
+				f.Texture,
+				penX+float32(g.XOffset)*scale,
+				penY+float32(g.YOffset)*scale,
+				float32(g.W)*scale,
+				float32(g.H)*scale,
+				float32(g.X)/tw,
+				float32(g.Y)/th,
+				float32(g.X+g.W)/tw,
+				float32(g.Y+g.H)/th,
+				color,
+			)
+		}
+		penX += float32(g.Advance) * scale
+	}
+}
+
+func (o *Overlay) quad(t Texture, v [6]OverlayVertex) {
+	if n := len(o.Batches); n == 0 || o.Batches[n-1].Texture != t {
+		o.Batches = append(o.Batches, OverlayBatch{Texture: t, First: len(o.Vertices)})
+	}
+	o.Vertices = append(o.Vertices, v[:]...)
+	o.Batches[len(o.Batches)-1].Triangles += 2
+}
+
+// argb converts an RGBA color with channels from 0 to 1 to an OverlayVertex
+// color.
+func argb(c [4]float32) uint32 {
+	b := func(x float32) uint32 {
+		if x < 0 {
+			x = 0
+		}
+		if x > 1 {
+			x = 1
+		}
+		return uint32(x*255 + 0.5)
+	}
+	return b(c[3])<<24 | b(c[0])<<16 | b(c[1])<<8 | b(c[2])
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+)
+
+const testFont = `# two glyphs
+texture font.png
+size 64 32
+
+line 10
+65 0 0 5 8 1 2 6
+63 8 0 4 8 0 2 5
+32 0 0 0 0 0 0 4
+`
+
+func parseTestFont(t *testing.T) *Font {
+	f, err := ParseFont(strings.NewReader(testFont))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestParseFont(t *testing.T) {
+	f := parseTestFont(t)
+	if f.Texture != "font.png" || f.TextureW != 64 || f.TextureH != 32 || f.LineHeight != 10 {
+		t.Errorf("unexpected font %+v", f)
+	}
+	want := Glyph{X: 0, Y: 0, W: 5, H: 8, XOffset: 1, YOffset: 2, Advance: 6}
+	if g := f.Glyphs['A']; g != want {
+		t.Errorf("want glyph %+v but have %+v", want, g)
+	}
+	if len(f.Glyphs) != 3 {
+		t.Errorf("want 3 glyphs but have %d", len(f.Glyphs))
+	}
+}
+
+func TestInvalidFontsAreErrors(t *testing.T) {
+	for _, text := range []string{
+		"size 64 32\nline 10",
+		"texture font.png\nline 10",
+		"texture font.png\nsize 64 32",
+		"texture font.png\nsize 64 32\nline 10\n65 0 0 5 8 1 2",
+		"texture font.png\nsize 64 32\nline 10\nglyph 0 0 5 8 1 2 6",
+		"texture font.png\nsize 64 32\nline 10\n65 0 0 -5 8 1 2 6",
+	} {
+		if _, err := ParseFont(strings.NewReader(text)); err == nil {
+			t.Errorf("no error for font\n%s", text)
+		}
+	}
+}
+
+func TestMeasureText(t *testing.T) {
+	f := parseTestFont(t)
+	if w, h := f.Measure("AA A"); w != 6+6+4+6 || h != 10 {
+		t.Errorf("want 22x10 but have %dx%d", w, h)
+	}
+	if w, h := f.Measure("A\nAAx"); w != 6+6+5 || h != 20 {
+		t.Errorf("unknown characters should measure as '?', want 17x20 but have %dx%d", w, h)
+	}
+}
+
+func TestTextQuads(t *testing.T) {
+	f := parseTestFont(t)
+	o := NewOverlay(100, 50)
+	o.Text(f, "A A", 10, 20, 2, [4]float32{1, 0, 0, 1})
+	// the space has no quad
+	if len(o.Vertices) != 2*6 {
+		t.Fatalf("want 12 vertices but have %d", len(o.Vertices))
+	}
+	first := o.Vertices[0]
+	if first.X != 10+2*1 || first.Y != 20+2*2 || first.U != 0 || first.V != 0 {
+		t.Errorf("unexpected top-left vertex %+v", first)
+	}
+	if first.Color != 0xFFFF0000 {
+		t.Errorf("want color FFFF0000 but have %X", first.Color)
+	}
+	second := o.Vertices[6]
+	if want := float32(10 + 2*(6+4+1)); second.X != want {
+		t.Errorf("want second glyph at x=%v but it is at %v", want, second.X)
+	}
+	bottomRight := o.Vertices[5]
+	if bottomRight.U != 5.0/64 || bottomRight.V != 8.0/32 {
+		t.Errorf("unexpected texture coordinates %v,%v", bottomRight.U, bottomRight.V)
+	}
+}
+
+func TestOverlayBatchesByTexture(t *testing.T) {
+	f := parseTestFont(t)
+	o := NewOverlay(100, 50)
+	o.Rect(0, 0, 10, 10, [4]float32{1, 1, 1, 1})
+	o.Rect(0, 20, 10, 10, [4]float32{1, 1, 1, 1})
+	o.Text(f, "AA", 0, 0, 1, [4]float32{1, 1, 1, 1})
+	o.Rect(0, 40, 10, 10, [4]float32{1, 1, 1, 1})
+	want := []OverlayBatch{
+		{Texture: "", First: 0, Triangles: 4},
+		{Texture: "font.png", First: 12, Triangles: 4},
+		{Texture: "", First: 24, Triangles: 2},
+	}
+	if len(o.Batches) != len(want) {
+		t.Fatalf("want batches %v but have %v", want, o.Batches)
+	}
+	for i := range want {
+		if o.Batches[i] != want[i] {
+			t.Errorf("want batch %v but have %v", want[i], o.Batches[i])
+		}
+	}
+
+	o.Reset(200, 100)
+	if len(o.Vertices) != 0 || len(o.Batches) != 0 || o.Width != 200 || o.Height != 100 {
+		t.Errorf("reset should clear the overlay but have %+v", o)
+	}
+}
+
+func TestOrthoProjectionMapsScreenCorners(t *testing.T) {
+	m := OrthoProjection(200, 100)
+	for _, c := range []struct{ x, y, clipX, clipY float32 }{
+		{0, 0, -1, 1},
+		{200, 0, 1, 1},
+		{0, 100, -1, -1},
+		{100, 50, 0, 0},
+	} {
+		p := d3dmath.Vec4{c.x, c.y, 0, 1}.MulMat(m)
+		if p[0] != c.clipX || p[1] != c.clipY || p[3] != 1 {
+			t.Errorf("%v,%v: want clip %v,%v but have %v", c.x, c.y, c.clipX, c.clipY, p)
+		}
+	}
+}
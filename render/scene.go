@@ -0,0 +1,246 @@
+// Package render describes what the game draws each frame, independent of the
+// graphics API. Draw turns a Scene into a list of DrawCalls which a Backend
+// executes. The game uses a Direct3D 9 backend, package soft has a CPU
+// rasterizer for tests and screenshots on machines without a GPU.
+package render
+
+import (
+	"math"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+	"github.com/gonutz/ld40/level"
+)
+
+// DefaultFieldOfView is the vertical field of view of a new Scene, in degrees.
+const DefaultFieldOfView = 60
+
+// Shader selects one of the game's shader pairs and thus the vertex layout of
+// the Mesh that is drawn with it.
+type Shader int
+
+const (
+	// Textured draws position and uv vertices with a texture.
+	Textured Shader = iota
+	// TexturedLit draws position, normal and uv vertices with a texture, a
+	// directional light and fog, see DrawCall.Light and DrawCall.Fog.
+	TexturedLit
+	// UniformColor draws position only vertices in DrawCall.Color.
+	UniformColor
+)
+
+// Mesh identifies one of the vertex buffers of the scene.
+type Mesh int
+
+const (
+	SkyMesh    Mesh = iota // SkyVertices
+	PropMesh               // PropVertices of the level's props
+	GroundMesh             // Mesh of the Terrain, indexed
+	LaserMesh              // SquareVertices
+)
+
+// Texture is the file name of a texture, "" means no texture.
+type Texture string
+
+type DrawCall struct {
+	Shader  Shader
+	Mesh    Mesh
+	Texture Texture
+	// MVP is the row-major model-view-projection matrix.
+	MVP d3dmath.Mat4
+	// Color is RGBA for the UniformColor shader.
+	Color [4]float32
+	// Model is the row-major model matrix that MVP starts with. TexturedLit
+	// transforms normals with its NormalMatrix and measures the fog distance
+	// in world space.
+	Model d3dmath.Mat4
+	// Eye is the camera position in world space, for the fog of TexturedLit.
+	Eye d3dmath.Vec3
+	// Light and Fog are used by TexturedLit.
+	Light level.Light
+	Fog   level.Fog
+	// FirstTriangle and Triangles select the part of the mesh to draw. For
+	// indexed meshes they count the triangles in the index list.
+	FirstTriangle int
+	Triangles     int
+	// DepthTest enables depth testing and writing.
+	DepthTest bool
+	// CullClockwise culls triangles that appear clockwise on the screen.
+	CullClockwise bool
+	// Blend enables alpha blending with source alpha.
+	Blend bool
+}
+
+type Backend interface {
+	Draw(DrawCall)
+}
+
+// Scene is everything that is needed to draw one frame.
+type Scene struct {
+	Eye           d3dmath.Vec3 // camera position
+	ViewDir       d3dmath.Vec3 // must be unit length
+	Aspect        float32      // viewport width / height
+	FieldOfView   float32      // vertical, in degrees
+	Terrain       *Terrain
+	GroundTexture Texture
+	SkyTexture    Texture
+	Props         []level.Prop
+	LaserBeams    []game.LaserBeam
+	Light         level.Light
+	Fog           level.Fog
+}
+
+// NewScene creates the scene from the player's point of view. The player is
+// interpolated between the last two simulation steps, see game.World. The
+// terrain must be created from the world's Ground.
+func NewScene(w *game.World, l *level.Level, t *Terrain, alpha, aspect float32) Scene {
+	eye, viewDir := w.Interpolated(alpha)
+	eye[1] += w.PlayerHeight
+	return Scene{
+		Eye:           eye,
+		ViewDir:       viewDir,
+		Aspect:        aspect,
+		FieldOfView:   DefaultFieldOfView,
+		Terrain:       t,
+		GroundTexture: Texture(l.TerrainTexture),
+		SkyTexture:    Texture(l.SkyTexture),
+		Props:         l.Props,
+		LaserBeams:    w.LaserBeams,
+		Light:         l.Light,
+		Fog:           l.Fog,
+	}
+}
+
+func (s Scene) projection() d3dmath.Mat4 {
+	return d3dmath.Perspective(
+		deg2rad(s.FieldOfView),
+		s.Aspect,
+		100,
+		0.001,
+	)
+}
+
+// ViewProjection is the camera transform for everything but the sky.
+func (s Scene) ViewProjection() d3dmath.Mat4 {
+	v := d3dmath.LookAt(
+		s.Eye,
+		s.Eye.Add(s.ViewDir),
+		d3dmath.Vec3{0, 1, 0},
+	)
+	return d3dmath.Mul4(v, s.projection())
+}
+
+// SkyMVP places the sky box around the camera so that it never comes closer.
+func (s Scene) SkyMVP() d3dmath.Mat4 {
+	m := d3dmath.Translate(0, 0, 0)
+	v := d3dmath.LookAt(
+		d3dmath.Vec3{},
+		s.ViewDir,
+		d3dmath.Vec3{0, 1, 0},
+	)
+	return d3dmath.Mul4(m, v, s.projection())
+}
+
+// LaserTransform is the model matrix that stretches the SquareVertices into a
+// thin beam.
+func LaserTransform(beam game.LaserBeam) d3dmath.Mat4 {
+	diff := beam.End.Sub(beam.Start)
+	length := diff.Norm()
+	scale := d3dmath.Scale(0.005, 1, length)
+	offset := d3dmath.TranslateV(beam.Start)
+	yRad := math.Atan2(float64(diff[2]), float64(diff[0]))
+	rotY := d3dmath.RotateY(math.Pi/2 - float32(yRad))
+	xRad := math.Atan2(float64(length), float64(-diff[1]))
+	rotX := d3dmath.RotateX(math.Pi/2 - float32(xRad))
+	return d3dmath.Mul4(scale, rotX, rotY, offset)
+}
+
+// Draw renders the scene: sky box first, then the world and last the
+// translucent laser beams.
+func Draw(b Backend, s Scene) {
+	vp := s.ViewProjection()
+
+	b.Draw(DrawCall{
+		Shader:        Textured,
+		Mesh:          SkyMesh,
+		Texture:       s.SkyTexture,
+		MVP:           s.SkyMVP(),
+		Triangles:     12,
+		CullClockwise: true,
+	})
+
+	for i, prop := range s.Props {
+		b.Draw(DrawCall{
+			Shader:        Textured,
+			Mesh:          PropMesh,
+			Texture:       Texture(prop.Texture),
+			MVP:           vp,
+			FirstTriangle: i,
+			Triangles:     1,
+			DepthTest:     true,
+		})
+	}
+
+	groundModel := s.Terrain.Ground.ModelTransform()
+	groundMVP := groundModel.Mul(vp)
+	for _, r := range s.Terrain.DrawRanges(vp, s.Eye) {
+		b.Draw(DrawCall{
+			Shader:        TexturedLit,
+			Mesh:          GroundMesh,
+			Texture:       s.GroundTexture,
+			MVP:           groundMVP,
+			Model:         groundModel,
+			Eye:           s.Eye,
+			Light:         s.Light,
+			Fog:           s.Fog,
+			FirstTriangle: r.First,
+			Triangles:     r.Count,
+			DepthTest:     true,
+			CullClockwise: true,
+		})
+	}
+
+	for _, beam := range s.LaserBeams {
+		b.Draw(DrawCall{
+			Shader:        UniformColor,
+			Mesh:          LaserMesh,
+			MVP:           d3dmath.Mul4(LaserTransform(beam), vp),
+			Color:         [4]float32{1, 0, 0, beam.Life},
+			Triangles:     2,
+			DepthTest:     true,
+			CullClockwise: true,
+			Blend:         true,
+		})
+	}
+}
+
+// NormalMatrix transforms normals for the model matrix m: it is the inverse
+// transpose of its upper 3x3 part, which keeps normals perpendicular to their
+// surface under non-uniform scaling. The results have to be normalized.
+func NormalMatrix(m d3dmath.Mat4) d3dmath.Mat4 {
+	at := func(row, col int) float32 { return m[row*4+col] }
+	// cofactor c(row, col) of the 3x3 part, the rows and columns of its minor
+	// are the other two in cyclic order, which takes care of the sign
+	cofactor := func(row, col int) float32 {
+		r1, r2 := (row+1)%3, (row+2)%3
+		c1, c2 := (col+1)%3, (col+2)%3
+		return at(r1, c1)*at(r2, c2) - at(r1, c2)*at(r2, c1)
+	}
+	det := at(0, 0)*cofactor(0, 0) + at(0, 1)*cofactor(0, 1) + at(0, 2)*cofactor(0, 2)
+	if det == 0 {
+		return d3dmath.Identity4()
+	}
+	// the inverse is the transposed cofactor matrix over the determinant, so
+	// the inverse transpose is the cofactor matrix itself
+	n := d3dmath.Identity4()
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			n[row*4+col] = cofactor(row, col) / det
+		}
+	}
+	return n
+}
+
+func deg2rad(x float32) float32 {
+	return x * math.Pi / 180
+}
@@ -0,0 +1,41 @@
+package render
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+)
+
+func TestNormalMatrixKeepsNormalsPerpendicular(t *testing.T) {
+	h := game.HeightField{
+		Heights: [][]float32{{0, 0}, {0, 0}},
+		Scale:   d3dmath.Vec3{0.25, 1.3, 0.5},
+	}
+	model := d3dmath.Mul4(h.ModelTransform(), d3dmath.RotateY(0.3))
+	normals := NormalMatrix(model)
+
+	// a slope in model space, its normal leans towards -x
+	a, b, c := d3dmath.Vec3{0, 0, 0}, d3dmath.Vec3{1, 1, 0}, d3dmath.Vec3{0, 0, 1}
+	n := c.Sub(a).Cross(b.Sub(a)).Normalized()
+	transform := func(p d3dmath.Vec3) d3dmath.Vec3 {
+		return p.Homogeneous().MulMat(model).DropW()
+	}
+	worldN := d3dmath.Vec4{n[0], n[1], n[2], 0}.MulMat(normals).DropW().Normalized()
+	for _, edge := range []d3dmath.Vec3{
+		transform(b).Sub(transform(a)),
+		transform(c).Sub(transform(a)),
+	} {
+		if d := worldN.Dot(edge.Normalized()); math.Abs(float64(d)) > 1e-5 {
+			t.Errorf("transformed normal %v is not perpendicular to %v, dot is %v", worldN, edge, d)
+		}
+	}
+	if worldN[1] <= 0 {
+		t.Errorf("the normal should still point up but is %v", worldN)
+	}
+
+	if NormalMatrix(d3dmath.Mat4{}) != d3dmath.Identity4() {
+		t.Error("a singular matrix should leave normals as they are")
+	}
+}
@@ -0,0 +1,266 @@
+package soft
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+	"github.com/gonutz/ld40/level"
+	"github.com/gonutz/ld40/menu"
+	"github.com/gonutz/ld40/render"
+)
+
+// Run
+//
+//	go test ./render/soft -update
+//
+// after an intended visual change to re-create the reference images. Check the
+// new images before committing them.
+var update = flag.Bool("update", false, "write new golden images")
+
+const (
+	goldenDir  = "testdata/golden"
+	failedDir  = "testdata/failed"
+	goldenW    = 160
+	goldenH    = 120
+	assetsPath = "../.."
+)
+
+// tolerances for comparing against the golden images, small differences come
+// from floating point rounding on other platforms
+const (
+	maxChannelDiff   = 8     // per color channel, 0..255
+	maxBadPixelRatio = 0.002 // share of pixels allowed to exceed maxChannelDiff
+)
+
+func TestGoldenImages(t *testing.T) {
+	open := level.DirOpener(assetsPath)
+	l, err := level.Load("level.json", open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ground, err := l.LoadGround(open)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	terrain := render.NewTerrain(ground)
+	r := New(goldenW, goldenH)
+	if err := r.LoadLevel(assetsPath, l, terrain); err != nil {
+		t.Fatal(err)
+	}
+	font, err := render.LoadFont("font.txt", open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fontImage, err := LoadPng(filepath.Join(assetsPath, string(font.Texture)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetTexture(font.Texture, fontImage)
+
+	steps := func(w *game.World, n int, in game.Input) {
+		for i := 0; i < n; i++ {
+			w.Step(in, float32(game.TimeStep.Seconds()))
+			in.Jump, in.Shoot = false, false
+			in.MouseDx, in.MouseDy = 0, 0
+		}
+	}
+
+	poses := []struct {
+		name  string
+		world func() *game.World
+		// scene changes the scene before it is drawn, if set
+		scene func(*render.Scene)
+		// overlay is drawn on top of the scene, if set
+		overlay func(*Renderer)
+	}{
+		{
+			name: "spawn",
+			world: func() *game.World {
+				w := l.NewWorld(ground)
+				steps(w, 1, game.Input{})
+				return w
+			},
+		},
+		{
+			name: "triangles",
+			world: func() *game.World {
+				w := l.NewWorld(ground)
+				w.Spawn[0], w.Spawn[2] = -2.5, -2
+				w.Respawn()
+				steps(w, 1, game.Input{})
+				return w
+			},
+		},
+		{
+			name: "jump_over_hill",
+			world: func() *game.World {
+				w := l.NewWorld(ground)
+				steps(w, 60, game.Input{Forward: true, Run: true})
+				steps(w, 1, game.Input{Forward: true, Run: true, Jump: true})
+				steps(w, 15, game.Input{Forward: true, Run: true})
+				return w
+			},
+		},
+		{
+			name: "laser",
+			world: func() *game.World {
+				w := l.NewWorld(ground)
+				steps(w, 1, game.Input{MouseDx: 80, MouseDy: 60})
+				steps(w, 1, game.Input{Shoot: true})
+				steps(w, 3, game.Input{})
+				return w
+			},
+		},
+		{
+			name: "fog",
+			world: func() *game.World {
+				return l.NewWorld(ground)
+			},
+			scene: func(s *render.Scene) {
+				s.Light = level.Light{
+					Direction: d3dmath.Vec3{1, -0.3, 0},
+					Color:     [3]float32{1, 0.7, 0.4},
+					Ambient:   [3]float32{0.2, 0.2, 0.4},
+				}
+				s.Fog = level.Fog{Mode: level.ExpFog, Color: [3]float32{0.6, 0.6, 0.7}, Density: 0.15}
+			},
+		},
+		{
+			name: "options_menu",
+			world: func() *game.World {
+				return l.NewWorld(ground)
+			},
+			overlay: func(b *Renderer) {
+				m := menu.New(menu.Options{Sensitivity: 0.125, FieldOfView: 60, Volume: 0.5})
+				m.Open()
+				m.Key(menu.Down)
+				m.Key(menu.Enter)
+				render.DrawMenu(b, m)
+			},
+		},
+		{
+			name: "overlay_text",
+			world: func() *game.World {
+				return l.NewWorld(ground)
+			},
+			overlay: func(b *Renderer) {
+				o := render.NewOverlay(goldenW, goldenH)
+				o.Rect(2, 2, 80, 30, [4]float32{0, 0, 0, 0.5})
+				o.Text(font, "Overlay\nText", 4, 4, 1, [4]float32{1, 1, 0.5, 1})
+				o.Text(font, "2x", 100, 80, 2, [4]float32{1, 1, 1, 1})
+				b.DrawOverlay(o)
+			},
+		},
+		{
+			name: "hud",
+			world: func() *game.World {
+				return l.NewWorld(ground)
+			},
+			overlay: func(b *Renderer) {
+				h := render.HUD{Target: 1.0 / 60}
+				for i := 0; i < 100; i++ {
+					h.Frames.Add(1.0/60 + float32(i%25)/1000)
+				}
+				o := render.NewOverlay(goldenW, goldenH)
+				render.DrawHUD(o, font, &h, l.NewWorld(ground))
+				b.DrawOverlay(o)
+			},
+		},
+	}
+
+	for _, pose := range poses {
+		t.Run(pose.name, func(t *testing.T) {
+			r.Clear(color.RGBA{255, 0, 0, 255})
+			scene := render.NewScene(pose.world(), l, terrain, 1, float32(goldenW)/goldenH)
+			if pose.scene != nil {
+				pose.scene(&scene)
+			}
+			render.Draw(r, scene)
+			if pose.overlay != nil {
+				pose.overlay(r)
+			}
+			compareGolden(t, pose.name, r.Image)
+		})
+	}
+}
+
+func compareGolden(t *testing.T, name string, have *image.RGBA) {
+	path := filepath.Join(goldenDir, name+".png")
+	if *update {
+		if err := writePng(path, have); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := LoadPng(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if want.Bounds() != have.Bounds() {
+		t.Fatalf("want image size %v but have %v", want.Bounds(), have.Bounds())
+	}
+
+	diff := image.NewRGBA(have.Bounds())
+	bad := 0
+	for i := 0; i < len(have.Pix); i += 4 {
+		d := 0
+		for c := 0; c < 3; c++ {
+			if x := absInt(int(have.Pix[i+c]) - int(want.Pix[i+c])); x > d {
+				d = x
+			}
+		}
+		diff.Pix[i+3] = 255
+		if d > maxChannelDiff {
+			bad++
+			diff.Pix[i] = 255
+		} else {
+			diff.Pix[i+0] = want.Pix[i+0] / 4
+			diff.Pix[i+1] = want.Pix[i+1] / 4
+			diff.Pix[i+2] = want.Pix[i+2] / 4
+		}
+	}
+
+	pixels := len(have.Pix) / 4
+	if float64(bad)/float64(pixels) > maxBadPixelRatio {
+		msg := fmt.Sprintf("%d of %d pixels differ from %s", bad, pixels, path)
+		if err := writePng(filepath.Join(failedDir, name+".png"), have); err != nil {
+			t.Error(err)
+		}
+		diffPath := filepath.Join(failedDir, name+"_diff.png")
+		if err := writePng(diffPath, diff); err != nil {
+			t.Error(err)
+		} else {
+			msg += ", differences are marked red in " + diffPath
+		}
+		t.Error(msg)
+	}
+}
+
+func writePng(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
+
+func absInt(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,452 @@
+// Package soft is a CPU rasterizer backend for package render. It mimics what
+// the game's Direct3D 9 shaders do closely enough to take screenshots on
+// machines without a GPU or Windows and to compare rendering changes in tests.
+package soft
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/level"
+	"github.com/gonutz/ld40/render"
+)
+
+// nearW is the closest distance to the camera (in clip space w) that is drawn.
+// Triangles are clipped against it.
+const nearW = 0.0001
+
+// Renderer draws into Image. Its depth buffer stores 1/w of the closest
+// fragment per pixel, 0 means nothing was drawn yet.
+type Renderer struct {
+	Image    *image.RGBA
+	depth    []float32
+	meshes   map[render.Mesh][]render.LitVertex
+	indexed  map[render.Mesh]render.IndexedMesh
+	textures map[render.Texture]*image.RGBA
+}
+
+func New(width, height int) *Renderer {
+	return &Renderer{
+		Image:    image.NewRGBA(image.Rect(0, 0, width, height)),
+		depth:    make([]float32, width*height),
+		meshes:   make(map[render.Mesh][]render.LitVertex),
+		indexed:  make(map[render.Mesh]render.IndexedMesh),
+		textures: make(map[render.Texture]*image.RGBA),
+	}
+}
+
+// SetMesh sets the vertices for a mesh, a slice of the vertex struct of the
+// shader which draws it, e.g. []render.TexturedVertex for the SkyMesh. Other
+// types are an error.
+func (r *Renderer) SetMesh(m render.Mesh, vertices interface{}) error {
+	lit, err := litVertices(vertices)
+	if err != nil {
+		return err
+	}
+	r.meshes[m] = lit
+	return nil
+}
+
+// litVertices converts the vertices of any shader to LitVertex, which has
+// everything that the vertex shaders read.
+func litVertices(vertices interface{}) ([]render.LitVertex, error) {
+	switch v := vertices.(type) {
+	case []render.LitVertex:
+		return v, nil
+	case []render.TexturedVertex:
+		lit := make([]render.LitVertex, len(v))
+		for i := range v {
+			lit[i] = render.LitVertex{Pos: v[i].Pos, UV: v[i].UV}
+		}
+		return lit, nil
+	case []render.PositionVertex:
+		lit := make([]render.LitVertex, len(v))
+		for i := range v {
+			lit[i] = render.LitVertex{Pos: v[i].Pos}
+		}
+		return lit, nil
+	}
+	return nil, fmt.Errorf("soft: unsupported vertices %T", vertices)
+}
+
+// SetIndexedMesh sets the vertices and indices for a mesh. The triangles in a
+// DrawCall for it select from the indices.
+func (r *Renderer) SetIndexedMesh(m render.Mesh, mesh render.IndexedMesh) {
+	r.meshes[m] = mesh.Vertices
+	r.indexed[m] = mesh
+}
+
+// SetTexture sets the image for a texture. The game's PNG files have red and
+// blue swapped because Direct3D expects BGRA, the image is expected in that
+// same layout so it can be loaded from the very same files.
+func (r *Renderer) SetTexture(t render.Texture, img *image.RGBA) {
+	r.textures[t] = img
+}
+
+// LoadLevel loads the level's textures from dir and creates all meshes for the
+// level and the given terrain.
+func (r *Renderer) LoadLevel(dir string, l *level.Level, terrain *render.Terrain) error {
+	for _, name := range l.Textures() {
+		img, err := LoadPng(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		r.SetTexture(render.Texture(name), img)
+	}
+	for _, m := range []struct {
+		mesh     render.Mesh
+		vertices interface{}
+	}{
+		{render.SkyMesh, render.SkyVertices},
+		{render.PropMesh, render.PropVertices(l.Props)},
+		{render.LaserMesh, render.SquareVertices},
+	} {
+		if err := r.SetMesh(m.mesh, m.vertices); err != nil {
+			return err
+		}
+	}
+	r.SetIndexedMesh(render.GroundMesh, terrain.Mesh)
+	return nil
+}
+
+func LoadPng(path string) (*image.RGBA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba, nil
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba, nil
+}
+
+// Clear fills the image with c and resets the depth buffer.
+func (r *Renderer) Clear(c color.RGBA) {
+	draw.Draw(r.Image, r.Image.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	for i := range r.depth {
+		r.depth[i] = 0
+	}
+}
+
+// vertex is a transformed vertex: clip space position and the values that the
+// vertex shader passes on to the pixel shader.
+type vertex struct {
+	pos   d3dmath.Vec4
+	u, v  float32
+	light [3]float32 // diffuse light, only used by TexturedLit
+	fog   float32    // visibility through the fog, only used by TexturedLit
+	color [4]float32 // only used by the overlay
+}
+
+// Draw implements render.Backend.
+func (r *Renderer) Draw(c render.DrawCall) {
+	data, ok := r.meshes[c.Mesh]
+	if !ok {
+		panic(errors.New("soft: mesh not set"))
+	}
+
+	normalMatrix := render.NormalMatrix(c.Model)
+	toLight := c.Light.Direction.Normalized().MulScalar(-1)
+	vertexShader := func(v render.LitVertex) vertex {
+		pos := v.Pos.Homogeneous()
+		out := vertex{pos: pos.MulMat(c.MVP)}
+		switch c.Shader {
+		case render.Textured:
+			out.u, out.v = v.UV[0], v.UV[1]
+		case render.TexturedLit:
+			normal := d3dmath.Vec4{v.Normal[0], v.Normal[1], v.Normal[2], 0}.MulMat(normalMatrix).DropW().Normalized()
+			power := normal.Dot(toLight)
+			for i := range out.light {
+				out.light[i] = clamp01(c.Light.Color[i] * power)
+			}
+			out.fog = c.Fog.Visibility(pos.MulMat(c.Model).DropW().Sub(c.Eye).Norm())
+			out.u, out.v = v.UV[0], v.UV[1]
+		}
+		return out
+	}
+
+	tex := r.textures[c.Texture]
+	pixelShader := func(v vertex) (r, g, b, a float32) {
+		switch c.Shader {
+		case render.Textured:
+			return sample(tex, v.u, v.v)
+		case render.TexturedLit:
+			r, g, b, a = sample(tex, v.u, v.v)
+			lit := [3]float32{r, g, b}
+			for i := range lit {
+				lit[i] *= clamp01(v.light[i] + c.Light.Ambient[i])
+				lit[i] = c.Fog.Color[i] + v.fog*(lit[i]-c.Fog.Color[i])
+			}
+			return lit[0], lit[1], lit[2], a
+		default:
+			return c.Color[0], c.Color[1], c.Color[2], c.Color[3]
+		}
+	}
+
+	index := func(i int) int { return i }
+	indexCount := len(data)
+	if mesh, ok := r.indexed[c.Mesh]; ok {
+		index = mesh.Index
+		indexCount = mesh.IndexCount()
+	}
+
+	for t := c.FirstTriangle; t < c.FirstTriangle+c.Triangles; t++ {
+		if 3*t+3 > indexCount {
+			break
+		}
+		tri := [3]vertex{
+			vertexShader(data[index(3*t)]),
+			vertexShader(data[index(3*t+1)]),
+			vertexShader(data[index(3*t+2)]),
+		}
+		poly := clipNear(tri[:])
+		for i := 2; i < len(poly); i++ {
+			r.rasterize([3]vertex{poly[0], poly[i-1], poly[i]}, c, pixelShader)
+		}
+	}
+}
+
+// DrawOverlay implements render.OverlayBackend. The texture is multiplied with
+// the vertex colors like the fixed function pipeline does in the game.
+func (r *Renderer) DrawOverlay(o *render.Overlay) {
+	proj := o.Projection()
+	c := render.DrawCall{Blend: true}
+	for _, b := range o.Batches {
+		tex := r.textures[b.Texture]
+		pixelShader := func(v vertex) (r, g, b, a float32) {
+			r, g, b, a = sample(tex, v.u, v.v)
+			return r * v.color[0], g * v.color[1], b * v.color[2], a * v.color[3]
+		}
+		for t := 0; t < b.Triangles; t++ {
+			var tri [3]vertex
+			for i := range tri {
+				in := o.Vertices[b.First+3*t+i]
+				tri[i] = vertex{
+					pos:   d3dmath.Vec4{in.X, in.Y, 0, 1}.MulMat(proj),
+					u:     in.U,
+					v:     in.V,
+					color: unpackColor(in.Color),
+				}
+			}
+			r.rasterize(tri, c, pixelShader)
+		}
+	}
+}
+
+// unpackColor converts an ARGB render.OverlayVertex color to RGBA from 0 to 1.
+func unpackColor(c uint32) [4]float32 {
+	channel := func(shift uint) float32 { return float32(c>>shift&0xFF) / 255 }
+	return [4]float32{channel(16), channel(8), channel(0), channel(24)}
+}
+
+// clipNear clips the triangle against the plane w = nearW, the result is a
+// convex polygon with up to 4 vertices.
+func clipNear(tri []vertex) []vertex {
+	var out []vertex
+	for i := range tri {
+		a, b := tri[i], tri[(i+1)%len(tri)]
+		aIn, bIn := a.pos[3] >= nearW, b.pos[3] >= nearW
+		if aIn {
+			out = append(out, a)
+		}
+		if aIn != bIn {
+			t := (nearW - a.pos[3]) / (b.pos[3] - a.pos[3])
+			out = append(out, lerpVertex(a, b, t))
+		}
+	}
+	return out
+}
+
+func lerpVertex(a, b vertex, t float32) vertex {
+	var v vertex
+	for i := range v.pos {
+		v.pos[i] = a.pos[i] + t*(b.pos[i]-a.pos[i])
+	}
+	v.u = a.u + t*(b.u-a.u)
+	v.v = a.v + t*(b.v-a.v)
+	for i := range v.light {
+		v.light[i] = a.light[i] + t*(b.light[i]-a.light[i])
+	}
+	v.fog = a.fog + t*(b.fog-a.fog)
+	for i := range v.color {
+		v.color[i] = a.color[i] + t*(b.color[i]-a.color[i])
+	}
+	return v
+}
+
+func (r *Renderer) rasterize(
+	tri [3]vertex,
+	c render.DrawCall,
+	pixelShader func(vertex) (r, g, b, a float32),
+) {
+	width, height := r.Image.Bounds().Dx(), r.Image.Bounds().Dy()
+
+	// project to screen space, y points down
+	var x, y, invW [3]float32
+	for i, v := range tri {
+		invW[i] = 1 / v.pos[3]
+		x[i] = (v.pos[0]*invW[i] + 1) / 2 * float32(width)
+		y[i] = (1 - v.pos[1]*invW[i]) / 2 * float32(height)
+	}
+
+	area := (x[1]-x[0])*(y[2]-y[0]) - (y[1]-y[0])*(x[2]-x[0])
+	if area == 0 {
+		return
+	}
+	// with y pointing down, a positive area means clockwise
+	if c.CullClockwise && area > 0 {
+		return
+	}
+	if area < 0 {
+		x[1], x[2] = x[2], x[1]
+		y[1], y[2] = y[2], y[1]
+		invW[1], invW[2] = invW[2], invW[1]
+		tri[1], tri[2] = tri[2], tri[1]
+		area = -area
+	}
+
+	minX := screenBound(floor(min3(x[0], x[1], x[2])), width)
+	maxX := screenBound(ceil(max3(x[0], x[1], x[2])), width)
+	minY := screenBound(floor(min3(y[0], y[1], y[2])), height)
+	maxY := screenBound(ceil(max3(y[0], y[1], y[2])), height)
+
+	edge := func(i, j int, px, py float32) float32 {
+		return (x[j]-x[i])*(py-y[i]) - (y[j]-y[i])*(px-x[i])
+	}
+	// top-left rule: pixels exactly on an edge belong to the triangle only
+	// for top and left edges so shared edges are not drawn twice
+	topLeft := func(i, j int) bool {
+		dx, dy := x[j]-x[i], y[j]-y[i]
+		return (dy == 0 && dx > 0) || dy < 0
+	}
+	tl := [3]bool{topLeft(1, 2), topLeft(2, 0), topLeft(0, 1)}
+
+	for py := minY; py <= maxY; py++ {
+		for px := minX; px <= maxX; px++ {
+			cx, cy := float32(px)+0.5, float32(py)+0.5
+			w := [3]float32{edge(1, 2, cx, cy), edge(2, 0, cx, cy), edge(0, 1, cx, cy)}
+			inside := true
+			for i := range w {
+				if w[i] < 0 || (w[i] == 0 && !tl[i]) {
+					inside = false
+				}
+			}
+			if !inside {
+				continue
+			}
+			b0, b1, b2 := w[0]/area, w[1]/area, w[2]/area
+
+			z := b0*invW[0] + b1*invW[1] + b2*invW[2]
+			di := py*width + px
+			if c.DepthTest && z <= r.depth[di] {
+				continue
+			}
+
+			// perspective correct interpolation
+			p0, p1, p2 := b0*invW[0]/z, b1*invW[1]/z, b2*invW[2]/z
+			v := vertex{
+				u:   p0*tri[0].u + p1*tri[1].u + p2*tri[2].u,
+				v:   p0*tri[0].v + p1*tri[1].v + p2*tri[2].v,
+				fog: p0*tri[0].fog + p1*tri[1].fog + p2*tri[2].fog,
+			}
+			for i := range v.light {
+				v.light[i] = p0*tri[0].light[i] + p1*tri[1].light[i] + p2*tri[2].light[i]
+			}
+			for i := range v.color {
+				v.color[i] = p0*tri[0].color[i] + p1*tri[1].color[i] + p2*tri[2].color[i]
+			}
+			red, green, blue, alpha := pixelShader(v)
+
+			pi := r.Image.PixOffset(px, py)
+			pix := r.Image.Pix[pi : pi+4 : pi+4]
+			if c.Blend {
+				alpha = clamp01(alpha)
+				red = red*alpha + float32(pix[0])/255*(1-alpha)
+				green = green*alpha + float32(pix[1])/255*(1-alpha)
+				blue = blue*alpha + float32(pix[2])/255*(1-alpha)
+			}
+			pix[0] = toByte(red)
+			pix[1] = toByte(green)
+			pix[2] = toByte(blue)
+			pix[3] = 255
+			if c.DepthTest {
+				r.depth[di] = z
+			}
+		}
+	}
+}
+
+// sample does point sampling with wrapping texture coordinates. The texture
+// has red and blue swapped, see SetTexture.
+func sample(img *image.RGBA, u, v float32) (r, g, b, a float32) {
+	if img == nil {
+		return 1, 1, 1, 1
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	x := wrap(int(floor(u*float32(w))), w)
+	y := wrap(int(floor(v*float32(h))), h)
+	i := img.PixOffset(img.Bounds().Min.X+x, img.Bounds().Min.Y+y)
+	p := img.Pix[i : i+4 : i+4]
+	return float32(p[2]) / 255, float32(p[1]) / 255, float32(p[0]) / 255, float32(p[3]) / 255
+}
+
+func wrap(i, n int) int {
+	i %= n
+	if i < 0 {
+		i += n
+	}
+	return i
+}
+
+func toByte(x float32) uint8 {
+	return uint8(clamp01(x)*255 + 0.5)
+}
+
+func clamp01(x float32) float32 {
+	if x < 0 {
+		return 0
+	}
+	if x > 1 {
+		return 1
+	}
+	return x
+}
+
+// screenBound clamps a pixel coordinate to [0..size-1]. Vertices close to the
+// near plane can be projected very far outside the screen, this is checked
+// before converting to int.
+func screenBound(x float32, size int) int {
+	if x < 0 {
+		return 0
+	}
+	if x > float32(size-1) {
+		return size - 1
+	}
+	return int(x)
+}
+
+func floor(x float32) float32 { return float32(math.Floor(float64(x))) }
+func ceil(x float32) float32  { return float32(math.Ceil(float64(x))) }
+
+func min3(a, b, c float32) float32 {
+	return float32(math.Min(float64(a), math.Min(float64(b), float64(c))))
+}
+
+func max3(a, b, c float32) float32 {
+	return float32(math.Max(float64(a), math.Max(float64(b), float64(c))))
+}
@@ -0,0 +1,121 @@
+package soft
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/render"
+)
+
+// a triangle that covers the lower left half of the screen, in clip space
+var screenTriangle = []render.PositionVertex{
+	{Pos: d3dmath.Vec3{-1, -1, 0.5}},
+	{Pos: d3dmath.Vec3{-1, 1, 0.5}},
+	{Pos: d3dmath.Vec3{1, -1, 0.5}},
+}
+
+func mustSetMesh(t *testing.T, r *Renderer, m render.Mesh, vertices interface{}) {
+	t.Helper()
+	if err := r.SetMesh(m, vertices); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func identity() d3dmath.Mat4 {
+	return d3dmath.Scale(1, 1, 1)
+}
+
+func TestUniformColorTriangleIsDrawn(t *testing.T) {
+	r := New(4, 4)
+	r.Clear(color.RGBA{0, 0, 0, 255})
+	mustSetMesh(t, r, render.LaserMesh, screenTriangle)
+	r.Draw(render.DrawCall{
+		Shader:    render.UniformColor,
+		Mesh:      render.LaserMesh,
+		MVP:       identity(),
+		Color:     [4]float32{0, 1, 0, 1},
+		Triangles: 1,
+	})
+	if c := r.Image.RGBAAt(0, 3); c != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("lower left pixel should be green but is %v", c)
+	}
+	if c := r.Image.RGBAAt(3, 0); c != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("upper right pixel should be black but is %v", c)
+	}
+}
+
+func TestClockwiseTrianglesCanBeCulled(t *testing.T) {
+	r := New(4, 4)
+	r.Clear(color.RGBA{0, 0, 0, 255})
+	mustSetMesh(t, r, render.LaserMesh, screenTriangle) // clockwise on the screen
+	r.Draw(render.DrawCall{
+		Shader:        render.UniformColor,
+		Mesh:          render.LaserMesh,
+		MVP:           identity(),
+		Color:         [4]float32{1, 1, 1, 1},
+		Triangles:     1,
+		CullClockwise: true,
+	})
+	if c := r.Image.RGBAAt(0, 3); c != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("triangle should have been culled but pixel is %v", c)
+	}
+}
+
+func TestTexturesHaveRedAndBlueSwapped(t *testing.T) {
+	r := New(4, 4)
+	tex := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	tex.Pix = []uint8{10, 20, 30, 255}
+	r.SetTexture(render.Texture("tex"), tex)
+	mustSetMesh(t, r, render.SkyMesh, []render.TexturedVertex{
+		{Pos: d3dmath.Vec3{-1, -1, 0.5}},
+		{Pos: d3dmath.Vec3{-1, 1, 0.5}},
+		{Pos: d3dmath.Vec3{1, -1, 0.5}},
+	})
+	r.Draw(render.DrawCall{
+		Shader:    render.Textured,
+		Mesh:      render.SkyMesh,
+		Texture:   render.Texture("tex"),
+		MVP:       identity(),
+		Triangles: 1,
+	})
+	if c := r.Image.RGBAAt(0, 3); c != (color.RGBA{30, 20, 10, 255}) {
+		t.Errorf("want texel with red and blue swapped but have %v", c)
+	}
+}
+
+func TestCloserTriangleWins(t *testing.T) {
+	r := New(4, 4)
+	r.Clear(color.RGBA{0, 0, 0, 255})
+	// the same triangle at two distances, w is the distance to the camera
+	mustSetMesh(t, r, render.LaserMesh, screenTriangle)
+	draw := func(w float32, c [4]float32) {
+		mvp := identity()
+		mvp[15] = w
+		mvp[0], mvp[5] = w, w // keep the same size on screen
+		r.Draw(render.DrawCall{
+			Shader:    render.UniformColor,
+			Mesh:      render.LaserMesh,
+			MVP:       mvp,
+			Color:     c,
+			Triangles: 1,
+			DepthTest: true,
+		})
+	}
+	draw(1, [4]float32{1, 0, 0, 1})
+	draw(2, [4]float32{0, 0, 1, 1})
+	if c := r.Image.RGBAAt(0, 3); c != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("the closer red triangle should be visible but pixel is %v", c)
+	}
+}
+
+func TestSetMeshRejectsUnknownVertices(t *testing.T) {
+	r := New(4, 4)
+	if err := r.SetMesh(render.LaserMesh, []float32{-1, -1, 0.5}); err == nil {
+		t.Error("float32 vertices should be an error")
+	}
+	if _, ok := r.meshes[render.LaserMesh]; ok {
+		t.Error("the mesh should not be set after an error")
+	}
+}
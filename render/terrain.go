@@ -0,0 +1,401 @@
+package render
+
+import (
+	"math"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+	"github.com/gonutz/ld40/geometry"
+)
+
+// ChunkCells is the number of grid cells along each side of a terrain chunk.
+// Chunks at the far edges of the height field may be smaller.
+const ChunkCells = 32
+
+// LODLevels is the number of levels of detail per chunk. Level l uses only
+// every 2^l-th height sample.
+const LODLevels = 4
+
+// LODDistance is the distance in world units up to which chunks are drawn with
+// full detail. Each further level of detail doubles this distance.
+var LODDistance float32 = 8
+
+// TriangleRange selects consecutive triangles of a mesh.
+type TriangleRange struct {
+	First, Count int
+}
+
+// TerrainChunk is a rectangular part of the terrain that is culled and has its
+// level of detail selected as a whole.
+type TerrainChunk struct {
+	X, Z           int // grid position of the first cell
+	CellsX, CellsZ int
+	Bounds         geometry.AABB // in world coordinates
+	// MaxLOD is the coarsest level of detail that fits the chunk's size.
+	MaxLOD int
+	// parts has, for every level of detail, the interior, the four sides
+	// and then the four sides stitched to a coarser neighbor.
+	parts [LODLevels][9]TriangleRange
+}
+
+// chunk sides, in the order they are stored in TerrainChunk.parts
+const (
+	south = iota // low z
+	east         // high x
+	north        // high z
+	west         // low x
+)
+
+// Terrain is the ground mesh split into chunks. Mesh has the vertices of
+// HeightFieldMesh and indices for all chunks at all levels of detail. Draw it
+// with the Ground's ModelTransform.
+type Terrain struct {
+	Ground           game.HeightField
+	ChunksX, ChunksZ int
+	Chunks           []TerrainChunk // row by row, starting at low x and low z
+	Mesh             IndexedMesh
+	indices          []uint32 // only used while building the Mesh
+}
+
+// NewTerrain splits the height field into chunks and creates the meshes for
+// all their levels of detail.
+func NewTerrain(ground game.HeightField) *Terrain {
+	t := &Terrain{Ground: ground}
+	xs := chunkSizes(ground.CellsX())
+	zs := chunkSizes(ground.CellsZ())
+	t.ChunksX, t.ChunksZ = len(xs), len(zs)
+	z := 0
+	for _, cz := range zs {
+		x := 0
+		for _, cx := range xs {
+			c := TerrainChunk{X: x, Z: z, CellsX: cx, CellsZ: cz}
+			c.Bounds = t.chunkBounds(c)
+			for c.MaxLOD+1 < LODLevels && canHaveLOD(cx, cz, c.MaxLOD+1) {
+				c.MaxLOD++
+			}
+			for lod := 0; lod <= c.MaxLOD; lod++ {
+				c.parts[lod][0] = t.appendTriangles(chunkInterior(c, lod))
+				for side := 0; side < 4; side++ {
+					c.parts[lod][1+side] = t.appendTriangles(chunkSide(c, lod, side, false))
+				}
+				for side := 0; side < 4; side++ {
+					c.parts[lod][5+side] = t.appendTriangles(chunkSide(c, lod, side, true))
+				}
+			}
+			t.Chunks = append(t.Chunks, c)
+			x += cx
+		}
+		z += cz
+	}
+	t.Mesh = newIndexedMesh(heightFieldGridVertices(ground), t.indices)
+	t.indices = nil
+	return t
+}
+
+// chunkSizes splits the cells along one axis into chunks. A remainder of a
+// single cell is added to the last chunk, chunks must be at least 2 cells wide
+// to have borders that can be stitched.
+func chunkSizes(cells int) []int {
+	var sizes []int
+	for cells >= ChunkCells {
+		sizes = append(sizes, ChunkCells)
+		cells -= ChunkCells
+	}
+	if cells == 1 && len(sizes) > 0 {
+		sizes[len(sizes)-1]++
+	} else if cells > 0 {
+		sizes = append(sizes, cells)
+	}
+	return sizes
+}
+
+// canHaveLOD tells whether a chunk's cells can be divided into steps of 2^lod
+// with a border ring and an interior.
+func canHaveLOD(cellsX, cellsZ, lod int) bool {
+	s := 1 << uint(lod)
+	return cellsX%s == 0 && cellsZ%s == 0 && cellsX >= 2*s && cellsZ >= 2*s
+}
+
+func (t *Terrain) chunkBounds(c TerrainChunk) geometry.AABB {
+	minY, maxY := float32(math.Inf(1)), float32(math.Inf(-1))
+	for z := c.Z; z <= c.Z+c.CellsZ; z++ {
+		for x := c.X; x <= c.X+c.CellsX; x++ {
+			y := t.height(x, z)
+			if y < minY {
+				minY = y
+			}
+			if y > maxY {
+				maxY = y
+			}
+		}
+	}
+	dx, _, dz := t.Ground.Offset()
+	s := t.Ground.Scale
+	return geometry.AABB{
+		Min: d3dmath.Vec3{
+			(float32(c.X) + dx) * s[0],
+			minY * s[1],
+			(float32(c.Z) + dz) * s[2],
+		},
+		Max: d3dmath.Vec3{
+			(float32(c.X+c.CellsX) + dx) * s[0],
+			maxY * s[1],
+			(float32(c.Z+c.CellsZ) + dz) * s[2],
+		},
+	}
+}
+
+// height returns the height sample at grid point x,z.
+func (t *Terrain) height(x, z int) float32 {
+	return t.Ground.Heights[t.Ground.CellsZ()-z][x]
+}
+
+type gridPoint struct{ x, z int }
+
+type gridTriangle [3]gridPoint
+
+// appendTriangles adds the triangles to the indices and returns where they
+// are.
+func (t *Terrain) appendTriangles(tris []gridTriangle) TriangleRange {
+	r := TriangleRange{First: len(t.indices) / 3, Count: len(tris)}
+	w := t.Ground.CellsX() + 1
+	for _, tri := range tris {
+		for _, p := range tri {
+			t.indices = append(t.indices, uint32(p.z*w+p.x))
+		}
+	}
+	return r
+}
+
+// chunkInterior triangulates the chunk without its border ring of one step
+// width. Chunks that are too small for a border are triangulated completely.
+func chunkInterior(c TerrainChunk, lod int) []gridTriangle {
+	s := 1 << uint(lod)
+	border := s
+	if c.CellsX < 2 || c.CellsZ < 2 {
+		border = 0
+	}
+	var tris []gridTriangle
+	for z := c.Z + border; z < c.Z+c.CellsZ-border; z += s {
+		for x := c.X + border; x < c.X+c.CellsX-border; x += s {
+			tris = append(tris,
+				gridTriangle{{x, z}, {x + s, z}, {x, z + s}},
+				gridTriangle{{x, z + s}, {x + s, z}, {x + s, z + s}},
+			)
+		}
+	}
+	return tris
+}
+
+// chunkSide triangulates one side of the chunk's border ring, a trapezoid
+// between the chunk's edge and the interior. If stitched is true, the edge
+// only uses every other vertex so it matches a neighbor with the next coarser
+// level of detail without cracks.
+func chunkSide(c TerrainChunk, lod, side int, stitched bool) []gridTriangle {
+	if c.CellsX < 2 || c.CellsZ < 2 {
+		return nil
+	}
+	s := 1 << uint(lod)
+	// the side goes from corner along a direction, the inside of the chunk
+	// is to the left of it
+	var corner, along, inward gridPoint
+	var length int
+	switch side {
+	case south:
+		corner, along, inward = gridPoint{c.X, c.Z}, gridPoint{1, 0}, gridPoint{0, 1}
+		length = c.CellsX
+	case east:
+		corner, along, inward = gridPoint{c.X + c.CellsX, c.Z}, gridPoint{0, 1}, gridPoint{-1, 0}
+		length = c.CellsZ
+	case north:
+		corner, along, inward = gridPoint{c.X + c.CellsX, c.Z + c.CellsZ}, gridPoint{-1, 0}, gridPoint{0, -1}
+		length = c.CellsX
+	case west:
+		corner, along, inward = gridPoint{c.X, c.Z + c.CellsZ}, gridPoint{0, -1}, gridPoint{1, 0}
+		length = c.CellsZ
+	}
+	at := func(t, depth int) gridPoint {
+		return gridPoint{
+			corner.x + t*along.x + depth*inward.x,
+			corner.z + t*along.z + depth*inward.z,
+		}
+	}
+
+	outerStep := s
+	if stitched {
+		outerStep = 2 * s
+		if length%outerStep != 0 {
+			// no neighbor can have a coarser level of detail on this side
+			return nil
+		}
+	}
+	// walk along the outer edge (from 0 to length) and the inner edge (from s
+	// to length-s) at the same time, always advancing the one that is behind
+	var tris []gridTriangle
+	outer, inner := 0, s
+	for outer < length || inner < length-s {
+		if inner >= length-s || (outer < length && outer+outerStep <= inner+s) {
+			tris = append(tris, ccw(at(outer, 0), at(outer+outerStep, 0), at(inner, s)))
+			outer += outerStep
+		} else {
+			tris = append(tris, ccw(at(outer, 0), at(inner+s, s), at(inner, s)))
+			inner += s
+		}
+	}
+	return tris
+}
+
+// ccw orders the triangle's corners counterclockwise in the x-z plane, like
+// the triangles of the grid cells.
+func ccw(a, b, c gridPoint) gridTriangle {
+	cross := (b.x-a.x)*(c.z-a.z) - (b.z-a.z)*(c.x-a.x)
+	if cross < 0 {
+		return gridTriangle{a, c, b}
+	}
+	return gridTriangle{a, b, c}
+}
+
+// SelectLODs returns the level of detail for each chunk, depending on its
+// distance from the eye. Neighboring chunks differ by at most one level so that
+// their borders can be stitched.
+func (t *Terrain) SelectLODs(eye d3dmath.Vec3) []int {
+	lods := make([]int, len(t.Chunks))
+	for i, c := range t.Chunks {
+		d := c.Bounds.Distance(eye)
+		lod := 0
+		for lod < c.MaxLOD && d > LODDistance*float32(int(1)<<uint(lod)) {
+			lod++
+		}
+		lods[i] = lod
+	}
+	for changed := true; changed; {
+		changed = false
+		for i := range t.Chunks {
+			for _, n := range t.neighbors(i) {
+				if n != -1 && lods[n] > lods[i]+1 {
+					lods[n] = lods[i] + 1
+					changed = true
+				}
+			}
+		}
+	}
+	return lods
+}
+
+// neighbors returns the indices of the chunks on the south, east, north and
+// west side of chunk i, -1 if there is none.
+func (t *Terrain) neighbors(i int) [4]int {
+	x, z := i%t.ChunksX, i/t.ChunksX
+	n := [4]int{-1, -1, -1, -1}
+	if z > 0 {
+		n[south] = i - t.ChunksX
+	}
+	if x < t.ChunksX-1 {
+		n[east] = i + 1
+	}
+	if z < t.ChunksZ-1 {
+		n[north] = i + t.ChunksX
+	}
+	if x > 0 {
+		n[west] = i - 1
+	}
+	return n
+}
+
+// Visible tells for each chunk whether it intersects the view frustum of the
+// given row-major view-projection matrix.
+func (t *Terrain) Visible(viewProjection d3dmath.Mat4) []bool {
+	planes := frustumPlanes(viewProjection)
+	visible := make([]bool, len(t.Chunks))
+	for i, c := range t.Chunks {
+		visible[i] = boxInFrustum(c.Bounds, planes)
+	}
+	return visible
+}
+
+// DrawRanges returns the triangles of the Mesh to draw for the visible chunks
+// at their selected levels of detail.
+func (t *Terrain) DrawRanges(viewProjection d3dmath.Mat4, eye d3dmath.Vec3) []TriangleRange {
+	lods := t.SelectLODs(eye)
+	visible := t.Visible(viewProjection)
+	var ranges []TriangleRange
+	for i := range t.Chunks {
+		if visible[i] {
+			ranges = t.appendChunk(ranges, i, lods)
+		}
+	}
+	return ranges
+}
+
+// appendChunk adds the parts of chunk i at its level of detail, with the sides
+// stitched where the neighbor is coarser.
+func (t *Terrain) appendChunk(ranges []TriangleRange, i int, lods []int) []TriangleRange {
+	lod := lods[i]
+	parts := t.Chunks[i].parts[lod]
+	ranges = appendRange(ranges, parts[0])
+	for side, n := range t.neighbors(i) {
+		if n != -1 && lods[n] > lod {
+			ranges = appendRange(ranges, parts[5+side])
+		} else {
+			ranges = appendRange(ranges, parts[1+side])
+		}
+	}
+	return ranges
+}
+
+// appendRange merges r into the last range if they are adjacent, to save draw
+// calls.
+func appendRange(ranges []TriangleRange, r TriangleRange) []TriangleRange {
+	if r.Count == 0 {
+		return ranges
+	}
+	if n := len(ranges); n > 0 && ranges[n-1].First+ranges[n-1].Count == r.First {
+		ranges[n-1].Count += r.Count
+		return ranges
+	}
+	return append(ranges, r)
+}
+
+// frustumPlanes extracts the left, right, bottom, top, near and far planes
+// from a row-major projection matrix that transforms row vectors. A point p is
+// inside plane n if n[0]*p[0] + n[1]*p[1] + n[2]*p[2] + n[3] >= 0.
+func frustumPlanes(m d3dmath.Mat4) [6][4]float32 {
+	col := func(c int) [4]float32 {
+		return [4]float32{m[c], m[4+c], m[8+c], m[12+c]}
+	}
+	add := func(a, b [4]float32) [4]float32 {
+		return [4]float32{a[0] + b[0], a[1] + b[1], a[2] + b[2], a[3] + b[3]}
+	}
+	sub := func(a, b [4]float32) [4]float32 {
+		return [4]float32{a[0] - b[0], a[1] - b[1], a[2] - b[2], a[3] - b[3]}
+	}
+	x, y, z, w := col(0), col(1), col(2), col(3)
+	return [6][4]float32{
+		add(w, x),
+		sub(w, x),
+		add(w, y),
+		sub(w, y),
+		z,
+		sub(w, z),
+	}
+}
+
+// boxInFrustum is conservative, boxes near the frustum's corners may be
+// reported as visible although they are not.
+func boxInFrustum(b geometry.AABB, planes [6][4]float32) bool {
+	for _, p := range planes {
+		// the box corner that is furthest inside the plane
+		var v d3dmath.Vec3
+		for i := 0; i < 3; i++ {
+			if p[i] >= 0 {
+				v[i] = b.Max[i]
+			} else {
+				v[i] = b.Min[i]
+			}
+		}
+		if p[0]*v[0]+p[1]*v[1]+p[2]*v[2]+p[3] < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+package render
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+)
+
+// hillyField has cellsX*cellsZ cells of 1x1 world units with some waves.
+func hillyField(cellsX, cellsZ int) game.HeightField {
+	heights := make([][]float32, cellsZ+1)
+	for i := range heights {
+		heights[i] = make([]float32, cellsX+1)
+		for j := range heights[i] {
+			heights[i][j] = float32(math.Sin(float64(i)/5) * math.Cos(float64(j)/7))
+		}
+	}
+	return game.HeightField{Heights: heights, Scale: d3dmath.Vec3{1, 1, 1}}
+}
+
+// triangles returns the x,z corners of the given parts of the terrain mesh.
+func triangles(t *Terrain, ranges []TriangleRange) [][3]gridPoint {
+	m := t.Mesh
+	var tris [][3]gridPoint
+	for _, r := range ranges {
+		for i := r.First; i < r.First+r.Count; i++ {
+			var tri [3]gridPoint
+			for j := range tri {
+				v := m.Vertices[m.Index(i*3+j)]
+				tri[j] = gridPoint{int(v.Pos[0]), int(v.Pos[2])}
+			}
+			tris = append(tris, tri)
+		}
+	}
+	return tris
+}
+
+func TestChunkSizes(t *testing.T) {
+	for _, test := range []struct {
+		cells int
+		want  []int
+	}{
+		{1, []int{1}},
+		{31, []int{31}},
+		{64, []int{32, 32}},
+		{65, []int{32, 33}},
+		{70, []int{32, 32, 6}},
+	} {
+		have := chunkSizes(test.cells)
+		if len(have) != len(test.want) {
+			t.Errorf("%d cells: want %v but have %v", test.cells, test.want, have)
+			continue
+		}
+		for i := range have {
+			if have[i] != test.want[i] {
+				t.Errorf("%d cells: want %v but have %v", test.cells, test.want, have)
+			}
+		}
+	}
+}
+
+func TestChunkPartsCoverTheChunk(t *testing.T) {
+	terrain := NewTerrain(hillyField(70, 33))
+	if terrain.ChunksX != 3 || terrain.ChunksZ != 1 {
+		t.Fatalf("want 3x1 chunks but have %dx%d", terrain.ChunksX, terrain.ChunksZ)
+	}
+	for i, c := range terrain.Chunks {
+		for lod := 0; lod <= c.MaxLOD; lod++ {
+			for stitched := 0; stitched < 2; stitched++ {
+				parts := []TriangleRange{c.parts[lod][0]}
+				for side := 0; side < 4; side++ {
+					part := c.parts[lod][1+4*stitched+side]
+					if part.Count == 0 {
+						// this side cannot be stitched
+						part = c.parts[lod][1+side]
+					}
+					parts = append(parts, part)
+				}
+				area := 0
+				for _, tri := range triangles(terrain, parts) {
+					a, b, c2 := tri[0], tri[1], tri[2]
+					cross := (b.x-a.x)*(c2.z-a.z) - (b.z-a.z)*(c2.x-a.x)
+					if cross <= 0 {
+						t.Fatalf("chunk %d lod %d: triangle %v is not counterclockwise", i, lod, tri)
+					}
+					for _, p := range tri {
+						if p.x < c.X || p.x > c.X+c.CellsX || p.z < c.Z || p.z > c.Z+c.CellsZ {
+							t.Fatalf("chunk %d lod %d: %v is outside the chunk", i, lod, p)
+						}
+					}
+					area += cross
+				}
+				// cross is twice the area of a triangle
+				if want := 2 * c.CellsX * c.CellsZ; area != want {
+					t.Errorf("chunk %d lod %d stitched %v: want area %d but have %d",
+						i, lod, stitched == 1, want/2, area/2)
+				}
+			}
+		}
+	}
+}
+
+func TestNeighborsWithDifferentLODsShareEdgeVertices(t *testing.T) {
+	terrain := NewTerrain(hillyField(128, 128))
+	// look from one corner so every level of detail is used
+	lods := terrain.SelectLODs(d3dmath.Vec3{-64, 0, -64})
+	have := map[int]bool{}
+	for _, lod := range lods {
+		have[lod] = true
+	}
+	if len(have) < 3 {
+		t.Fatalf("want different levels of detail but have %v", lods)
+	}
+
+	// edgeVertices are the vertices of chunk i on the line x = x0 or z = z0
+	edgeVertices := func(i int, onEdge func(gridPoint) bool) map[gridPoint]bool {
+		edge := map[gridPoint]bool{}
+		ranges := terrain.appendChunk(nil, i, lods)
+		for _, tri := range triangles(terrain, ranges) {
+			for _, p := range tri {
+				if onEdge(p) {
+					edge[p] = true
+				}
+			}
+		}
+		return edge
+	}
+	sameSet := func(a, b map[gridPoint]bool) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for p := range a {
+			if !b[p] {
+				return false
+			}
+		}
+		return true
+	}
+
+	for i, c := range terrain.Chunks {
+		n := terrain.neighbors(i)
+		if e := n[east]; e != -1 {
+			x := c.X + c.CellsX
+			onEdge := func(p gridPoint) bool { return p.x == x }
+			if !sameSet(edgeVertices(i, onEdge), edgeVertices(e, onEdge)) {
+				t.Errorf("crack between chunk %d (lod %d) and %d (lod %d)", i, lods[i], e, lods[e])
+			}
+		}
+		if no := n[north]; no != -1 {
+			z := c.Z + c.CellsZ
+			onEdge := func(p gridPoint) bool { return p.z == z }
+			if !sameSet(edgeVertices(i, onEdge), edgeVertices(no, onEdge)) {
+				t.Errorf("crack between chunk %d (lod %d) and %d (lod %d)", i, lods[i], no, lods[no])
+			}
+		}
+	}
+}
+
+func TestLODsIncreaseWithDistanceAndDifferByOne(t *testing.T) {
+	terrain := NewTerrain(hillyField(256, 256))
+	eye := d3dmath.Vec3{0, 0, 0}
+	lods := terrain.SelectLODs(eye)
+	for i, c := range terrain.Chunks {
+		d := c.Bounds.Distance(eye)
+		if d == 0 && lods[i] != 0 {
+			t.Errorf("chunk %d contains the eye but has lod %d", i, lods[i])
+		}
+		if d > 8*LODDistance && lods[i] != LODLevels-1 {
+			t.Errorf("chunk %d is far away but has lod %d", i, lods[i])
+		}
+		for _, n := range terrain.neighbors(i) {
+			if n != -1 && (lods[n]-lods[i] > 1 || lods[i]-lods[n] > 1) {
+				t.Errorf("neighbors %d and %d have lods %d and %d", i, n, lods[i], lods[n])
+			}
+		}
+	}
+}
+
+func TestChunksBehindTheCameraAreCulled(t *testing.T) {
+	terrain := NewTerrain(hillyField(128, 128))
+	s := Scene{
+		Eye:     d3dmath.Vec3{0, 1, 0},
+		ViewDir: d3dmath.Vec3{1, 0, 0},
+		Aspect:  1,
+	}
+	visible := terrain.Visible(s.ViewProjection())
+	for i, c := range terrain.Chunks {
+		behind := c.Bounds.Max[0] < s.Eye[0]
+		ahead := c.Bounds.Min[0] <= 16 && c.Bounds.Max[0] > 16 &&
+			c.Bounds.Min[2] <= 0 && c.Bounds.Max[2] >= 0
+		if behind && visible[i] {
+			t.Errorf("chunk %d at %v is behind the camera but visible", i, c.Bounds)
+		}
+		if ahead && !visible[i] {
+			t.Errorf("chunk %d at %v is in front of the camera but culled", i, c.Bounds)
+		}
+	}
+}
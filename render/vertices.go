@@ -0,0 +1,71 @@
+package render
+
+import (
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/vertex"
+)
+
+// PositionVertex is the vertex layout of the UniformColor shader.
+type PositionVertex struct {
+	Pos d3dmath.Vec3 `vertex:"position"`
+}
+
+// TexturedVertex is the vertex layout of the Textured shader.
+type TexturedVertex struct {
+	Pos d3dmath.Vec3 `vertex:"position"`
+	UV  [2]float32   `vertex:"texcoord"`
+}
+
+// LitVertex is the vertex layout of the TexturedLit shader.
+type LitVertex struct {
+	Pos    d3dmath.Vec3 `vertex:"position"`
+	Normal d3dmath.Vec3 `vertex:"normal"`
+	UV     [2]float32   `vertex:"texcoord"`
+}
+
+var shaderFormats = [...]vertex.Format{
+	Textured:     vertex.MustOf(TexturedVertex{}),
+	TexturedLit:  vertex.MustOf(LitVertex{}),
+	UniformColor: vertex.MustOf(PositionVertex{}),
+}
+
+// Format is the layout of the vertices in the meshes that the shader draws.
+// Meshes are slices of the vertex struct, which the Format can Pack.
+func (s Shader) Format() vertex.Format {
+	return shaderFormats[s]
+}
+
+// shaderInputs are the inputs of the vertex shaders, as declared in their .vs
+// files. TestShaderInputsMatchSources keeps them in sync.
+var shaderInputs = [...][]vertex.Input{
+	Textured: {
+		{Usage: vertex.Position, Components: 4},
+		{Usage: vertex.TexCoord, Components: 2},
+	},
+	TexturedLit: {
+		{Usage: vertex.Position, Components: 4},
+		{Usage: vertex.Normal, Components: 3},
+		{Usage: vertex.TexCoord, Components: 2},
+	},
+	UniformColor: {
+		{Usage: vertex.Position, Components: 4},
+	},
+}
+
+// Inputs are what the shader's vertex shader reads. Check them against the
+// Format with vertex.Format.Check.
+func (s Shader) Inputs() []vertex.Input {
+	return shaderInputs[s]
+}
+
+// Format is the vertex layout of the mesh, that of the shader it is drawn
+// with.
+func (m Mesh) Format() vertex.Format {
+	switch m {
+	case GroundMesh:
+		return TexturedLit.Format()
+	case LaserMesh:
+		return UniformColor.Format()
+	}
+	return Textured.Format()
+}
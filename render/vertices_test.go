@@ -0,0 +1,131 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+	"github.com/gonutz/ld40/level"
+	"github.com/gonutz/ld40/vertex"
+)
+
+func TestShaderFormatsMatchTheirInputs(t *testing.T) {
+	for _, s := range []Shader{Textured, TexturedLit, UniformColor} {
+		if err := s.Format().Check(s.Inputs()); err != nil {
+			t.Errorf("shader %d: %v", s, err)
+		}
+	}
+}
+
+func TestShaderInputsMatchSources(t *testing.T) {
+	for s, file := range map[Shader]string{
+		Textured:     "../texture.vs",
+		TexturedLit:  "../texture_lit.vs",
+		UniformColor: "../uniform_color.vs",
+	} {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs, err := parseShaderInputs(string(source))
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if !reflect.DeepEqual(inputs, s.Inputs()) {
+			t.Errorf("%s declares inputs %v but shader %d has %v", file, inputs, s, s.Inputs())
+		}
+	}
+}
+
+// inputField is a line of a vertex shader's input struct, e.g.
+//
+//	float3 normal : NORMAL0;
+var inputField = regexp.MustCompile(`^float([1-4])\s+\w+\s*:\s*([A-Z]+)([0-9]*)\s*;`)
+
+// parseShaderInputs reads the fields of the struct named input in HLSL source.
+func parseShaderInputs(source string) ([]vertex.Input, error) {
+	start := strings.Index(source, "struct input {")
+	if start == -1 {
+		return nil, errors.New("no input struct")
+	}
+	end := strings.Index(source[start:], "};")
+	if end == -1 {
+		return nil, errors.New("input struct does not end")
+	}
+	lines := strings.Split(source[start:start+end], "\n")[1:]
+	var inputs []vertex.Input
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		m := inputField.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("cannot parse input %q", line)
+		}
+		in := vertex.Input{Usage: -1}
+		in.Components, _ = strconv.Atoi(m[1])
+		for _, u := range []vertex.Usage{vertex.Position, vertex.Normal, vertex.TexCoord, vertex.Color} {
+			if strings.EqualFold(m[2], u.String()) {
+				in.Usage = u
+			}
+		}
+		if in.Usage == -1 {
+			return nil, fmt.Errorf("unknown semantic %s", m[2])
+		}
+		if m[3] != "" {
+			in.UsageIndex, _ = strconv.Atoi(m[3])
+		}
+		inputs = append(inputs, in)
+	}
+	return inputs, nil
+}
+
+func TestMeshDataMatchesFormats(t *testing.T) {
+	terrain := NewTerrain(hillyField(5, 3))
+	for _, m := range []struct {
+		mesh     Mesh
+		vertices interface{}
+	}{
+		{SkyMesh, SkyVertices},
+		{PropMesh, PropVertices([]level.Prop{{Scale: 2}, {YawDeg: 90}})},
+		{GroundMesh, terrain.Mesh.Vertices},
+		{LaserMesh, SquareVertices},
+	} {
+		if _, err := m.mesh.Format().Pack(m.vertices); err != nil {
+			t.Errorf("mesh %d: %v", m.mesh, err)
+		}
+	}
+}
+
+// drawCalls records the calls of a Backend.
+type drawCalls []DrawCall
+
+func (d *drawCalls) Draw(c DrawCall) {
+	*d = append(*d, c)
+}
+
+func TestDrawCallsUseTheMeshFormat(t *testing.T) {
+	ground := hillyField(5, 3)
+	w := game.NewWorld(ground)
+	w.LaserBeams = []game.LaserBeam{{End: d3dmath.Vec3{1, 1, 1}, Life: 1}}
+	l := &level.Level{Props: []level.Prop{{}}}
+	var calls drawCalls
+	Draw(&calls, NewScene(w, l, NewTerrain(ground), 1, 1))
+	if len(calls) == 0 {
+		t.Fatal("nothing was drawn")
+	}
+	for _, c := range calls {
+		if c.Shader.Format().Stride != c.Mesh.Format().Stride {
+			t.Errorf("mesh %d is drawn with shader %d which has a different layout", c.Mesh, c.Shader)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+// Package replay records the control events of a play session to a compact
+// binary file and plays them back, so that a session can be reproduced exactly.
+//
+// A replay file starts with the 8 byte header "LD40RPL" followed by a format
+// version byte and the Settings that the session started with. Then follow
+// the events, each one encoded as:
+//
+//	uvarint  frame index delta to the previous event
+//	byte     game.EventKind or settingsChange
+//	byte     game.Key                 for KeyDown and KeyUp
+//	varint   x, varint y              for MouseMove and MouseDelta
+//	byte     game.Axis                for AxisMove, followed by
+//	uint32   value as little endian float32 bits
+//	Settings                          for settingsChange
+//
+// Settings are written as their float32 fields, as little endian bits in the
+// order of the struct, followed by a byte for InvertY.
+//
+// Older files are read as well, version 1 has no AxisMove events, version 2 no
+// MouseDelta events and up to version 3 there are no settings.
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/gonutz/ld40/game"
+)
+
+const (
+	magic   = "LD40RPL"
+	version = 4
+)
+
+// settingsChange is the event kind of a SettingsChange in the file, it is not
+// a game.EventKind.
+const settingsChange = 0xFF
+
+// Settings are the player's settings that change how the world is simulated.
+// They are recorded so that a replay does not depend on the settings of the
+// player who watches it.
+type Settings struct {
+	MoveSpeed       float32
+	RunSpeed        float32
+	SneakSpeed      float32
+	JumpSpeed       float32
+	Gravity         float32
+	PlayerHeight    float32
+	AirAcceleration float32
+	CoyoteTime      float32
+	JumpBuffer      float32
+	MaxSlope        float32
+	StepHeight      float32
+	LookSensitivity float32
+	InvertY         bool
+}
+
+// SettingsOf returns the current settings of the world.
+func SettingsOf(w *game.World) Settings {
+	return Settings{
+		MoveSpeed:       w.MoveSpeed,
+		RunSpeed:        w.RunSpeed,
+		SneakSpeed:      w.SneakSpeed,
+		JumpSpeed:       w.JumpSpeed,
+		Gravity:         w.Gravity,
+		PlayerHeight:    w.PlayerHeight,
+		AirAcceleration: w.AirAcceleration,
+		CoyoteTime:      w.CoyoteTime,
+		JumpBuffer:      w.JumpBuffer,
+		MaxSlope:        w.Controller.MaxSlope,
+		StepHeight:      w.Controller.StepHeight,
+		LookSensitivity: w.LookSensitivity,
+		InvertY:         w.InvertY,
+	}
+}
+
+// Apply sets the settings in the world.
+func (s Settings) Apply(w *game.World) {
+	w.MoveSpeed = s.MoveSpeed
+	w.RunSpeed = s.RunSpeed
+	w.SneakSpeed = s.SneakSpeed
+	w.JumpSpeed = s.JumpSpeed
+	w.Gravity = s.Gravity
+	w.PlayerHeight = s.PlayerHeight
+	w.AirAcceleration = s.AirAcceleration
+	w.CoyoteTime = s.CoyoteTime
+	w.JumpBuffer = s.JumpBuffer
+	w.Controller.MaxSlope = s.MaxSlope
+	w.Controller.StepHeight = s.StepHeight
+	w.LookSensitivity = s.LookSensitivity
+	w.InvertY = s.InvertY
+}
+
+func (s *Settings) floats() []*float32 {
+	return []*float32{
+		&s.MoveSpeed,
+		&s.RunSpeed,
+		&s.SneakSpeed,
+		&s.JumpSpeed,
+		&s.Gravity,
+		&s.PlayerHeight,
+		&s.AirAcceleration,
+		&s.CoyoteTime,
+		&s.JumpBuffer,
+		&s.MaxSlope,
+		&s.StepHeight,
+		&s.LookSensitivity,
+	}
+}
+
+func (s Settings) encode() []byte {
+	var b []byte
+	for _, f := range s.floats() {
+		b = append(b, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(b[len(b)-4:], math.Float32bits(*f))
+	}
+	if s.InvertY {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+func readSettings(r io.Reader) (Settings, error) {
+	var s Settings
+	floats := s.floats()
+	b := make([]byte, 4*len(floats)+1)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return Settings{}, unexpected(err)
+	}
+	for i, f := range floats {
+		*f = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	s.InvertY = b[len(b)-1] != 0
+	return s, nil
+}
+
+// SettingsChange is a change of the settings, e.g. in the menu, that happened
+// right before the given simulation frame was stepped.
+type SettingsChange struct {
+	Frame uint32
+	Settings
+}
+
+// Event is a game.Event that happened right before the given simulation frame
+// was stepped.
+type Event struct {
+	Frame uint32
+	game.Event
+}
+
+type Writer struct {
+	w         *bufio.Writer
+	lastFrame uint32
+	buf       [2*binary.MaxVarintLen64 + 2]byte
+}
+
+// NewWriter writes the file header with the settings that the session starts
+// with and returns a Writer for the events. Call Flush when done.
+func NewWriter(w io.Writer, s Settings) (*Writer, error) {
+	buf := bufio.NewWriter(w)
+	buf.WriteString(magic)
+	buf.WriteByte(version)
+	buf.Write(s.encode())
+	return &Writer{w: buf}, buf.Flush()
+}
+
+// Write appends an event. Events must be written in ascending frame order.
+func (w *Writer) Write(e Event) error {
+	if e.Frame < w.lastFrame {
+		return fmt.Errorf("replay: event for frame %d written after frame %d", e.Frame, w.lastFrame)
+	}
+	n := binary.PutUvarint(w.buf[:], uint64(e.Frame-w.lastFrame))
+	w.lastFrame = e.Frame
+	w.buf[n] = byte(e.Kind)
+	n++
+	switch e.Kind {
+	case game.KeyDown, game.KeyUp:
+		w.buf[n] = byte(e.Key)
+		n++
+	case game.MouseMove, game.MouseDelta:
+		n += binary.PutVarint(w.buf[n:], int64(e.X))
+		n += binary.PutVarint(w.buf[n:], int64(e.Y))
+	case game.AxisMove:
+		w.buf[n] = byte(e.Axis)
+		binary.LittleEndian.PutUint32(w.buf[n+1:], math.Float32bits(e.Value))
+		n += 5
+	default:
+		return fmt.Errorf("replay: unknown event kind %d", e.Kind)
+	}
+	_, err := w.w.Write(w.buf[:n])
+	return err
+}
+
+// WriteSettings appends a change of the settings. Like events, changes must be
+// written in ascending frame order.
+func (w *Writer) WriteSettings(c SettingsChange) error {
+	if c.Frame < w.lastFrame {
+		return fmt.Errorf("replay: settings for frame %d written after frame %d", c.Frame, w.lastFrame)
+	}
+	n := binary.PutUvarint(w.buf[:], uint64(c.Frame-w.lastFrame))
+	w.lastFrame = c.Frame
+	w.buf[n] = settingsChange
+	n++
+	if _, err := w.w.Write(w.buf[:n]); err != nil {
+		return err
+	}
+	_, err := w.w.Write(c.Settings.encode())
+	return err
+}
+
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Recording is the content of a replay file.
+type Recording struct {
+	// Settings are those at the start of the session. HasSettings is false
+	// for files before version 4, which did not record them.
+	Settings    Settings
+	HasSettings bool
+	Events      []Event
+	Changes     []SettingsChange
+}
+
+// ReadAll reads a complete replay file and returns its events, see Read for
+// the settings.
+func ReadAll(r io.Reader) ([]Event, error) {
+	rec, err := Read(r)
+	if err != nil {
+		return nil, err
+	}
+	return rec.Events, nil
+}
+
+// Read reads a complete replay file.
+func Read(r io.Reader) (*Recording, error) {
+	buf := bufio.NewReader(r)
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(buf, header); err != nil {
+		return nil, errors.New("replay: file too short for header")
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, errors.New("replay: not a replay file")
+	}
+	v := header[len(magic)]
+	if v < 1 || v > version {
+		return nil, fmt.Errorf("replay: unsupported version %d", v)
+	}
+
+	var rec Recording
+	if v >= 4 {
+		s, err := readSettings(buf)
+		if err != nil {
+			return nil, errors.New("replay: file too short for header")
+		}
+		rec.Settings, rec.HasSettings = s, true
+	}
+	var frame uint32
+	for {
+		delta, err := binary.ReadUvarint(buf)
+		if err == io.EOF {
+			return &rec, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		frame += uint32(delta)
+		e := Event{Frame: frame}
+		kind, err := buf.ReadByte()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		if kind == settingsChange && v >= 4 {
+			s, err := readSettings(buf)
+			if err != nil {
+				return nil, err
+			}
+			rec.Changes = append(rec.Changes, SettingsChange{Frame: frame, Settings: s})
+			continue
+		}
+		e.Kind = game.EventKind(kind)
+		switch e.Kind {
+		case game.KeyDown, game.KeyUp:
+			key, err := buf.ReadByte()
+			if err != nil {
+				return nil, unexpected(err)
+			}
+			e.Key = game.Key(key)
+		case game.MouseMove, game.MouseDelta:
+			x, err := binary.ReadVarint(buf)
+			if err != nil {
+				return nil, unexpected(err)
+			}
+			y, err := binary.ReadVarint(buf)
+			if err != nil {
+				return nil, unexpected(err)
+			}
+			e.X, e.Y = int(x), int(y)
+		case game.AxisMove:
+			var axis [5]byte
+			if _, err := io.ReadFull(buf, axis[:]); err != nil {
+				return nil, unexpected(err)
+			}
+			e.Axis = game.Axis(axis[0])
+			e.Value = math.Float32frombits(binary.LittleEndian.Uint32(axis[1:]))
+		default:
+			return nil, fmt.Errorf("replay: unknown event kind %d in frame %d", kind, frame)
+		}
+		rec.Events = append(rec.Events, e)
+	}
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Player feeds recorded events back into the game's Controls.
+type Player struct {
+	events     []Event
+	next       int
+	world      *game.World
+	changes    []SettingsChange
+	nextChange int
+}
+
+func NewPlayer(events []Event) *Player {
+	return &Player{events: events}
+}
+
+// Play applies the recording's settings to w and returns a Player that
+// applies the later changes to w as well.
+func (r *Recording) Play(w *game.World) *Player {
+	if r.HasSettings {
+		r.Settings.Apply(w)
+	}
+	return &Player{events: r.Events, world: w, changes: r.Changes}
+}
+
+// Feed hands all events recorded for the given frame to c and applies the
+// settings changes for it. Call it right before stepping the simulation, with
+// the frame index counting up from 0.
+func (p *Player) Feed(frame uint32, c *game.Controls) {
+	for p.nextChange < len(p.changes) && p.changes[p.nextChange].Frame <= frame {
+		p.changes[p.nextChange].Apply(p.world)
+		p.nextChange++
+	}
+	for p.next < len(p.events) && p.events[p.next].Frame <= frame {
+		c.Handle(p.events[p.next].Event)
+		p.next++
+	}
+}
+
+// Done reports whether all events and settings changes were played back.
+func (p *Player) Done() bool {
+	return p.next >= len(p.events) && p.nextChange >= len(p.changes)
+}
@@ -0,0 +1,210 @@
+package replay
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+)
+
+func TestEventsSurviveRoundTrip(t *testing.T) {
+	events := []Event{
+		{0, game.Event{Kind: game.KeyDown, Key: game.KeyForward}},
+		{0, game.Event{Kind: game.MouseMove, X: -3, Y: 700}},
+		{1, game.Event{Kind: game.MouseDelta, X: 5, Y: -2}},
+		{5, game.Event{Kind: game.KeyDown, Key: game.KeyJump}},
+		{5, game.Event{Kind: game.AxisMove, Axis: game.AxisTurnY, Value: -0.375}},
+		{300, game.Event{Kind: game.KeyUp, Key: game.KeyForward}},
+	}
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(events) {
+		t.Fatalf("want %d events but have %d", len(events), len(read))
+	}
+	for i := range events {
+		if read[i] != events[i] {
+			t.Errorf("event %d: want %v but have %v", i, events[i], read[i])
+		}
+	}
+}
+
+func TestTruncatedFileIsAnError(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, Settings{})
+	w.Write(Event{7, game.Event{Kind: game.MouseMove, X: 1000, Y: 1000}})
+	w.Flush()
+	data := buf.Bytes()
+	if _, err := ReadAll(bytes.NewReader(data[:len(data)-1])); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestVersion1FilesCanBeRead(t *testing.T) {
+	file := append([]byte(magic), 1, 3, byte(game.KeyDown), byte(game.KeyJump))
+	events, err := ReadAll(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Event{3, game.Event{Kind: game.KeyDown, Key: game.KeyJump}}
+	if len(events) != 1 || events[0] != want {
+		t.Errorf("want %v but have %v", want, events)
+	}
+	if _, err := ReadAll(bytes.NewReader(append([]byte(magic), version+1))); err == nil {
+		t.Error("newer versions must be an error")
+	}
+}
+
+func TestReplayReproducesSession(t *testing.T) {
+	session := map[uint32][]game.Event{
+		0:  {{Kind: game.KeyDown, Key: game.KeyForward}},
+		10: {{Kind: game.MouseMove, X: 12, Y: -4}, {Kind: game.KeyDown, Key: game.KeyJump}},
+		11: {{Kind: game.MouseMove}},
+		25: {{Kind: game.KeyDown, Key: game.KeyShoot}, {Kind: game.KeyDown, Key: game.KeyRun}},
+		40: {{Kind: game.KeyUp, Key: game.KeyForward}},
+	}
+	const frames = 60
+
+	var buf bytes.Buffer
+	live := newWorld()
+	rec, _ := NewWriter(&buf, SettingsOf(live))
+	var liveControls game.Controls
+	for frame := uint32(0); frame < frames; frame++ {
+		for _, e := range session[frame] {
+			liveControls.Handle(e)
+			rec.Write(Event{frame, e})
+		}
+		live.Step(liveControls.NextInput(), 1.0/60)
+	}
+	rec.Flush()
+
+	events, err := ReadAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := NewPlayer(events)
+	replayed := newWorld()
+	var controls game.Controls
+	for frame := uint32(0); frame < frames; frame++ {
+		player.Feed(frame, &controls)
+		replayed.Step(controls.NextInput(), 1.0/60)
+	}
+
+	if !player.Done() {
+		t.Error("not all events were played back")
+	}
+	if replayed.Pos != live.Pos || replayed.ViewDir() != live.ViewDir() ||
+		len(replayed.LaserBeams) != len(live.LaserBeams) {
+		t.Errorf("replay differs, live: %v %v, replay: %v %v",
+			live.Pos, live.ViewDir(), replayed.Pos, replayed.ViewDir())
+	}
+}
+
+func TestReplayReproducesSettings(t *testing.T) {
+	const frames = 60
+	var buf bytes.Buffer
+	live := newWorld()
+	live.MoveSpeed = 3
+	live.LookSensitivity = 0.3
+	live.Controller.StepHeight = 0.2
+	rec, err := NewWriter(&buf, SettingsOf(live))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var liveControls game.Controls
+	for frame := uint32(0); frame < frames; frame++ {
+		var events []game.Event
+		switch frame {
+		case 0:
+			events = []game.Event{{Kind: game.KeyDown, Key: game.KeyForward}}
+		case 10, 30:
+			events = []game.Event{{Kind: game.MouseDelta, X: 20, Y: 10}}
+		case 20:
+			live.LookSensitivity = 0.05
+			live.InvertY = true
+			if err := rec.WriteSettings(SettingsChange{frame, SettingsOf(live)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, e := range events {
+			liveControls.Handle(e)
+			rec.Write(Event{frame, e})
+		}
+		live.Step(liveControls.NextInput(), 1.0/60)
+	}
+	rec.Flush()
+
+	recording, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recording.Changes) != 1 || recording.Changes[0].Frame != 20 {
+		t.Errorf("want one settings change in frame 20 but have %v", recording.Changes)
+	}
+	// the replay is watched with default settings
+	replayed := newWorld()
+	player := recording.Play(replayed)
+	var controls game.Controls
+	for frame := uint32(0); frame < frames; frame++ {
+		player.Feed(frame, &controls)
+		replayed.Step(controls.NextInput(), 1.0/60)
+	}
+
+	if !player.Done() {
+		t.Error("not all events were played back")
+	}
+	if SettingsOf(replayed) != SettingsOf(live) {
+		t.Errorf("want settings %+v but have %+v", SettingsOf(live), SettingsOf(replayed))
+	}
+	if replayed.Pos != live.Pos || replayed.Yaw != live.Yaw || replayed.Pitch != live.Pitch {
+		t.Errorf("replay differs, live: %v %v %v, replay: %v %v %v",
+			live.Pos, live.Yaw, live.Pitch, replayed.Pos, replayed.Yaw, replayed.Pitch)
+	}
+}
+
+func TestOldFilesHaveNoSettings(t *testing.T) {
+	file := append([]byte(magic), 3, 0, byte(game.KeyDown), byte(game.KeyJump))
+	rec, err := Read(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.HasSettings || len(rec.Events) != 1 {
+		t.Errorf("unexpected recording %+v", rec)
+	}
+	w := newWorld()
+	w.MoveSpeed = 5
+	rec.Play(w)
+	if w.MoveSpeed != 5 {
+		t.Error("playing an old file must keep the world's settings")
+	}
+}
+
+func newWorld() *game.World {
+	heights := make([][]float32, 9)
+	for i := range heights {
+		heights[i] = make([]float32, 9)
+		for j := range heights[i] {
+			heights[i][j] = float32(i*j) / 20
+		}
+	}
+	return game.NewWorld(game.HeightField{
+		Heights: heights,
+		Scale:   d3dmath.Vec3{1, 1, 1},
+	})
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"sort"
+	"strings"
+
+	"github.com/gonutz/d3d9"
+	"github.com/gonutz/ld40/render"
+	"github.com/gonutz/ld40/vertex"
+)
+
+// resources keeps the game's Direct3D objects by name. Requesting a name that
+// is already loaded returns the same object and counts a reference, the object
+// is released with its last reference. All objects can be created again from
+// their source, see reload, which also brings back the ones in the default
+// pool after the device is reset.
+type resources struct {
+	device *d3d9.Device
+	byName map[string]*resource
+}
+
+// releaser is what all Direct3D objects implement.
+type releaser interface {
+	Release() uint32
+}
+
+type resource struct {
+	name  string
+	refs  int
+	value releaser // nil while released for a device reset
+	// create makes the object from its source, e.g. shader byte code or an
+	// image file.
+	create func(*d3d9.Device) (releaser, error)
+	// lostWithDevice is set for objects in d3d9.POOL_DEFAULT. They live in
+	// video memory and have to be released before the device is reset, e.g.
+	// after alt-tabbing out of fullscreen, and created again afterwards.
+	// Shaders, vertex declarations and managed textures survive a reset.
+	lostWithDevice bool
+}
+
+// object returns the Direct3D object, nil for a zero handle.
+func (r *resource) object() releaser {
+	if r == nil {
+		return nil
+	}
+	return r.value
+}
+
+func newResources(device *d3d9.Device) *resources {
+	return &resources{device: device, byName: make(map[string]*resource)}
+}
+
+// acquire returns the object with the given name, it is created if it is not
+// loaded yet. Errors contain the name.
+func (r *resources) acquire(
+	name string,
+	lostWithDevice bool,
+	create func(*d3d9.Device) (releaser, error),
+) (*resource, error) {
+	if res, ok := r.byName[name]; ok {
+		res.refs++
+		return res, nil
+	}
+	value, err := create(r.device)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	res := &resource{
+		name:           name,
+		refs:           1,
+		value:          value,
+		create:         create,
+		lostWithDevice: lostWithDevice,
+	}
+	r.byName[name] = res
+	return res, nil
+}
+
+// release gives up a reference that acquire returned. Releasing nil does
+// nothing.
+func (r *resources) release(res *resource) {
+	if res == nil {
+		return
+	}
+	res.refs--
+	if res.refs > 0 {
+		return
+	}
+	if res.value != nil {
+		res.value.Release()
+		res.value = nil
+	}
+	delete(r.byName, res.name)
+}
+
+// reload creates the named object again from its source, e.g. after its file
+// changed. If that fails, the old object is kept.
+func (r *resources) reload(name string) error {
+	res, ok := r.byName[name]
+	if !ok {
+		return fmt.Errorf("%s: not loaded", name)
+	}
+	value, err := res.create(r.device)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if res.value != nil {
+		res.value.Release()
+	}
+	res.value = value
+	return nil
+}
+
+// reloadAll reloads every object. All are tried, the errors are returned
+// together.
+func (r *resources) reloadAll() error {
+	var msgs []string
+	for _, name := range r.names() {
+		if err := r.reload(name); err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, "\n"))
+	}
+	return nil
+}
+
+// releaseLost releases the objects that do not survive a device reset, call
+// it before device.Reset.
+func (r *resources) releaseLost() {
+	for _, res := range r.byName {
+		if res.lostWithDevice && res.value != nil {
+			res.value.Release()
+			res.value = nil
+		}
+	}
+}
+
+// restoreLost creates the objects that releaseLost released again, call it
+// after device.Reset.
+func (r *resources) restoreLost() error {
+	for _, name := range r.names() {
+		if res := r.byName[name]; res.lostWithDevice && res.value == nil {
+			if err := r.reload(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *resources) names() []string {
+	names := make([]string, 0, len(r.byName))
+	for name := range r.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The typed handles below wrap a resource. Their get methods return the
+// current object, which changes when it is reloaded, so they must be called
+// every time the object is used.
+
+type vertexShader struct{ *resource }
+
+func (h vertexShader) get() *d3d9.VertexShader {
+	s, _ := h.object().(*d3d9.VertexShader)
+	return s
+}
+
+type pixelShader struct{ *resource }
+
+func (h pixelShader) get() *d3d9.PixelShader {
+	s, _ := h.object().(*d3d9.PixelShader)
+	return s
+}
+
+type vertexDeclaration struct{ *resource }
+
+func (h vertexDeclaration) get() *d3d9.VertexDeclaration {
+	d, _ := h.object().(*d3d9.VertexDeclaration)
+	return d
+}
+
+type vertexBuffer struct{ *resource }
+
+func (h vertexBuffer) get() *d3d9.VertexBuffer {
+	b, _ := h.object().(*d3d9.VertexBuffer)
+	return b
+}
+
+type indexBuffer struct{ *resource }
+
+func (h indexBuffer) get() *d3d9.IndexBuffer {
+	b, _ := h.object().(*d3d9.IndexBuffer)
+	return b
+}
+
+type texture struct{ *resource }
+
+func (h texture) get() *d3d9.Texture {
+	t, _ := h.object().(*d3d9.Texture)
+	return t
+}
+
+func (r *resources) vertexShader(name string, code []byte) (vertexShader, error) {
+	res, err := r.acquire(name, false, func(device *d3d9.Device) (releaser, error) {
+		s, err := device.CreateVertexShaderFromBytes(code)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+	return vertexShader{res}, err
+}
+
+func (r *resources) pixelShader(name string, code []byte) (pixelShader, error) {
+	res, err := r.acquire(name, false, func(device *d3d9.Device) (releaser, error) {
+		s, err := device.CreatePixelShaderFromBytes(code)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+	return pixelShader{res}, err
+}
+
+func (r *resources) vertexDeclaration(name string, elements []d3d9.VERTEXELEMENT) (vertexDeclaration, error) {
+	res, err := r.acquire(name, false, func(device *d3d9.Device) (releaser, error) {
+		d, err := device.CreateVertexDeclaration(elements)
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	})
+	return vertexDeclaration{res}, err
+}
+
+// vertexBuffer creates a static buffer with the vertices, which must not change
+// afterwards. The vertices are a slice of the struct that the format was made
+// from.
+func (r *resources) vertexBuffer(name string, format vertex.Format, vertices interface{}) (vertexBuffer, error) {
+	data, err := format.Pack(vertices)
+	if err != nil {
+		return vertexBuffer{}, fmt.Errorf("%s: %v", name, err)
+	}
+	res, err := r.acquire(name, true, func(device *d3d9.Device) (releaser, error) {
+		b, err := createVertexBuffer(device, data)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	})
+	return vertexBuffer{res}, err
+}
+
+// dynamicVertexBuffer creates an empty buffer of the given size in bytes that
+// is meant to be filled every frame with d3d9.LOCK_DISCARD.
+func (r *resources) dynamicVertexBuffer(name string, size uint, fvf uint32) (vertexBuffer, error) {
+	res, err := r.acquire(name, true, func(device *d3d9.Device) (releaser, error) {
+		b, err := device.CreateVertexBuffer(
+			size,
+			d3d9.USAGE_DYNAMIC|d3d9.USAGE_WRITEONLY,
+			fvf,
+			d3d9.POOL_DEFAULT,
+			0,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	})
+	return vertexBuffer{res}, err
+}
+
+func (r *resources) indexBuffer(name string, mesh render.IndexedMesh) (indexBuffer, error) {
+	res, err := r.acquire(name, true, func(device *d3d9.Device) (releaser, error) {
+		b, err := createIndexBuffer(device, mesh)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	})
+	return indexBuffer{res}, err
+}
+
+// texture loads the PNG file with the given name, see open.
+func (r *resources) texture(name string) (texture, error) {
+	res, err := r.acquire(name, false, func(device *d3d9.Device) (releaser, error) {
+		img, err := loadPng(name)
+		if err != nil {
+			return nil, err
+		}
+		t, err := createTexture(device, img)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+	return texture{res}, err
+}
+
+func createTexture(device *d3d9.Device, img *image.RGBA) (*d3d9.Texture, error) {
+	texture, err := device.CreateTexture(
+		uint(img.Bounds().Dx()),
+		uint(img.Bounds().Dy()),
+		1,
+		d3d9.USAGE_SOFTWAREPROCESSING,
+		d3d9.FMT_A8R8G8B8,
+		d3d9.POOL_MANAGED,
+		0,
+	)
+	if err != nil {
+		return nil, err
+	}
+	r, err := texture.LockRect(0, nil, d3d9.LOCK_DISCARD)
+	if err != nil {
+		texture.Release()
+		return nil, err
+	}
+	r.SetAllBytes(img.Pix, img.Stride)
+	if err := texture.UnlockRect(0); err != nil {
+		texture.Release()
+		return nil, err
+	}
+	return texture, nil
+}
+
+func loadPng(path string) (*image.RGBA, error) {
+	f, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, ok := img.(*image.RGBA); ok {
+		return n, nil
+	}
+	n := image.NewRGBA(img.Bounds())
+	draw.Draw(n, n.Bounds(), img, img.Bounds().Min, draw.Src)
+	return n, nil
+}
+
+func createVertexBuffer(device *d3d9.Device, data []byte) (*d3d9.VertexBuffer, error) {
+	buf, err := device.CreateVertexBuffer(
+		uint(len(data)),
+		d3d9.USAGE_WRITEONLY,
+		0,
+		d3d9.POOL_DEFAULT,
+		0,
+	)
+	if err != nil {
+		return nil, err
+	}
+	mem, err := buf.Lock(0, 0, d3d9.LOCK_DISCARD)
+	if err != nil {
+		buf.Release()
+		return nil, err
+	}
+	mem.SetBytes(0, data)
+	if err := buf.Unlock(); err != nil {
+		buf.Release()
+		return nil, err
+	}
+	return buf, nil
+}
+
+// createIndexBuffer creates a 16 or 32 bit index buffer, whichever the mesh
+// uses.
+func createIndexBuffer(device *d3d9.Device, mesh render.IndexedMesh) (*d3d9.IndexBuffer, error) {
+	var format d3d9.FORMAT = d3d9.FMT_INDEX16
+	size := uint(len(mesh.Indices16)) * 2
+	if mesh.Indices32 != nil {
+		format, size = d3d9.FMT_INDEX32, uint(len(mesh.Indices32))*4
+	}
+	buf, err := device.CreateIndexBuffer(
+		size,
+		d3d9.USAGE_WRITEONLY,
+		format,
+		d3d9.POOL_DEFAULT,
+		0,
+	)
+	if err != nil {
+		return nil, err
+	}
+	mem, err := buf.Lock(0, 0, d3d9.LOCK_DISCARD)
+	if err != nil {
+		buf.Release()
+		return nil, err
+	}
+	if mesh.Indices32 != nil {
+		mem.SetUint32s(0, mesh.Indices32)
+	} else {
+		mem.SetUint16s(0, mesh.Indices16)
+	}
+	if err := buf.Unlock(); err != nil {
+		buf.Release()
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,88 @@
+package settings
+
+import (
+	"flag"
+	"strconv"
+)
+
+// Overrides are settings from the command line. They replace the loaded
+// settings for one run, but are not meant to be saved.
+type Overrides struct {
+	set []func(*Settings)
+}
+
+// RegisterFlags defines a flag for each setting in fs. Only flags that are
+// given on the command line override anything.
+func RegisterFlags(fs *flag.FlagSet) *Overrides {
+	o := &Overrides{}
+	intFlag := func(name, usage string, field func(*Settings) *int) {
+		fs.Var(&override{o: o, parse: func(v string) (func(*Settings), error) {
+			n, err := strconv.Atoi(v)
+			return func(s *Settings) { *field(s) = n }, err
+		}}, name, usage)
+	}
+	floatFlag := func(name, usage string, field func(*Settings) *float32) {
+		fs.Var(&override{o: o, parse: func(v string) (func(*Settings), error) {
+			f, err := strconv.ParseFloat(v, 32)
+			return func(s *Settings) { *field(s) = float32(f) }, err
+		}}, name, usage)
+	}
+	boolFlag := func(name, usage string, field func(*Settings) *bool) {
+		fs.Var(&override{o: o, isBool: true, parse: func(v string) (func(*Settings), error) {
+			b, err := strconv.ParseBool(v)
+			return func(s *Settings) { *field(s) = b }, err
+		}}, name, usage)
+	}
+
+	intFlag("width", "window width in pixels", func(s *Settings) *int { return &s.Window.Width })
+	intFlag("height", "window height in pixels", func(s *Settings) *int { return &s.Window.Height })
+	boolFlag("fullscreen", "start in fullscreen mode", func(s *Settings) *bool { return &s.Window.Fullscreen })
+	floatFlag("fov", "vertical field of view in degrees", func(s *Settings) *float32 { return &s.Graphics.FieldOfView })
+	intFlag("maxfps", "maximum frames drawn per second", func(s *Settings) *int { return &s.Graphics.MaxFPS })
+	floatFlag("sensitivity", "mouse look speed in degrees per pixel", func(s *Settings) *float32 { return &s.Mouse.Sensitivity })
+	boolFlag("inverty", "look down when moving the mouse up", func(s *Settings) *bool { return &s.Mouse.InvertY })
+	boolFlag("rawmouse", "read the mouse through raw input instead of cursor positions", func(s *Settings) *bool { return &s.Mouse.Raw })
+	floatFlag("volume", "sound volume from 0 to 1", func(s *Settings) *float32 { return &s.Audio.Volume })
+	floatFlag("movespeed", "walking speed in units per second", func(s *Settings) *float32 { return &s.Movement.MoveSpeed })
+	floatFlag("jumpspeed", "initial jump speed in units per second", func(s *Settings) *float32 { return &s.Movement.JumpSpeed })
+	floatFlag("gravity", "gravity in units per second squared, negative pulls down", func(s *Settings) *float32 { return &s.Movement.Gravity })
+	return o
+}
+
+// Apply returns s with the overrides from the command line.
+func (o *Overrides) Apply(s Settings) Settings {
+	for _, set := range o.set {
+		set(&s)
+	}
+	return s
+}
+
+// override is a flag.Value that records a change to the settings when the
+// flag is set.
+type override struct {
+	o      *Overrides
+	isBool bool
+	value  string
+	parse  func(string) (func(*Settings), error)
+}
+
+func (f *override) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+func (f *override) Set(v string) error {
+	set, err := f.parse(v)
+	if err != nil {
+		return err
+	}
+	f.value = v
+	f.o.set = append(f.o.set, set)
+	return nil
+}
+
+func (f *override) IsBoolFlag() bool {
+	return f.isBool
+}
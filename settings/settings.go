@@ -0,0 +1,255 @@
+// Package settings holds the player's options that are kept between runs:
+// window, graphics, mouse and movement. They are stored as a versioned JSON
+// file. Files from older versions are migrated, broken files and files from
+// newer versions fall back to the defaults. Command line flags can override
+// settings for one run, see RegisterFlags.
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gonutz/d3dmath"
+	"github.com/gonutz/ld40/game"
+	"github.com/gonutz/ld40/render"
+)
+
+// Version is the file format that Save writes.
+const Version = 1
+
+// Settings is everything the player can configure, except for the controls,
+// which are in package input.
+type Settings struct {
+	Version  int      `json:"version"`
+	Window   Window   `json:"window"`
+	Graphics Graphics `json:"graphics"`
+	Mouse    Mouse    `json:"mouse"`
+	Audio    Audio    `json:"audio"`
+	Movement Movement `json:"movement"`
+}
+
+type Window struct {
+	// Width and Height are the size of the drawing area when not in
+	// fullscreen mode, in pixels.
+	Width      int  `json:"width"`
+	Height     int  `json:"height"`
+	Fullscreen bool `json:"fullscreen"`
+}
+
+type Graphics struct {
+	// FieldOfView is the vertical field of view in degrees.
+	FieldOfView float32 `json:"fieldOfView"`
+	// MaxFPS limits how many frames are drawn per second. The simulation
+	// always runs at game.TimeStep.
+	MaxFPS int `json:"maxFPS"`
+}
+
+type Mouse struct {
+	Sensitivity float32 `json:"sensitivity"` // degrees per pixel
+	InvertY     bool    `json:"invertY"`
+	// Raw reads the mouse through raw input instead of the cursor position.
+	Raw bool `json:"raw"`
+}
+
+type Audio struct {
+	// Volume is from 0 (silent) to 1. The game has no sounds yet, the setting
+	// is kept for when it does.
+	Volume float32 `json:"volume"`
+}
+
+// Movement are the player's physics, see game.World for what they mean.
+type Movement struct {
+	MoveSpeed       float32 `json:"moveSpeed"`
+	RunSpeed        float32 `json:"runSpeed"`
+	SneakSpeed      float32 `json:"sneakSpeed"`
+	JumpSpeed       float32 `json:"jumpSpeed"`
+	Gravity         float32 `json:"gravity"`
+	PlayerHeight    float32 `json:"playerHeight"`
+	AirAcceleration float32 `json:"airAcceleration"`
+	CoyoteTime      float32 `json:"coyoteTime"`
+	JumpBuffer      float32 `json:"jumpBuffer"`
+	MaxSlope        float32 `json:"maxSlope"`
+	StepHeight      float32 `json:"stepHeight"`
+}
+
+// Defaults returns the settings that the game starts with on the first run.
+// The movement is that of a new game.World.
+func Defaults() Settings {
+	w := game.NewWorld(noGround)
+	return Settings{
+		Version: Version,
+		Window: Window{
+			Width:      480,
+			Height:     320,
+			Fullscreen: true,
+		},
+		Graphics: Graphics{
+			FieldOfView: render.DefaultFieldOfView,
+			MaxFPS:      60,
+		},
+		Mouse: Mouse{
+			Sensitivity: w.LookSensitivity,
+			InvertY:     w.InvertY,
+		},
+		Audio: Audio{Volume: 1},
+		Movement: Movement{
+			MoveSpeed:       w.MoveSpeed,
+			RunSpeed:        w.RunSpeed,
+			SneakSpeed:      w.SneakSpeed,
+			JumpSpeed:       w.JumpSpeed,
+			Gravity:         w.Gravity,
+			PlayerHeight:    w.PlayerHeight,
+			AirAcceleration: w.AirAcceleration,
+			CoyoteTime:      w.CoyoteTime,
+			JumpBuffer:      w.JumpBuffer,
+			MaxSlope:        w.Controller.MaxSlope,
+			StepHeight:      w.Controller.StepHeight,
+		},
+	}
+}
+
+// noGround is a height field without cells, it only exists to create a
+// game.World for its default values.
+var noGround = game.HeightField{
+	Heights: [][]float32{{0}},
+	Scale:   d3dmath.Vec3{1, 1, 1},
+}
+
+// FrameDelay is the minimum time between two drawn frames.
+func (g Graphics) FrameDelay() time.Duration {
+	return time.Second / time.Duration(g.MaxFPS)
+}
+
+// Apply sets the mouse and movement settings in the world.
+func (s Settings) Apply(w *game.World) {
+	m := s.Movement
+	w.MoveSpeed = m.MoveSpeed
+	w.RunSpeed = m.RunSpeed
+	w.SneakSpeed = m.SneakSpeed
+	w.JumpSpeed = m.JumpSpeed
+	w.Gravity = m.Gravity
+	w.PlayerHeight = m.PlayerHeight
+	w.AirAcceleration = m.AirAcceleration
+	w.CoyoteTime = m.CoyoteTime
+	w.JumpBuffer = m.JumpBuffer
+	w.Controller.MaxSlope = m.MaxSlope
+	w.Controller.StepHeight = m.StepHeight
+	w.LookSensitivity = s.Mouse.Sensitivity
+	w.InvertY = s.Mouse.InvertY
+}
+
+// Validate returns an error for settings that the game cannot run with.
+func (s Settings) Validate() error {
+	switch {
+	case s.Window.Width <= 0 || s.Window.Height <= 0:
+		return fmt.Errorf("window size must be positive but is %dx%d", s.Window.Width, s.Window.Height)
+	case s.Graphics.FieldOfView <= 0 || s.Graphics.FieldOfView >= 180:
+		return fmt.Errorf("field of view must be between 0 and 180 degrees but is %v", s.Graphics.FieldOfView)
+	case s.Graphics.MaxFPS <= 0:
+		return fmt.Errorf("maxFPS must be positive but is %d", s.Graphics.MaxFPS)
+	case s.Mouse.Sensitivity <= 0:
+		return fmt.Errorf("mouse sensitivity must be positive but is %v", s.Mouse.Sensitivity)
+	case s.Audio.Volume < 0 || s.Audio.Volume > 1:
+		return fmt.Errorf("volume must be between 0 and 1 but is %v", s.Audio.Volume)
+	case s.Movement.Gravity >= 0:
+		return fmt.Errorf("gravity must pull down but is %v", s.Movement.Gravity)
+	case s.Movement.MaxSlope <= 0 || s.Movement.MaxSlope >= 90:
+		return fmt.Errorf("max slope must be between 0 and 90 degrees but is %v", s.Movement.MaxSlope)
+	}
+	return nil
+}
+
+// UnknownVersionError is returned for files that are not a version that this
+// game knows, e.g. when it was written by a newer game.
+type UnknownVersionError int
+
+func (e UnknownVersionError) Error() string {
+	return fmt.Sprintf("unknown settings version %d", int(e))
+}
+
+// Load reads settings in JSON format. Older versions are migrated to the
+// current Version. Settings that are not in the file keep their defaults.
+func Load(r io.Reader) (Settings, error) {
+	var file map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return Settings{}, fmt.Errorf("settings: %v", err)
+	}
+	var version int
+	if err := json.Unmarshal(file["version"], &version); err != nil {
+		return Settings{}, errors.New("settings: version is missing")
+	}
+	if version < 1 || version > Version {
+		return Settings{}, fmt.Errorf("settings: %w", UnknownVersionError(version))
+	}
+	for v := version; v < Version; v++ {
+		if err := migrations[v](file); err != nil {
+			return Settings{}, fmt.Errorf("settings: migrating version %d: %v", v, err)
+		}
+	}
+	file["version"] = json.RawMessage(fmt.Sprint(Version))
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return Settings{}, fmt.Errorf("settings: %v", err)
+	}
+	s := Defaults()
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Settings{}, fmt.Errorf("settings: %v", err)
+	}
+	if err := s.Validate(); err != nil {
+		return Settings{}, fmt.Errorf("settings: %v", err)
+	}
+	return s, nil
+}
+
+// migrations[v] changes a file of version v to version v+1. Whenever the
+// format changes in a way that older files cannot be read as the new one,
+// Version is increased and a migration added here.
+var migrations = map[int]func(file map[string]json.RawMessage) error{}
+
+// Save writes the settings in the format that Load reads.
+func (s Settings) Save(w io.Writer) error {
+	s.Version = Version
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// LoadFile loads the settings from the given file. If it does not exist, the
+// defaults are returned. If it cannot be loaded, the defaults are returned
+// along with the error.
+func LoadFile(path string) (Settings, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Defaults(), nil
+	}
+	if err != nil {
+		return Defaults(), err
+	}
+	defer f.Close()
+	s, err := Load(f)
+	if err != nil {
+		return Defaults(), fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// SaveFile writes the settings to the given file, replacing it.
+func (s Settings) SaveFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := s.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,143 @@
+package settings
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gonutz/ld40/game"
+)
+
+func TestDefaults(t *testing.T) {
+	s := Defaults()
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Window.Width != 480 || s.Window.Height != 320 || !s.Window.Fullscreen {
+		t.Errorf("unexpected default window %+v", s.Window)
+	}
+	if s.Graphics.FieldOfView != 60 || s.Graphics.FrameDelay() != time.Second/60 {
+		t.Errorf("unexpected default graphics %+v", s.Graphics)
+	}
+
+	// applying the defaults must not change a new world
+	fresh := game.NewWorld(noGround)
+	w := game.NewWorld(noGround)
+	s.Apply(w)
+	if !reflect.DeepEqual(w, fresh) {
+		t.Errorf("default movement differs from a new world:\n%+v\n%+v", w, fresh)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	s := Defaults()
+	s.Window.Fullscreen = false
+	s.Graphics.FieldOfView = 75
+	s.Mouse.InvertY = true
+	s.Movement.Gravity = -20
+	var buf bytes.Buffer
+	if err := s.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != s {
+		t.Errorf("want %+v after loading but have %+v", s, loaded)
+	}
+}
+
+func TestMissingSettingsKeepDefaults(t *testing.T) {
+	s, err := Load(strings.NewReader(`{"version": 1, "graphics": {"fieldOfView": 90}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Defaults()
+	want.Graphics.FieldOfView = 90
+	if s != want {
+		t.Errorf("want %+v but have %+v", want, s)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, test := range []struct {
+		file, msg string
+	}{
+		{`{"version": 1`, "unexpected EOF"},
+		{`{"window": {}}`, "version is missing"},
+		{`{"version": 2}`, "unknown settings version 2"},
+		{`{"version": 0}`, "unknown settings version 0"},
+		{`{"version": 1, "window": {"width": -1}}`, "window size must be positive"},
+		{`{"version": 1, "graphics": {"fieldOfView": 180}}`, "field of view"},
+		{`{"version": 1, "movement": {"gravity": 1}}`, "gravity"},
+		{`{"version": 1, "audio": {"volume": 2}}`, "volume"},
+	} {
+		_, err := Load(strings.NewReader(test.file))
+		if err == nil || !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("%s: want error containing %q but have %v", test.file, test.msg, err)
+		}
+	}
+}
+
+func TestLoadFileFallsBackToDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	s, err := LoadFile(path)
+	if err != nil || s != Defaults() {
+		t.Errorf("missing file should give the defaults but has %+v, %v", s, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version": 99, "window": {"width": 1}}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	s, err = LoadFile(path)
+	var version UnknownVersionError
+	if !errors.As(err, &version) || version != 99 {
+		t.Errorf("want unknown version error but have %v", err)
+	}
+	if s != Defaults() {
+		t.Errorf("unknown version should give the defaults but has %+v", s)
+	}
+
+	want := Defaults()
+	want.Mouse.Sensitivity = 0.3
+	if err := want.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := LoadFile(path); err != nil || s != want {
+		t.Errorf("want %+v but have %+v, %v", want, s, err)
+	}
+}
+
+func TestFlagsOverrideOnlyWhatIsGiven(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := RegisterFlags(fs)
+	if err := fs.Parse([]string{"-fov=90", "-inverty", "-width", "640", "-fullscreen=false"}); err != nil {
+		t.Fatal(err)
+	}
+	loaded := Defaults()
+	loaded.Movement.Gravity = -5
+	s := o.Apply(loaded)
+
+	want := loaded
+	want.Graphics.FieldOfView = 90
+	want.Mouse.InvertY = true
+	want.Window.Width = 640
+	want.Window.Fullscreen = false
+	if s != want {
+		t.Errorf("want %+v but have %+v", want, s)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&bytes.Buffer{})
+	RegisterFlags(fs)
+	if err := fs.Parse([]string{"-fov=wide"}); err == nil {
+		t.Error("invalid flag value must be an error")
+	}
+}
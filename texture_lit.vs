@@ -1,4 +1,16 @@
 float4x4 mvp : register(c0);
+// model places the vertex in the world for the fog distance, normalMatrix is
+// its inverse transpose which keeps normals perpendicular to their surface
+float4x4 model : register(c4);
+float4x4 normalMatrix : register(c8);
+float3 toLight : register(c12); // unit vector pointing at the light
+float3 lightColor : register(c13);
+float3 eye : register(c14);
+// fog.x is the end of linear fog, fog.y is 1 / (end - start), fog.z is the
+// density of exponential fog
+float4 fog : register(c15);
+// exactly one of fogMode.x (no fog), .y (linear) or .z (exponential) is 1
+float4 fogMode : register(c16);
 
 struct input {
 	float4 position: POSITION0;
@@ -10,16 +22,18 @@ struct output {
 	float4 position: POSITION0;
 	float4 color   : COLOR0;
 	float2 texCoord: TEXCOORD0;
+	float  fog     : TEXCOORD1; // 1 shows the surface, 0 only the fog
 };
 
 void main(in input IN, out output OUT) {
-	float3 diffuseDir = normalize(-float3(-0.7, -0.1, 0.7));
-	float4 diffuseColor = float4(1, 1, 1, 1);
-	float diffusePower = 1.0;
-	
 	OUT.position = mul(IN.position, mvp);
-	// TODO if we have a model transform it must be applied to the normal as well
-	float lightPower = dot(IN.normal, diffuseDir);
-	OUT.color = saturate(diffuseColor * diffusePower * lightPower);
+	float3 normal = normalize(mul(float4(IN.normal, 0), normalMatrix).xyz);
+	float lightPower = dot(normal, toLight);
+	OUT.color = float4(saturate(lightColor * lightPower), 1);
 	OUT.texCoord = IN.texCoord;
+
+	float distance = length(mul(IN.position, model).xyz - eye);
+	float linearFog = saturate((fog.x - distance) * fog.y);
+	float expFog = exp(-fog.z * distance);
+	OUT.fog = dot(fogMode.xyz, float3(1, linearFog, expFog));
 }
@@ -1,26 +1,33 @@
 package main
 
 var pixelShader_texture_lit = []byte{
-	0x00, 0x02, 0xFF, 0xFF, 0xFE, 0xFF, 0x20, 0x00, 0x43, 0x54, 0x41, 0x42,
-	0x1C, 0x00, 0x00, 0x00, 0x53, 0x00, 0x00, 0x00, 0x00, 0x02, 0xFF, 0xFF,
-	0x01, 0x00, 0x00, 0x00, 0x1C, 0x00, 0x00, 0x00, 0x00, 0x01, 0x04, 0x00,
-	0x4C, 0x00, 0x00, 0x00, 0x30, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00,
-	0x01, 0x00, 0x00, 0x00, 0x3C, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-	0x69, 0x6D, 0x61, 0x67, 0x65, 0x54, 0x65, 0x78, 0x00, 0xAB, 0xAB, 0xAB,
-	0x04, 0x00, 0x0C, 0x00, 0x01, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00,
-	0x00, 0x00, 0x00, 0x00, 0x70, 0x73, 0x5F, 0x32, 0x5F, 0x30, 0x00, 0x4D,
-	0x69, 0x63, 0x72, 0x6F, 0x73, 0x6F, 0x66, 0x74, 0x20, 0x28, 0x52, 0x29,
-	0x20, 0x48, 0x4C, 0x53, 0x4C, 0x20, 0x53, 0x68, 0x61, 0x64, 0x65, 0x72,
-	0x20, 0x43, 0x6F, 0x6D, 0x70, 0x69, 0x6C, 0x65, 0x72, 0x20, 0x31, 0x30,
-	0x2E, 0x31, 0x00, 0xAB, 0x51, 0x00, 0x00, 0x05, 0x00, 0x00, 0x0F, 0xA0,
-	0x00, 0x00, 0x00, 0x3F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-	0x00, 0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x80,
-	0x00, 0x00, 0x0F, 0x90, 0x1F, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x80,
-	0x00, 0x00, 0x03, 0xB0, 0x1F, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x90,
-	0x00, 0x08, 0x0F, 0xA0, 0x42, 0x00, 0x00, 0x03, 0x00, 0x00, 0x0F, 0x80,
-	0x00, 0x00, 0xE4, 0xB0, 0x00, 0x08, 0xE4, 0xA0, 0x02, 0x00, 0x00, 0x03,
-	0x01, 0x00, 0x1F, 0x80, 0x00, 0x00, 0xE4, 0x90, 0x00, 0x00, 0x00, 0xA0,
-	0x05, 0x00, 0x00, 0x03, 0x00, 0x00, 0x0F, 0x80, 0x00, 0x00, 0xE4, 0x80,
-	0x01, 0x00, 0xE4, 0x80, 0x01, 0x00, 0x00, 0x02, 0x00, 0x08, 0x0F, 0x80,
+	0x00, 0x02, 0xFF, 0xFF, 0xFE, 0xFF, 0x33, 0x00, 0x43, 0x54, 0x41, 0x42,
+	0x1C, 0x00, 0x00, 0x00, 0xAF, 0x00, 0x00, 0x00, 0x00, 0x02, 0xFF, 0xFF,
+	0x03, 0x00, 0x00, 0x00, 0x1C, 0x00, 0x00, 0x00, 0x00, 0x01, 0x04, 0x00,
+	0xA8, 0x00, 0x00, 0x00, 0x58, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x02, 0x00, 0x60, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x70, 0x00, 0x00, 0x00, 0x02, 0x00, 0x01, 0x00, 0x01, 0x00, 0x02, 0x00,
+	0x7C, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x8C, 0x00, 0x00, 0x00,
+	0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x98, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x61, 0x6D, 0x62, 0x69, 0x65, 0x6E, 0x74, 0x00,
+	0x01, 0x00, 0x03, 0x00, 0x01, 0x00, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x66, 0x6F, 0x67, 0x43, 0x6F, 0x6C, 0x6F, 0x72,
+	0x00, 0xAB, 0xAB, 0xAB, 0x01, 0x00, 0x03, 0x00, 0x01, 0x00, 0x03, 0x00,
+	0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x69, 0x6D, 0x61, 0x67,
+	0x65, 0x54, 0x65, 0x78, 0x00, 0xAB, 0xAB, 0xAB, 0x04, 0x00, 0x0C, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x70, 0x73, 0x5F, 0x32, 0x5F, 0x30, 0x00, 0x6C, 0x64, 0x34, 0x30, 0x20,
+	0x73, 0x68, 0x61, 0x64, 0x65, 0x72, 0x20, 0x61, 0x73, 0x73, 0x65, 0x6D,
+	0x62, 0x6C, 0x65, 0x72, 0x00, 0xAB, 0xAB, 0xAB, 0x1F, 0x00, 0x00, 0x02,
+	0x00, 0x00, 0x00, 0x80, 0x00, 0x00, 0x0F, 0x90, 0x1F, 0x00, 0x00, 0x02,
+	0x00, 0x00, 0x00, 0x80, 0x00, 0x00, 0x03, 0xB0, 0x1F, 0x00, 0x00, 0x02,
+	0x00, 0x00, 0x00, 0x80, 0x01, 0x00, 0x01, 0xB0, 0x1F, 0x00, 0x00, 0x02,
+	0x00, 0x00, 0x00, 0x90, 0x00, 0x08, 0x0F, 0xA0, 0x42, 0x00, 0x00, 0x03,
+	0x00, 0x00, 0x0F, 0x80, 0x00, 0x00, 0xE4, 0xB0, 0x00, 0x08, 0xE4, 0xA0,
+	0x02, 0x00, 0x00, 0x03, 0x01, 0x00, 0x17, 0x80, 0x00, 0x00, 0xE4, 0x90,
+	0x00, 0x00, 0xE4, 0xA0, 0x05, 0x00, 0x00, 0x03, 0x01, 0x00, 0x07, 0x80,
+	0x00, 0x00, 0xE4, 0x80, 0x01, 0x00, 0xE4, 0x80, 0x12, 0x00, 0x00, 0x04,
+	0x00, 0x00, 0x07, 0x80, 0x01, 0x00, 0x00, 0xB0, 0x01, 0x00, 0xE4, 0x80,
+	0x01, 0x00, 0xE4, 0xA0, 0x01, 0x00, 0x00, 0x02, 0x00, 0x08, 0x0F, 0x80,
 	0x00, 0x00, 0xE4, 0x80, 0xFF, 0xFF, 0x00, 0x00,
 }
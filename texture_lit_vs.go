@@ -1,33 +1,85 @@
 package main
 
 var vertexShader_texture_lit = []byte{
-	0x00, 0x02, 0xFE, 0xFF, 0xFE, 0xFF, 0x1E, 0x00, 0x43, 0x54, 0x41, 0x42,
-	0x1C, 0x00, 0x00, 0x00, 0x4B, 0x00, 0x00, 0x00, 0x00, 0x02, 0xFE, 0xFF,
-	0x01, 0x00, 0x00, 0x00, 0x1C, 0x00, 0x00, 0x00, 0x00, 0x01, 0x04, 0x00,
-	0x44, 0x00, 0x00, 0x00, 0x30, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00,
-	0x04, 0x00, 0x02, 0x00, 0x34, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-	0x6D, 0x76, 0x70, 0x00, 0x03, 0x00, 0x03, 0x00, 0x04, 0x00, 0x04, 0x00,
+	0x00, 0x02, 0xFE, 0xFF, 0xFE, 0xFF, 0x68, 0x00, 0x43, 0x54, 0x41, 0x42,
+	0x1C, 0x00, 0x00, 0x00, 0x83, 0x01, 0x00, 0x00, 0x00, 0x02, 0xFE, 0xFF,
+	0x08, 0x00, 0x00, 0x00, 0x1C, 0x00, 0x00, 0x00, 0x00, 0x01, 0x04, 0x00,
+	0x7C, 0x01, 0x00, 0x00, 0xBC, 0x00, 0x00, 0x00, 0x02, 0x00, 0x0E, 0x00,
+	0x01, 0x00, 0x02, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xD0, 0x00, 0x00, 0x00, 0x02, 0x00, 0x0F, 0x00, 0x01, 0x00, 0x02, 0x00,
+	0xD4, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xE4, 0x00, 0x00, 0x00,
+	0x02, 0x00, 0x10, 0x00, 0x01, 0x00, 0x02, 0x00, 0xEC, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0xFC, 0x00, 0x00, 0x00, 0x02, 0x00, 0x0D, 0x00,
+	0x01, 0x00, 0x02, 0x00, 0x08, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x18, 0x01, 0x00, 0x00, 0x02, 0x00, 0x04, 0x00, 0x03, 0x00, 0x02, 0x00,
+	0x20, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x30, 0x01, 0x00, 0x00,
+	0x02, 0x00, 0x00, 0x00, 0x04, 0x00, 0x02, 0x00, 0x34, 0x01, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x44, 0x01, 0x00, 0x00, 0x02, 0x00, 0x08, 0x00,
+	0x03, 0x00, 0x02, 0x00, 0x54, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x64, 0x01, 0x00, 0x00, 0x02, 0x00, 0x0C, 0x00, 0x01, 0x00, 0x02, 0x00,
+	0x6C, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x65, 0x79, 0x65, 0x00,
+	0x01, 0x00, 0x03, 0x00, 0x01, 0x00, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x66, 0x6F, 0x67, 0x00, 0x01, 0x00, 0x03, 0x00,
+	0x01, 0x00, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x66, 0x6F, 0x67, 0x4D, 0x6F, 0x64, 0x65, 0x00, 0x01, 0x00, 0x03, 0x00,
+	0x01, 0x00, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x6C, 0x69, 0x67, 0x68, 0x74, 0x43, 0x6F, 0x6C, 0x6F, 0x72, 0x00, 0xAB,
+	0x01, 0x00, 0x03, 0x00, 0x01, 0x00, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x6D, 0x6F, 0x64, 0x65, 0x6C, 0x00, 0xAB, 0xAB,
+	0x03, 0x00, 0x03, 0x00, 0x04, 0x00, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x6D, 0x76, 0x70, 0x00, 0x03, 0x00, 0x03, 0x00,
+	0x04, 0x00, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x6E, 0x6F, 0x72, 0x6D, 0x61, 0x6C, 0x4D, 0x61, 0x74, 0x72, 0x69, 0x78,
+	0x00, 0xAB, 0xAB, 0xAB, 0x03, 0x00, 0x03, 0x00, 0x04, 0x00, 0x04, 0x00,
+	0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x74, 0x6F, 0x4C, 0x69,
+	0x67, 0x68, 0x74, 0x00, 0x01, 0x00, 0x03, 0x00, 0x01, 0x00, 0x03, 0x00,
 	0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x76, 0x73, 0x5F, 0x32,
-	0x5F, 0x30, 0x00, 0x4D, 0x69, 0x63, 0x72, 0x6F, 0x73, 0x6F, 0x66, 0x74,
-	0x20, 0x28, 0x52, 0x29, 0x20, 0x48, 0x4C, 0x53, 0x4C, 0x20, 0x53, 0x68,
-	0x61, 0x64, 0x65, 0x72, 0x20, 0x43, 0x6F, 0x6D, 0x70, 0x69, 0x6C, 0x65,
-	0x72, 0x20, 0x31, 0x30, 0x2E, 0x31, 0x00, 0xAB, 0x51, 0x00, 0x00, 0x05,
-	0x04, 0x00, 0x0F, 0xA0, 0x50, 0x1A, 0x34, 0x3F, 0xED, 0xD4, 0xCD, 0x3D,
-	0x50, 0x1A, 0x34, 0xBF, 0x00, 0x00, 0x00, 0x00, 0x51, 0x00, 0x00, 0x05,
-	0x05, 0x00, 0x0F, 0xA0, 0x00, 0x00, 0x80, 0x3F, 0x00, 0x00, 0x00, 0x00,
-	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x02,
-	0x00, 0x00, 0x00, 0x80, 0x00, 0x00, 0x0F, 0x90, 0x1F, 0x00, 0x00, 0x02,
-	0x03, 0x00, 0x00, 0x80, 0x01, 0x00, 0x0F, 0x90, 0x1F, 0x00, 0x00, 0x02,
-	0x05, 0x00, 0x00, 0x80, 0x02, 0x00, 0x0F, 0x90, 0x09, 0x00, 0x00, 0x03,
-	0x00, 0x00, 0x01, 0xC0, 0x00, 0x00, 0xE4, 0x90, 0x00, 0x00, 0xE4, 0xA0,
-	0x09, 0x00, 0x00, 0x03, 0x00, 0x00, 0x02, 0xC0, 0x00, 0x00, 0xE4, 0x90,
-	0x01, 0x00, 0xE4, 0xA0, 0x09, 0x00, 0x00, 0x03, 0x00, 0x00, 0x04, 0xC0,
-	0x00, 0x00, 0xE4, 0x90, 0x02, 0x00, 0xE4, 0xA0, 0x09, 0x00, 0x00, 0x03,
-	0x00, 0x00, 0x08, 0xC0, 0x00, 0x00, 0xE4, 0x90, 0x03, 0x00, 0xE4, 0xA0,
-	0x08, 0x00, 0x00, 0x03, 0x00, 0x00, 0x01, 0x80, 0x01, 0x00, 0xE4, 0x90,
-	0x04, 0x00, 0xE4, 0xA0, 0x0B, 0x00, 0x00, 0x03, 0x00, 0x00, 0x01, 0x80,
-	0x00, 0x00, 0x00, 0x80, 0x04, 0x00, 0xFF, 0xA0, 0x0A, 0x00, 0x00, 0x03,
-	0x00, 0x00, 0x0F, 0xD0, 0x00, 0x00, 0x00, 0x80, 0x05, 0x00, 0x00, 0xA0,
-	0x01, 0x00, 0x00, 0x02, 0x00, 0x00, 0x03, 0xE0, 0x02, 0x00, 0xE4, 0x90,
-	0xFF, 0xFF, 0x00, 0x00,
+	0x5F, 0x30, 0x00, 0x6C, 0x64, 0x34, 0x30, 0x20, 0x73, 0x68, 0x61, 0x64,
+	0x65, 0x72, 0x20, 0x61, 0x73, 0x73, 0x65, 0x6D, 0x62, 0x6C, 0x65, 0x72,
+	0x00, 0xAB, 0xAB, 0xAB, 0x51, 0x00, 0x00, 0x05, 0x11, 0x00, 0x0F, 0xA0,
+	0x00, 0x00, 0x80, 0x3F, 0x00, 0x00, 0x00, 0x00, 0x3B, 0xAA, 0xB8, 0x3F,
+	0x00, 0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x80,
+	0x00, 0x00, 0x0F, 0x90, 0x1F, 0x00, 0x00, 0x02, 0x03, 0x00, 0x00, 0x80,
+	0x01, 0x00, 0x0F, 0x90, 0x1F, 0x00, 0x00, 0x02, 0x05, 0x00, 0x00, 0x80,
+	0x02, 0x00, 0x0F, 0x90, 0x09, 0x00, 0x00, 0x03, 0x00, 0x00, 0x01, 0xC0,
+	0x00, 0x00, 0xE4, 0x90, 0x00, 0x00, 0xE4, 0xA0, 0x09, 0x00, 0x00, 0x03,
+	0x00, 0x00, 0x02, 0xC0, 0x00, 0x00, 0xE4, 0x90, 0x01, 0x00, 0xE4, 0xA0,
+	0x09, 0x00, 0x00, 0x03, 0x00, 0x00, 0x04, 0xC0, 0x00, 0x00, 0xE4, 0x90,
+	0x02, 0x00, 0xE4, 0xA0, 0x09, 0x00, 0x00, 0x03, 0x00, 0x00, 0x08, 0xC0,
+	0x00, 0x00, 0xE4, 0x90, 0x03, 0x00, 0xE4, 0xA0, 0x08, 0x00, 0x00, 0x03,
+	0x00, 0x00, 0x01, 0x80, 0x01, 0x00, 0xE4, 0x90, 0x08, 0x00, 0xE4, 0xA0,
+	0x08, 0x00, 0x00, 0x03, 0x00, 0x00, 0x02, 0x80, 0x01, 0x00, 0xE4, 0x90,
+	0x09, 0x00, 0xE4, 0xA0, 0x08, 0x00, 0x00, 0x03, 0x00, 0x00, 0x04, 0x80,
+	0x01, 0x00, 0xE4, 0x90, 0x0A, 0x00, 0xE4, 0xA0, 0x08, 0x00, 0x00, 0x03,
+	0x00, 0x00, 0x08, 0x80, 0x00, 0x00, 0xE4, 0x80, 0x00, 0x00, 0xE4, 0x80,
+	0x07, 0x00, 0x00, 0x02, 0x00, 0x00, 0x08, 0x80, 0x00, 0x00, 0xFF, 0x80,
+	0x05, 0x00, 0x00, 0x03, 0x00, 0x00, 0x07, 0x80, 0x00, 0x00, 0xE4, 0x80,
+	0x00, 0x00, 0xFF, 0x80, 0x08, 0x00, 0x00, 0x03, 0x00, 0x00, 0x01, 0x80,
+	0x00, 0x00, 0xE4, 0x80, 0x0C, 0x00, 0xE4, 0xA0, 0x05, 0x00, 0x00, 0x03,
+	0x01, 0x00, 0x07, 0x80, 0x0D, 0x00, 0xE4, 0xA0, 0x00, 0x00, 0x00, 0x80,
+	0x0B, 0x00, 0x00, 0x03, 0x01, 0x00, 0x07, 0x80, 0x01, 0x00, 0xE4, 0x80,
+	0x11, 0x00, 0x55, 0xA0, 0x0A, 0x00, 0x00, 0x03, 0x00, 0x00, 0x07, 0xD0,
+	0x01, 0x00, 0xE4, 0x80, 0x11, 0x00, 0x00, 0xA0, 0x01, 0x00, 0x00, 0x02,
+	0x00, 0x00, 0x08, 0xD0, 0x11, 0x00, 0x00, 0xA0, 0x01, 0x00, 0x00, 0x02,
+	0x00, 0x00, 0x03, 0xE0, 0x02, 0x00, 0xE4, 0x90, 0x09, 0x00, 0x00, 0x03,
+	0x01, 0x00, 0x01, 0x80, 0x00, 0x00, 0xE4, 0x90, 0x04, 0x00, 0xE4, 0xA0,
+	0x09, 0x00, 0x00, 0x03, 0x01, 0x00, 0x02, 0x80, 0x00, 0x00, 0xE4, 0x90,
+	0x05, 0x00, 0xE4, 0xA0, 0x09, 0x00, 0x00, 0x03, 0x01, 0x00, 0x04, 0x80,
+	0x00, 0x00, 0xE4, 0x90, 0x06, 0x00, 0xE4, 0xA0, 0x02, 0x00, 0x00, 0x03,
+	0x01, 0x00, 0x07, 0x80, 0x01, 0x00, 0xE4, 0x80, 0x0E, 0x00, 0xE4, 0xA1,
+	0x08, 0x00, 0x00, 0x03, 0x01, 0x00, 0x08, 0x80, 0x01, 0x00, 0xE4, 0x80,
+	0x01, 0x00, 0xE4, 0x80, 0x07, 0x00, 0x00, 0x02, 0x01, 0x00, 0x08, 0x80,
+	0x01, 0x00, 0xFF, 0x80, 0x06, 0x00, 0x00, 0x02, 0x01, 0x00, 0x08, 0x80,
+	0x01, 0x00, 0xFF, 0x80, 0x02, 0x00, 0x00, 0x03, 0x02, 0x00, 0x01, 0x80,
+	0x0F, 0x00, 0x00, 0xA0, 0x01, 0x00, 0xFF, 0x81, 0x05, 0x00, 0x00, 0x03,
+	0x02, 0x00, 0x01, 0x80, 0x02, 0x00, 0x00, 0x80, 0x0F, 0x00, 0x55, 0xA0,
+	0x0B, 0x00, 0x00, 0x03, 0x02, 0x00, 0x01, 0x80, 0x02, 0x00, 0x00, 0x80,
+	0x11, 0x00, 0x55, 0xA0, 0x0A, 0x00, 0x00, 0x03, 0x02, 0x00, 0x02, 0x80,
+	0x02, 0x00, 0x00, 0x80, 0x11, 0x00, 0x00, 0xA0, 0x05, 0x00, 0x00, 0x03,
+	0x02, 0x00, 0x01, 0x80, 0x0F, 0x00, 0xAA, 0xA0, 0x01, 0x00, 0xFF, 0x80,
+	0x05, 0x00, 0x00, 0x03, 0x02, 0x00, 0x01, 0x80, 0x02, 0x00, 0x00, 0x80,
+	0x11, 0x00, 0xAA, 0xA1, 0x0E, 0x00, 0x00, 0x02, 0x02, 0x00, 0x04, 0x80,
+	0x02, 0x00, 0x00, 0x80, 0x01, 0x00, 0x00, 0x02, 0x02, 0x00, 0x01, 0x80,
+	0x11, 0x00, 0x00, 0xA0, 0x08, 0x00, 0x00, 0x03, 0x01, 0x00, 0x01, 0xE0,
+	0x10, 0x00, 0xE4, 0xA0, 0x02, 0x00, 0xE4, 0x80, 0xFF, 0xFF, 0x00, 0x00,
 }
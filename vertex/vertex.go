@@ -0,0 +1,280 @@
+// Package vertex describes the layout of vertices in a vertex buffer with Go
+// structs. Each field is tagged with what it is:
+//
+//	type Lit struct {
+//		Pos    [3]float32 `vertex:"position"`
+//		Normal [3]float32 `vertex:"normal"`
+//		UV     [2]float32 `vertex:"texcoord"`
+//	}
+//
+// Of derives a Format from such a struct: the Elements with their offsets and
+// types and the Stride, which the vertex declaration and the vertex buffer
+// have to agree on. Pack writes vertices in that layout and Check compares it
+// to the inputs of a vertex shader. Nothing in here depends on Direct3D, the
+// front end translates the Elements to its vertex declaration.
+package vertex
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Usage is what a vertex element means to the shader, the semantic in HLSL.
+type Usage int
+
+const (
+	Position Usage = iota
+	Normal
+	TexCoord
+	Color
+)
+
+var usageNames = []string{
+	Position: "position",
+	Normal:   "normal",
+	TexCoord: "texcoord",
+	Color:    "color",
+}
+
+func (u Usage) String() string {
+	if 0 <= u && int(u) < len(usageNames) {
+		return usageNames[u]
+	}
+	return "Usage(" + strconv.Itoa(int(u)) + ")"
+}
+
+// Type is how an element is stored.
+type Type int
+
+const (
+	Float1 Type = iota
+	Float2
+	Float3
+	Float4
+	// ColorARGB is a uint32 with 8 bits per channel, alpha in the highest
+	// byte. The shader reads it as 4 floats from 0 to 1.
+	ColorARGB
+)
+
+// Size is the number of bytes of the type.
+func (t Type) Size() int {
+	if t == ColorARGB {
+		return 4
+	}
+	return 4 * t.Components()
+}
+
+// Components is the number of values that the shader reads.
+func (t Type) Components() int {
+	if t == ColorARGB {
+		return 4
+	}
+	return int(t-Float1) + 1
+}
+
+func (t Type) String() string {
+	if t == ColorARGB {
+		return "color"
+	}
+	return "float" + strconv.Itoa(t.Components())
+}
+
+// Element is one field of a vertex.
+type Element struct {
+	Name       string // of the struct field
+	Offset     int    // in bytes from the start of the vertex
+	Type       Type
+	Usage      Usage
+	UsageIndex int // e.g. 1 for the second set of texture coordinates
+}
+
+// Format is the memory layout of a vertex struct. The elements are packed
+// without gaps in the order of the struct fields.
+type Format struct {
+	Elements []Element
+	Stride   int // bytes per vertex
+	vertex   reflect.Type
+}
+
+// Of derives the Format of a vertex struct from its field tags. The tag is the
+// Usage, followed by the usage index if it is not 0, e.g. "texcoord1". Fields
+// can be float32, arrays of 2 to 4 float32 or, for colors, uint32 in ARGB
+// order. Every field must have a tag and no usage can appear twice.
+func Of(vertex interface{}) (Format, error) {
+	t := reflect.TypeOf(vertex)
+	if t == nil || t.Kind() != reflect.Struct {
+		return Format{}, fmt.Errorf("vertex: %v is not a struct", t)
+	}
+	f := Format{vertex: t}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("vertex")
+		if !ok {
+			return Format{}, fmt.Errorf("vertex: %v.%s has no vertex tag", t, field.Name)
+		}
+		usage, index, err := parseTag(tag)
+		if err != nil {
+			return Format{}, fmt.Errorf("vertex: %v.%s: %v", t, field.Name, err)
+		}
+		typ, err := fieldType(field.Type, usage)
+		if err != nil {
+			return Format{}, fmt.Errorf("vertex: %v.%s: %v", t, field.Name, err)
+		}
+		for _, e := range f.Elements {
+			if e.Usage == usage && e.UsageIndex == index {
+				return Format{}, fmt.Errorf("vertex: %v.%s and %s are both %s", t, e.Name, field.Name, tag)
+			}
+		}
+		f.Elements = append(f.Elements, Element{
+			Name:       field.Name,
+			Offset:     f.Stride,
+			Type:       typ,
+			Usage:      usage,
+			UsageIndex: index,
+		})
+		f.Stride += typ.Size()
+	}
+	if len(f.Elements) == 0 {
+		return Format{}, fmt.Errorf("vertex: %v has no fields", t)
+	}
+	return f, nil
+}
+
+// MustOf is like Of but panics on errors. It is meant for package level
+// variables.
+func MustOf(vertex interface{}) Format {
+	f, err := Of(vertex)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func parseTag(tag string) (Usage, int, error) {
+	for u, name := range usageNames {
+		if !strings.HasPrefix(tag, name) {
+			continue
+		}
+		index := 0
+		if rest := tag[len(name):]; rest != "" {
+			n, err := strconv.Atoi(rest)
+			if err != nil || n < 0 {
+				return 0, 0, fmt.Errorf("invalid usage index in %q", tag)
+			}
+			index = n
+		}
+		return Usage(u), index, nil
+	}
+	return 0, 0, fmt.Errorf("unknown usage %q", tag)
+}
+
+func fieldType(t reflect.Type, usage Usage) (Type, error) {
+	switch {
+	case t.Kind() == reflect.Float32:
+		return Float1, nil
+	case t.Kind() == reflect.Array && t.Elem().Kind() == reflect.Float32 &&
+		2 <= t.Len() && t.Len() <= 4:
+		return Float1 + Type(t.Len()-1), nil
+	case t.Kind() == reflect.Uint32 && usage == Color:
+		return ColorARGB, nil
+	}
+	return 0, fmt.Errorf("unsupported type %v for %v", t, usage)
+}
+
+// Floats is the number of float32 per vertex when the vertices are given as a
+// flat []float32, see CheckFloats.
+func (f Format) Floats() int {
+	return f.Stride / 4
+}
+
+// CheckFloats returns an error if data cannot be vertices of this format:
+// the format must consist only of floats and data must have whole vertices.
+func (f Format) CheckFloats(data []float32) error {
+	for _, e := range f.Elements {
+		if e.Type == ColorARGB {
+			return fmt.Errorf("vertex: %v has a color, it cannot be given as floats", f.vertex)
+		}
+	}
+	if len(data)%f.Floats() != 0 {
+		return fmt.Errorf("vertex: %d floats are no whole number of %v with %d floats each", len(data), f.vertex, f.Floats())
+	}
+	return nil
+}
+
+// Pack writes the vertices, a slice of the struct that the Format was made
+// from, in the Format's layout in little endian byte order.
+func (f Format) Pack(vertices interface{}) ([]byte, error) {
+	v := reflect.ValueOf(vertices)
+	if v.Kind() != reflect.Slice || v.Type().Elem() != f.vertex {
+		return nil, fmt.Errorf("vertex: cannot pack %T, need []%v", vertices, f.vertex)
+	}
+	buf := make([]byte, v.Len()*f.Stride)
+	for i := 0; i < v.Len(); i++ {
+		vertex := v.Index(i)
+		b := buf[i*f.Stride:]
+		for j, e := range f.Elements {
+			field := vertex.Field(j)
+			switch e.Type {
+			case ColorARGB:
+				binary.LittleEndian.PutUint32(b[e.Offset:], uint32(field.Uint()))
+			case Float1:
+				putFloat(b[e.Offset:], field.Float())
+			default:
+				for c := 0; c < e.Type.Components(); c++ {
+					putFloat(b[e.Offset+4*c:], field.Index(c).Float())
+				}
+			}
+		}
+	}
+	return buf, nil
+}
+
+func putFloat(b []byte, x float64) {
+	binary.LittleEndian.PutUint32(b, math.Float32bits(float32(x)))
+}
+
+// Input is a parameter of a vertex shader, e.g. for
+//
+//	float3 normal : NORMAL0;
+//
+// it is Input{Usage: Normal, UsageIndex: 0, Components: 3}.
+type Input struct {
+	Usage      Usage
+	UsageIndex int
+	Components int
+}
+
+// Check returns an error if a shader with the given inputs cannot read
+// vertices of this Format. Every input must have an element with the same
+// number of components. Positions may leave out w, which the shader sets
+// to 1. Elements that the shader does not read are allowed.
+func (f Format) Check(inputs []Input) error {
+	for _, in := range inputs {
+		e, ok := f.element(in.Usage, in.UsageIndex)
+		if !ok {
+			return fmt.Errorf("vertex: %v has no %v%d for the shader", f.vertex, in.Usage, in.UsageIndex)
+		}
+		n := e.Type.Components()
+		positionWithoutW := in.Usage == Position && in.Components == 4 && n == 3
+		if n != in.Components && !positionWithoutW {
+			return fmt.Errorf(
+				"vertex: %v.%s has %d components but the shader reads %d",
+				f.vertex, e.Name, n, in.Components,
+			)
+		}
+	}
+	return nil
+}
+
+func (f Format) element(u Usage, index int) (Element, bool) {
+	for _, e := range f.Elements {
+		if e.Usage == u && e.UsageIndex == index {
+			return e, true
+		}
+	}
+	return Element{}, false
+}
@@ -0,0 +1,163 @@
+package vertex
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type lit struct {
+	Pos    [3]float32 `vertex:"position"`
+	Normal [3]float32 `vertex:"normal"`
+	UV     [2]float32 `vertex:"texcoord"`
+}
+
+type colored struct {
+	Pos    [3]float32 `vertex:"position"`
+	Color  uint32     `vertex:"color"`
+	UV     [2]float32 `vertex:"texcoord"`
+	Detail [2]float32 `vertex:"texcoord1"`
+	Fade   float32    `vertex:"texcoord2"`
+}
+
+func TestFormatOfLitVertex(t *testing.T) {
+	f, err := Of(lit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Element{
+		{Name: "Pos", Offset: 0, Type: Float3, Usage: Position},
+		{Name: "Normal", Offset: 12, Type: Float3, Usage: Normal},
+		{Name: "UV", Offset: 24, Type: Float2, Usage: TexCoord},
+	}
+	if !reflect.DeepEqual(f.Elements, want) {
+		t.Errorf("want elements\n%v\nbut have\n%v", want, f.Elements)
+	}
+	if f.Stride != 32 || f.Floats() != 8 {
+		t.Errorf("want stride 32 and 8 floats but have %d and %d", f.Stride, f.Floats())
+	}
+}
+
+func TestFormatWithColorAndUsageIndices(t *testing.T) {
+	f, err := Of(colored{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Stride != 12+4+8+8+4 {
+		t.Errorf("want stride 36 but have %d", f.Stride)
+	}
+	color, detail, fade := f.Elements[1], f.Elements[3], f.Elements[4]
+	if color.Type != ColorARGB || color.Offset != 12 {
+		t.Errorf("unexpected color element %+v", color)
+	}
+	if detail.Usage != TexCoord || detail.UsageIndex != 1 || detail.Offset != 24 {
+		t.Errorf("unexpected detail element %+v", detail)
+	}
+	if fade.Type != Float1 || fade.UsageIndex != 2 {
+		t.Errorf("unexpected fade element %+v", fade)
+	}
+}
+
+func TestInvalidFormats(t *testing.T) {
+	for _, v := range []interface{}{
+		nil,
+		3,
+		struct{}{},
+		struct {
+			Pos [3]float32
+		}{},
+		struct {
+			Pos [3]float32 `vertex:"place"`
+		}{},
+		struct {
+			Pos [3]float64 `vertex:"position"`
+		}{},
+		struct {
+			Pos [5]float32 `vertex:"position"`
+		}{},
+		struct {
+			Pos uint32 `vertex:"position"`
+		}{},
+		struct {
+			UV  [2]float32 `vertex:"texcoord"`
+			UV0 [2]float32 `vertex:"texcoord0"`
+		}{},
+		struct {
+			UV [2]float32 `vertex:"texcoordX"`
+		}{},
+	} {
+		if _, err := Of(v); err == nil {
+			t.Errorf("no error for %#v", v)
+		}
+	}
+}
+
+func TestPack(t *testing.T) {
+	f := MustOf(colored{})
+	data, err := f.Pack([]colored{
+		{Pos: [3]float32{1, 2, 3}, Color: 0x80FF0000, UV: [2]float32{0.5, 1}, Fade: 7},
+		{Pos: [3]float32{4, 5, 6}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2*f.Stride {
+		t.Fatalf("want %d bytes but have %d", 2*f.Stride, len(data))
+	}
+	float := func(offset int) float32 {
+		return math.Float32frombits(binary.LittleEndian.Uint32(data[offset:]))
+	}
+	if float(0) != 1 || float(8) != 3 || float(16) != 0.5 || float(32) != 7 {
+		t.Errorf("first vertex is packed wrong: % x", data[:f.Stride])
+	}
+	if c := binary.LittleEndian.Uint32(data[12:]); c != 0x80FF0000 {
+		t.Errorf("want color 80FF0000 but have %X", c)
+	}
+	if float(f.Stride+4) != 5 {
+		t.Errorf("second vertex is packed wrong: % x", data[f.Stride:])
+	}
+
+	if _, err := f.Pack([]lit{{}}); err == nil {
+		t.Error("packing a different vertex type should fail")
+	}
+}
+
+func TestCheckFloats(t *testing.T) {
+	f := MustOf(lit{})
+	if err := f.CheckFloats(make([]float32, 16)); err != nil {
+		t.Error(err)
+	}
+	if err := f.CheckFloats(make([]float32, 15)); err == nil {
+		t.Error("a partial vertex should be an error")
+	}
+	if err := MustOf(colored{}).CheckFloats(make([]float32, 9)); err == nil {
+		t.Error("colors cannot be given as floats")
+	}
+}
+
+func TestCheckShaderInputs(t *testing.T) {
+	f := MustOf(lit{})
+	ok := []Input{
+		{Usage: Position, Components: 4},
+		{Usage: Normal, Components: 3},
+		{Usage: TexCoord, Components: 2},
+	}
+	if err := f.Check(ok); err != nil {
+		t.Error(err)
+	}
+	if err := f.Check(ok[:1]); err != nil {
+		t.Errorf("elements that the shader does not read are fine but have %v", err)
+	}
+	for _, in := range []Input{
+		{Usage: Color, Components: 4},
+		{Usage: TexCoord, UsageIndex: 1, Components: 2},
+		{Usage: Normal, Components: 4},
+		{Usage: TexCoord, Components: 3},
+		{Usage: Position, Components: 2},
+	} {
+		if err := f.Check([]Input{in}); err == nil {
+			t.Errorf("no error for shader input %+v", in)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gonutz/d3d9"
+	"github.com/gonutz/ld40/vertex"
+)
+
+var declTypes = [...]d3d9.DECLTYPE{
+	vertex.Float1:    d3d9.DECLTYPE_FLOAT1,
+	vertex.Float2:    d3d9.DECLTYPE_FLOAT2,
+	vertex.Float3:    d3d9.DECLTYPE_FLOAT3,
+	vertex.Float4:    d3d9.DECLTYPE_FLOAT4,
+	vertex.ColorARGB: d3d9.DECLTYPE_D3DCOLOR,
+}
+
+var declUsages = [...]d3d9.DECLUSAGE{
+	vertex.Position: d3d9.DECLUSAGE_POSITION,
+	vertex.Normal:   d3d9.DECLUSAGE_NORMAL,
+	vertex.TexCoord: d3d9.DECLUSAGE_TEXCOORD,
+	vertex.Color:    d3d9.DECLUSAGE_COLOR,
+}
+
+// vertexElements is the vertex declaration for the format, all in stream 0.
+func vertexElements(f vertex.Format) []d3d9.VERTEXELEMENT {
+	elements := make([]d3d9.VERTEXELEMENT, 0, len(f.Elements)+1)
+	for _, e := range f.Elements {
+		elements = append(elements, d3d9.VERTEXELEMENT{
+			Stream:     0,
+			Offset:     uint16(e.Offset),
+			Type:       declTypes[e.Type],
+			Method:     d3d9.DECLMETHOD_DEFAULT,
+			Usage:      declUsages[e.Usage],
+			UsageIndex: byte(e.UsageIndex),
+		})
+	}
+	return append(elements, d3d9.DeclEnd())
+}
+
+// fvf returns the flexible vertex format for the fixed function pipeline. An
+// FVF has a fixed order: a float3 position, a float3 normal, the ARGB diffuse
+// color and then float2 texture coordinates with increasing usage indices.
+// Formats that are not in that order have no FVF.
+func fvf(f vertex.Format) (uint32, error) {
+	order := []struct {
+		usage vertex.Usage
+		typ   vertex.Type
+		flag  uint32
+	}{
+		{vertex.Position, vertex.Float3, d3d9.FVF_XYZ},
+		{vertex.Normal, vertex.Float3, d3d9.FVF_NORMAL},
+		{vertex.Color, vertex.ColorARGB, d3d9.FVF_DIFFUSE},
+	}
+	var flags uint32
+	textures := 0
+	for _, e := range f.Elements {
+		for len(order) > 0 && order[0].usage != e.Usage {
+			order = order[1:]
+		}
+		switch {
+		case len(order) > 0 && e.UsageIndex == 0 && e.Type == order[0].typ:
+			flags |= order[0].flag
+			order = order[1:]
+		case e.Usage == vertex.TexCoord && e.UsageIndex == textures && e.Type == vertex.Float2:
+			textures++
+		default:
+			return 0, fmt.Errorf("%s %v%d of type %v cannot be in an FVF here", e.Name, e.Usage, e.UsageIndex, e.Type)
+		}
+	}
+	if flags&d3d9.FVF_XYZ == 0 {
+		return 0, fmt.Errorf("an FVF needs a float3 position")
+	}
+	return flags | uint32(textures)<<d3d9.FVF_TEXCOUNT_SHIFT, nil
+}
+
+// mustFVF is like fvf but panics on errors, it is meant for package level
+// variables.
+func mustFVF(f vertex.Format) uint32 {
+	flags, err := fvf(f)
+	if err != nil {
+		panic(err)
+	}
+	return flags
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/gonutz/ld40/input"
+)
+
+// XInput is not part of w32, so the DLL is loaded here. Windows 8 and newer
+// have xinput1_4.dll, Windows 7 only comes with xinput9_1_0.dll.
+var xInputGetState = func() *syscall.LazyProc {
+	for _, name := range []string{"xinput1_4.dll", "xinput9_1_0.dll"} {
+		dll := syscall.NewLazyDLL(name)
+		if dll.Load() == nil {
+			return dll.NewProc("XInputGetState")
+		}
+	}
+	return nil
+}()
+
+type xInputState struct {
+	packetNumber uint32
+	buttons      uint16
+	leftTrigger  uint8
+	rightTrigger uint8
+	thumbLX      int16
+	thumbLY      int16
+	thumbRX      int16
+	thumbRY      int16
+}
+
+var xInputButtons = []struct {
+	mask   uint16
+	button input.Button
+}{
+	{0x0001, input.PadUp},
+	{0x0002, input.PadDown},
+	{0x0004, input.PadLeft},
+	{0x0008, input.PadRight},
+	{0x0010, input.PadStart},
+	{0x0020, input.PadBack},
+	{0x0040, input.PadLeftThumb},
+	{0x0080, input.PadRightThumb},
+	{0x0100, input.PadLeftShoulder},
+	{0x0200, input.PadRightShoulder},
+	{0x1000, input.PadA},
+	{0x2000, input.PadB},
+	{0x4000, input.PadX},
+	{0x8000, input.PadY},
+}
+
+// xInputRetry is how long to wait before asking for a disconnected gamepad
+// again. XInputGetState is slow for empty slots, so it should not be called
+// every frame.
+const xInputRetry = time.Second
+
+var xInputLastMiss time.Time
+
+// readGamepad returns the state of the first XInput gamepad. If none is
+// connected, it returns the zero state.
+func readGamepad() input.GamepadState {
+	if xInputGetState == nil || time.Since(xInputLastMiss) < xInputRetry {
+		return input.GamepadState{}
+	}
+	var s xInputState
+	ret, _, _ := xInputGetState.Call(0, uintptr(unsafe.Pointer(&s)))
+	if ret != 0 {
+		xInputLastMiss = time.Now()
+		return input.GamepadState{}
+	}
+	pad := input.GamepadState{
+		LeftX:        thumb(s.thumbLX),
+		LeftY:        thumb(s.thumbLY),
+		RightX:       thumb(s.thumbRX),
+		RightY:       thumb(s.thumbRY),
+		LeftTrigger:  float32(s.leftTrigger) / 255,
+		RightTrigger: float32(s.rightTrigger) / 255,
+	}
+	for _, b := range xInputButtons {
+		if s.buttons&b.mask != 0 {
+			pad.Buttons = append(pad.Buttons, b.button)
+		}
+	}
+	return pad
+}
+
+func thumb(v int16) float32 {
+	if v < 0 {
+		return float32(v) / 32768
+	}
+	return float32(v) / 32767
+}